@@ -0,0 +1,254 @@
+package brewfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Brewfile contains the entries of a Brewfile that setup-mac can install
+type Brewfile struct {
+	Taps  []Tap
+	Brews []Package
+	Casks []Package
+	Mas   []MasApp
+
+	// Warnings lists entries and options that were ignored
+	Warnings []string
+}
+
+// Tap is a Homebrew tap with an optional clone URL
+type Tap struct {
+	Name string
+	URL  string
+}
+
+// Package is a formula or cask with extra `brew install` arguments
+type Package struct {
+	Name string
+	Args []string
+	// Greedy upgrades a cask even if it updates itself
+	Greedy bool
+}
+
+// MasApp is a Mac App Store app
+type MasApp struct {
+	Name string
+	ID   int
+}
+
+// ParseFile parses the Brewfile at path
+func ParseFile(path string) (*Brewfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bf, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bf, nil
+}
+
+// Parse reads tap, brew, cask, mas and cask_args entries. Other entries
+// (vscode, whalebrew, ...) are skipped with a warning.
+func Parse(r io.Reader) (*Brewfile, error) {
+	bf := &Brewfile{}
+	var caskArgs []string
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, args, opts, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		switch directive {
+		case "tap":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: tap needs a name", lineNum)
+			}
+			tap := Tap{Name: args[0]}
+			if len(args) > 1 {
+				tap.URL = args[1]
+			}
+			bf.Taps = append(bf.Taps, tap)
+
+		case "brew", "cask":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: %s needs a name", lineNum, directive)
+			}
+			pkg := Package{Name: args[0]}
+			for _, key := range sortedKeys(opts) {
+				value := opts[key]
+				if key == "greedy" && directive == "cask" {
+					pkg.Greedy = value == true
+					continue
+				}
+				if key != "args" {
+					bf.Warnings = append(bf.Warnings, fmt.Sprintf("line %d: %s %q: option %s ignored", lineNum, directive, pkg.Name, key))
+					continue
+				}
+				flags, err := toFlags(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNum, err)
+				}
+				pkg.Args = append(pkg.Args, flags...)
+			}
+			if directive == "brew" {
+				bf.Brews = append(bf.Brews, pkg)
+			} else {
+				bf.Casks = append(bf.Casks, pkg)
+			}
+
+		case "cask_args":
+			flags, err := toFlags(opts)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			caskArgs = append(caskArgs, flags...)
+
+		case "mas":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: mas needs a name", lineNum)
+			}
+			id, ok := opts["id"].(int)
+			if !ok {
+				return nil, fmt.Errorf("line %d: mas %q needs a numeric id", lineNum, args[0])
+			}
+			bf.Mas = append(bf.Mas, MasApp{Name: args[0], ID: id})
+
+		default:
+			bf.Warnings = append(bf.Warnings, fmt.Sprintf("line %d: %s entries are not supported", lineNum, directive))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// cask_args apply to every cask
+	if len(caskArgs) > 0 {
+		for i := range bf.Casks {
+			bf.Casks[i].Args = append(append([]string{}, caskArgs...), bf.Casks[i].Args...)
+		}
+	}
+
+	return bf, nil
+}
+
+// toFlags converts Brewfile args to command line flags. Lists become
+// --value, hashes become --key=value (or --key for true).
+func toFlags(value any) ([]string, error) {
+	var flags []string
+
+	switch v := value.(type) {
+	case string:
+		flags = append(flags, "--"+v)
+	case []any:
+		for _, item := range v {
+			flags = append(flags, "--"+fmt.Sprint(item))
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			flag := "--" + strings.ReplaceAll(key, "_", "-")
+			switch val := v[key].(type) {
+			case bool:
+				if val {
+					flags = append(flags, flag)
+				}
+			default:
+				flags = append(flags, fmt.Sprintf("%s=%v", flag, val))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported args value %v", value)
+	}
+
+	return flags, nil
+}
+
+// Write writes a Brewfile that brew bundle can read
+func Write(w io.Writer, bf *Brewfile) error {
+	bw := bufio.NewWriter(w)
+
+	for _, tap := range bf.Taps {
+		if tap.URL != "" {
+			fmt.Fprintf(bw, "tap %s, %s\n", quote(tap.Name), quote(tap.URL))
+		} else {
+			fmt.Fprintf(bw, "tap %s\n", quote(tap.Name))
+		}
+	}
+	for _, pkg := range bf.Brews {
+		fmt.Fprintf(bw, "brew %s%s\n", quote(pkg.Name), brewArgs(pkg.Args))
+	}
+	for _, pkg := range bf.Casks {
+		greedy := ""
+		if pkg.Greedy {
+			greedy = ", greedy: true"
+		}
+		fmt.Fprintf(bw, "cask %s%s%s\n", quote(pkg.Name), caskArgs(pkg.Args), greedy)
+	}
+	for _, app := range bf.Mas {
+		fmt.Fprintf(bw, "mas %s, id: %d\n", quote(app.Name), app.ID)
+	}
+
+	return bw.Flush()
+}
+
+// brewArgs formats formula flags as args: ["flag", ...]
+func brewArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	items := make([]string, len(args))
+	for i, arg := range args {
+		items[i] = quote(strings.TrimPrefix(arg, "--"))
+	}
+	return ", args: [" + strings.Join(items, ", ") + "]"
+}
+
+// caskArgs formats cask flags as args: { key: value, ... }
+func caskArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	items := make([]string, len(args))
+	for i, arg := range args {
+		key, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		key = strings.ReplaceAll(key, "-", "_")
+		if hasValue {
+			items[i] = fmt.Sprintf("%s: %s", key, quote(value))
+		} else {
+			items[i] = key + ": true"
+		}
+	}
+	return ", args: { " + strings.Join(items, ", ") + " }"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
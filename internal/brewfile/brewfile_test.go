@@ -0,0 +1,118 @@
+package brewfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBrewfile = `# Team Brewfile
+tap "homebrew/bundle"
+tap "acme/tools", "https://github.com/acme/homebrew-tools.git"
+cask_args appdir: "~/Applications"
+
+brew "wget"
+brew "imagemagick", args: ["with-webp", "HEAD"]
+brew "mysql@8.0", restart_service: :changed, link: true # database
+cask "firefox", args: { no_quarantine: true }
+cask 'google-chrome'
+mas "Xcode", id: 497_799_835
+vscode "golang.go"
+`
+
+func TestParse(t *testing.T) {
+	bf, err := Parse(strings.NewReader(testBrewfile))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	wantTaps := []Tap{
+		{Name: "homebrew/bundle"},
+		{Name: "acme/tools", URL: "https://github.com/acme/homebrew-tools.git"},
+	}
+	if !reflect.DeepEqual(bf.Taps, wantTaps) {
+		t.Errorf("taps: got %+v, want %+v", bf.Taps, wantTaps)
+	}
+
+	wantBrews := []Package{
+		{Name: "wget"},
+		{Name: "imagemagick", Args: []string{"--with-webp", "--HEAD"}},
+		{Name: "mysql@8.0"},
+	}
+	if !reflect.DeepEqual(bf.Brews, wantBrews) {
+		t.Errorf("brews: got %+v, want %+v", bf.Brews, wantBrews)
+	}
+
+	wantCasks := []Package{
+		{Name: "firefox", Args: []string{"--appdir=~/Applications", "--no-quarantine"}},
+		{Name: "google-chrome", Args: []string{"--appdir=~/Applications"}},
+	}
+	if !reflect.DeepEqual(bf.Casks, wantCasks) {
+		t.Errorf("casks: got %+v, want %+v", bf.Casks, wantCasks)
+	}
+
+	wantMas := []MasApp{{Name: "Xcode", ID: 497799835}}
+	if !reflect.DeepEqual(bf.Mas, wantMas) {
+		t.Errorf("mas: got %+v, want %+v", bf.Mas, wantMas)
+	}
+
+	// link, restart_service and vscode are reported
+	if len(bf.Warnings) != 3 {
+		t.Errorf("expected 3 warnings, got %v", bf.Warnings)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		`brew`,
+		`brew "unterminated`,
+		`brew "foo", args: ["a"`,
+		`mas "Xcode"`,
+		`brew "foo#{bar}"`,
+		`brew "foo" "bar"`,
+	}
+
+	for _, content := range invalid {
+		if _, err := Parse(strings.NewReader(content)); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	bf := &Brewfile{
+		Taps:  []Tap{{Name: "homebrew/bundle"}, {Name: "acme/tools", URL: "https://example.com/tools.git"}},
+		Brews: []Package{{Name: "wget"}, {Name: "imagemagick", Args: []string{"--with-webp"}}},
+		Casks: []Package{
+			{Name: "firefox", Args: []string{"--appdir=~/Applications", "--no-quarantine"}},
+			{Name: "slack", Greedy: true},
+		},
+		Mas: []MasApp{{Name: "Xcode", ID: 497799835}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, bf); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	want := `tap "homebrew/bundle"
+tap "acme/tools", "https://example.com/tools.git"
+brew "wget"
+brew "imagemagick", args: ["with-webp"]
+cask "firefox", args: { appdir: "~/Applications", no_quarantine: true }
+cask "slack", greedy: true
+mas "Xcode", id: 497799835
+`
+	if buf.String() != want {
+		t.Errorf("unexpected Brewfile:\n%s", buf.String())
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written Brewfile: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, bf) {
+		t.Errorf("round trip mismatch:\ngot  %+v\nwant %+v", parsed, bf)
+	}
+}
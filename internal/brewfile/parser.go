@@ -0,0 +1,265 @@
+package brewfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// lexer reads the small subset of Ruby used in Brewfiles: strings,
+// symbols, integers, booleans, arrays and hashes
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+// parseLine splits an entry like `brew "foo", args: ["bar"]` into its
+// directive, positional string arguments and options
+func parseLine(line string) (string, []string, map[string]any, error) {
+	l := &lexer{input: []rune(line)}
+
+	directive := l.ident()
+	if directive == "" {
+		return "", nil, nil, fmt.Errorf("expected directive, got %q", line)
+	}
+
+	l.skipSpace()
+	parens := l.consume('(')
+
+	var args []string
+	opts := make(map[string]any)
+	for {
+		l.skipSpace()
+		if l.done() || (parens && l.peek() == ')') {
+			break
+		}
+
+		if key, ok := l.key(); ok {
+			value, err := l.value()
+			if err != nil {
+				return "", nil, nil, err
+			}
+			opts[key] = value
+		} else {
+			value, err := l.value()
+			if err != nil {
+				return "", nil, nil, err
+			}
+			s, ok := value.(string)
+			if !ok {
+				return "", nil, nil, fmt.Errorf("%s: expected string argument, got %v", directive, value)
+			}
+			args = append(args, s)
+		}
+
+		l.skipSpace()
+		if !l.consume(',') {
+			break
+		}
+	}
+
+	if parens && !l.consume(')') {
+		return "", nil, nil, fmt.Errorf("%s: missing )", directive)
+	}
+	l.skipSpace()
+	if !l.done() {
+		return "", nil, nil, fmt.Errorf("%s: unexpected %q", directive, string(l.input[l.pos:]))
+	}
+
+	return directive, args, opts, nil
+}
+
+// done reports whether the rest of the line is empty or a comment
+func (l *lexer) done() bool {
+	return l.pos >= len(l.input) || l.input[l.pos] == '#'
+}
+
+func (l *lexer) peek() rune {
+	if l.pos >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos]
+}
+
+func (l *lexer) consume(r rune) bool {
+	if l.peek() == r {
+		l.pos++
+		return true
+	}
+	return false
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+func (l *lexer) ident() string {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		l.pos++
+	}
+	return string(l.input[start:l.pos])
+}
+
+// key reads `name:` or `:name =>` and leaves the position unchanged if
+// there is no key
+func (l *lexer) key() (string, bool) {
+	start := l.pos
+
+	if l.consume(':') {
+		if name := l.ident(); name != "" {
+			l.skipSpace()
+			if l.consume('=') && l.consume('>') {
+				l.skipSpace()
+				return name, true
+			}
+		}
+		l.pos = start
+		return "", false
+	}
+
+	if name := l.ident(); name != "" && l.peek() == ':' {
+		l.pos++
+		if l.peek() != ':' {
+			l.skipSpace()
+			return name, true
+		}
+	}
+
+	l.pos = start
+	return "", false
+}
+
+func (l *lexer) value() (any, error) {
+	l.skipSpace()
+
+	switch r := l.peek(); {
+	case r == '"' || r == '\'':
+		return l.str()
+	case r == ':':
+		l.pos++
+		return l.ident(), nil
+	case r == '[':
+		return l.array()
+	case r == '{':
+		return l.hash()
+	case r == '-' || unicode.IsDigit(r):
+		start := l.pos
+		l.pos++
+		for l.pos < len(l.input) && (unicode.IsDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
+			l.pos++
+		}
+		n, err := strconv.Atoi(strings.ReplaceAll(string(l.input[start:l.pos]), "_", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", string(l.input[start:l.pos]))
+		}
+		return n, nil
+	}
+
+	switch word := l.ident(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	case "":
+		return nil, fmt.Errorf("unexpected %q", string(l.input[l.pos:]))
+	default:
+		return nil, fmt.Errorf("unsupported expression %q", word)
+	}
+}
+
+func (l *lexer) str() (string, error) {
+	quote := l.input[l.pos]
+	l.pos++
+
+	var b strings.Builder
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		l.pos++
+		switch {
+		case r == quote:
+			return b.String(), nil
+		case r == '\\' && l.pos < len(l.input):
+			next := l.input[l.pos]
+			l.pos++
+			if quote == '"' && next == 'n' {
+				b.WriteRune('\n')
+			} else if quote == '\'' && next != '\'' && next != '\\' {
+				b.WriteRune('\\')
+				b.WriteRune(next)
+			} else {
+				b.WriteRune(next)
+			}
+		case r == '#' && quote == '"' && l.peek() == '{':
+			return "", fmt.Errorf("string interpolation is not supported")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (l *lexer) array() ([]any, error) {
+	l.pos++ // [
+
+	var items []any
+	for {
+		l.skipSpace()
+		if l.consume(']') {
+			return items, nil
+		}
+
+		item, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		l.skipSpace()
+		if !l.consume(',') {
+			if l.consume(']') {
+				return items, nil
+			}
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (l *lexer) hash() (map[string]any, error) {
+	l.pos++ // {
+
+	items := make(map[string]any)
+	for {
+		l.skipSpace()
+		if l.consume('}') {
+			return items, nil
+		}
+
+		key, ok := l.key()
+		if !ok {
+			return nil, fmt.Errorf("expected key in hash")
+		}
+		value, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		items[key] = value
+
+		l.skipSpace()
+		if !l.consume(',') {
+			if l.consume('}') {
+				return items, nil
+			}
+			return nil, fmt.Errorf("expected , or } in hash")
+		}
+	}
+}
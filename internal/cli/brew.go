@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+)
+
+var (
+	brewExportFormat string
+	brewExportOutput string
+	brewLockOutput   string
+	brewLockCheck    bool
+)
+
+var brewCmd = &cobra.Command{
+	Use:   "brew",
+	Short: "Work with the Homebrew part of the config",
+	Long: `Work with the Homebrew packages from the resolved config.
+
+Examples:
+  # Generate a Brewfile for brew bundle
+  setup-mac brew export --format brewfile --output Brewfile
+
+  # Compare this machine with a lock file from another one
+  setup-mac brew lock --check --output team.lock.json`,
+}
+
+var brewExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export Homebrew packages from the config",
+	Long: `Write the taps, formulae, casks and App Store apps from the resolved
+config (including homebrew.brewfile) in another format.
+
+Supported formats:
+  brewfile   Brewfile for brew bundle
+
+Examples:
+  # Print a Brewfile for the default config
+  setup-mac brew export --format brewfile
+
+  # Write a Brewfile for a custom config and profile
+  setup-mac brew export --config team.yaml --profile work --output Brewfile
+  brew bundle --file Brewfile`,
+	RunE: runBrewExport,
+}
+
+var brewLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Write or check the Homebrew lock file",
+	Long: `Record the installed versions of the configured formulae and casks in
+homebrew.lock_file (brew.lock.json). install and update refresh the lock
+file automatically.
+
+With --check the lock file is compared with this machine instead, and the
+command fails if any version differs.
+
+Examples:
+  # Write the lock file
+  setup-mac brew lock
+
+  # Check this machine against a lock file from another one
+  setup-mac brew lock --check --output ~/Downloads/brew.lock.json`,
+	RunE: runBrewLock,
+	// A failed check is reported by Execute, not a usage error
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var brewServicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "Manage the services from homebrew.services",
+}
+
+var brewServicesStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop all services from homebrew.services",
+	Long: `Stop every service listed in homebrew.services and remove it from
+login, e.g. before removing setup-mac or handing over a machine.
+
+Examples:
+  setup-mac brew services stop
+  setup-mac brew services stop --dry-run`,
+	RunE: runBrewServicesStop,
+}
+
+var brewServicesDryRun bool
+
+func init() {
+	rootCmd.AddCommand(brewCmd)
+	brewCmd.AddCommand(brewExportCmd)
+	brewCmd.AddCommand(brewLockCmd)
+	brewCmd.AddCommand(brewServicesCmd)
+	brewServicesCmd.AddCommand(brewServicesStopCmd)
+
+	brewExportCmd.Flags().StringVar(&brewExportFormat, "format", "brewfile", "output format (brewfile)")
+	brewExportCmd.Flags().StringVarP(&brewExportOutput, "output", "o", "", "file to write to (default: stdout)")
+
+	brewLockCmd.Flags().StringVarP(&brewLockOutput, "output", "o", "", "lock file (default: homebrew.lock_file)")
+	brewLockCmd.Flags().BoolVar(&brewLockCheck, "check", false, "compare this machine with the lock file")
+
+	brewServicesStopCmd.Flags().BoolVarP(&brewServicesDryRun, "dry-run", "n", false, "show what would be done without making changes")
+}
+
+func runBrewExport(cmd *cobra.Command, args []string) error {
+	if brewExportFormat != "brewfile" {
+		return fmt.Errorf("unsupported format: %s (supported: brewfile)", brewExportFormat)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	packages, err := installer.BrewPackages(cfg.Homebrew)
+	if err != nil {
+		return err
+	}
+	for _, warning := range packages.Warnings {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⚠ Brewfile: %s\n", warning)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by setup-mac brew export\n")
+	if err := brewfile.Write(&buf, packages); err != nil {
+		return fmt.Errorf("failed to build Brewfile: %w", err)
+	}
+
+	if brewExportOutput == "" {
+		fmt.Print(buf.String())
+		return nil
+	}
+
+	if err := os.WriteFile(brewExportOutput, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write Brewfile: %w", err)
+	}
+
+	color.New(color.FgGreen, color.Bold).Printf("Brewfile written to %s\n", brewExportOutput)
+	fmt.Printf("  Install it: brew bundle --file %s\n", brewExportOutput)
+
+	return nil
+}
+
+func runBrewLock(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	path := brewLockOutput
+	if path == "" {
+		path = cfg.Homebrew.LockFile
+	}
+	if path == "" {
+		return fmt.Errorf("no lock file configured (set homebrew.lock_file or use --output)")
+	}
+
+	packages, err := installer.BrewPackages(cfg.Homebrew)
+	if err != nil {
+		return err
+	}
+
+	ictx := installer.NewContext(cfg, false, verbose)
+	state, err := installer.LoadBrewState(context.Background(), ictx.Executor)
+	if err != nil {
+		return err
+	}
+	current := installer.NewBrewLock(state, packages)
+
+	if !brewLockCheck {
+		if err := current.Write(path); err != nil {
+			return fmt.Errorf("failed to write lock file: %w", err)
+		}
+		color.New(color.FgGreen, color.Bold).Printf("Lock file written to %s\n", path)
+		fmt.Printf("  %d formulae, %d casks\n", len(current.Formulae), len(current.Casks))
+		return nil
+	}
+
+	locked, err := installer.ReadBrewLock(path)
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	diffs := locked.Diff(current)
+	if len(diffs) == 0 {
+		color.New(color.FgGreen, color.Bold).Printf("✓ This machine matches %s\n", path)
+		return nil
+	}
+
+	color.New(color.FgYellow, color.Bold).Printf("This machine differs from %s:\n", path)
+	for _, diff := range diffs {
+		fmt.Printf("  • %s\n", diff)
+	}
+	return fmt.Errorf("%d package(s) differ from the lock file", len(diffs))
+}
+
+func runBrewServicesStop(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(cfg.Homebrew.Services) == 0 {
+		fmt.Println("No services configured in homebrew.services")
+		return nil
+	}
+
+	ictx := installer.NewContext(cfg, brewServicesDryRun, verbose)
+	return installer.StopServices(context.Background(), ictx.Executor, cfg.Homebrew.Services)
+}
@@ -0,0 +1,377 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+var (
+	configOutput string
+	configForce  bool
+	exportOutput string
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Create and manage configuration files",
+	Long: `Create and manage setup-mac configuration files.
+
+Examples:
+  # Walk through the configuration interactively
+  setup-mac config init
+
+  # Write the result to a specific file
+  setup-mac config init --output ~/.config/setup-mac/config.yaml
+
+  # Capture this machine's setup as a config
+  setup-mac config export --output my-mac.yaml`,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file interactively",
+	Long: `Walk through the configuration sections and write a minimal override file.
+
+Only values that differ from the embedded defaults are written, so the
+resulting file stays small and keeps picking up future default changes.
+
+Examples:
+  # Create setup-mac.yaml in the current directory
+  setup-mac config init
+
+  # Overwrite an existing file
+  setup-mac config init --output my-config.yaml --force`,
+	RunE: runConfigInit,
+}
+
+var configExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Capture this machine's setup as a config file",
+	Long: `Read installed Homebrew packages, .zshrc, macOS defaults, global Git
+config and SSH key info, and write them as a complete config file.
+
+Git settings that look like credentials are left out. Settings that --config
+sets from a secret:// reference are written as that reference.
+
+Examples:
+  # Print the config to stdout
+  setup-mac config export
+
+  # Write it to a file and check it
+  setup-mac config export --output my-mac.yaml
+  setup-mac validate --config my-mac.yaml`,
+	RunE: runConfigExport,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configExportCmd)
+
+	configInitCmd.Flags().StringVarP(&configOutput, "output", "o", "setup-mac.yaml", "file to write the config to")
+	configInitCmd.Flags().BoolVarP(&configForce, "force", "f", false, "overwrite the output file if it exists")
+
+	configExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write the config to (default: stdout)")
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(configOutput); err == nil && !configForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", configOutput)
+	}
+
+	base, err := config.LoadDefault()
+	if err != nil {
+		return fmt.Errorf("failed to load default config: %w", err)
+	}
+
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return fmt.Errorf("failed to load default config: %w", err)
+	}
+
+	printBanner()
+	ui.PrintInfo("Answer the questions below. Press Enter to keep the default value.")
+
+	prompt := ui.NewPrompt(true)
+	steps := []struct {
+		title string
+		run   func(*ui.Prompt, *config.Config) error
+	}{
+		{"Homebrew", promptHomebrew},
+		{"Terminal", promptTerminal},
+		{"macOS Defaults", promptMacOS},
+		{"Git", promptGit},
+		{"SSH", promptSSH},
+	}
+
+	for _, step := range steps {
+		ui.PrintHeader(step.title)
+		if err := step.run(prompt, cfg); err != nil {
+			return fmt.Errorf("%s: %w", strings.ToLower(step.title), err)
+		}
+	}
+
+	out, err := config.MarshalOverride(base, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build config: %w", err)
+	}
+
+	if dir := filepath.Dir(configOutput); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	header := "# Generated by setup-mac config init\n# Only values that differ from the embedded defaults are listed.\n\n"
+	if err := os.WriteFile(configOutput, append([]byte(header), out...), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	fmt.Println()
+	color.New(color.FgGreen, color.Bold).Printf("Config written to %s\n", configOutput)
+	fmt.Printf("  Validate it: setup-mac validate --config %s\n", configOutput)
+	fmt.Printf("  Apply it:    setup-mac install --all --config %s\n", configOutput)
+
+	return nil
+}
+
+func runConfigExport(cmd *cobra.Command, args []string) error {
+	// Secret references in --config stay references in the export
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ictx := installer.NewContext(cfg, false, verbose)
+	// Keep stdout clean for the YAML output
+	ictx.Executor.Stdout = os.Stderr
+
+	exported, err := installer.NewExporter(ictx).Export(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to export config: %w", err)
+	}
+
+	out, err := config.Marshal(exported)
+	if err != nil {
+		return fmt.Errorf("failed to build config: %w", err)
+	}
+
+	header := "# Generated by setup-mac config export\n\n"
+	if exportOutput == "" {
+		fmt.Print(header + string(out))
+		return nil
+	}
+
+	if err := os.WriteFile(exportOutput, append([]byte(header), out...), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	color.New(color.FgGreen, color.Bold).Printf("Config written to %s\n", exportOutput)
+	fmt.Printf("  Validate it: setup-mac validate --config %s\n", exportOutput)
+
+	return nil
+}
+
+func promptHomebrew(prompt *ui.Prompt, cfg *config.Config) error {
+	install, err := prompt.Confirm("Install Homebrew packages", cfg.Homebrew.Install)
+	if err != nil {
+		return err
+	}
+	cfg.Homebrew.Install = install
+	if !install {
+		return nil
+	}
+
+	formulae, err := promptList(prompt, "Formulae", cfg.Homebrew.FormulaNames())
+	if err != nil {
+		return err
+	}
+	cfg.Homebrew.Formulae = config.NewFormulae(formulae)
+
+	casks, err := promptList(prompt, "Casks", cfg.Homebrew.CaskNames())
+	if err != nil {
+		return err
+	}
+	cfg.Homebrew.Casks = config.NewCasks(casks)
+
+	return nil
+}
+
+func promptTerminal(prompt *ui.Prompt, cfg *config.Config) error {
+	plugins, err := promptList(prompt, "Oh-My-Zsh plugins", cfg.Terminal.OhMyZsh.Plugins)
+	if err != nil {
+		return err
+	}
+	cfg.Terminal.OhMyZsh.Plugins = plugins
+
+	items := []ui.SelectItem{
+		{Name: "ask", Description: "Ask during installation", Value: ""},
+	}
+	for _, style := range installer.P10kStyles() {
+		items = append(items, ui.SelectItem{
+			Name:        style.Name,
+			Description: style.Description,
+			Value:       style.Name,
+		})
+	}
+
+	_, selected, err := prompt.SelectWithDescription("Powerlevel10k style", items)
+	if err != nil {
+		return err
+	}
+	cfg.Terminal.Powerlevel10k.Style = selected.Value
+
+	return nil
+}
+
+func promptMacOS(prompt *ui.Prompt, cfg *config.Config) error {
+	configure, err := prompt.Confirm("Configure macOS defaults", cfg.MacOS.Configure)
+	if err != nil {
+		return err
+	}
+	cfg.MacOS.Configure = configure
+	if !configure {
+		return nil
+	}
+
+	dock := &cfg.MacOS.Defaults.Dock
+	if dock.Autohide, err = prompt.Confirm("Dock: autohide", dock.Autohide); err != nil {
+		return err
+	}
+	if dock.TileSize, err = promptInt(prompt, "Dock: icon size", dock.TileSize); err != nil {
+		return err
+	}
+	if dock.Magnification, err = prompt.Confirm("Dock: magnification", dock.Magnification); err != nil {
+		return err
+	}
+	if dock.ShowRecents, err = prompt.Confirm("Dock: show recent apps", dock.ShowRecents); err != nil {
+		return err
+	}
+
+	finder := &cfg.MacOS.Defaults.Finder
+	if finder.ShowHiddenFiles, err = prompt.Confirm("Finder: show hidden files", finder.ShowHiddenFiles); err != nil {
+		return err
+	}
+	if finder.ShowExtensions, err = prompt.Confirm("Finder: show file extensions", finder.ShowExtensions); err != nil {
+		return err
+	}
+
+	viewStyles := []string{"list", "icon", "column", "gallery"}
+	for i, style := range viewStyles {
+		if style == finder.DefaultViewStyle {
+			viewStyles[0], viewStyles[i] = viewStyles[i], viewStyles[0]
+			break
+		}
+	}
+	if _, finder.DefaultViewStyle, err = prompt.Select("Finder: default view style", viewStyles); err != nil {
+		return err
+	}
+
+	keyboard := &cfg.MacOS.Defaults.Keyboard
+	if keyboard.KeyRepeat, err = promptInt(prompt, "Keyboard: key repeat (lower is faster)", keyboard.KeyRepeat); err != nil {
+		return err
+	}
+	if keyboard.InitialKeyRepeat, err = promptInt(prompt, "Keyboard: delay until repeat (lower is shorter)", keyboard.InitialKeyRepeat); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func promptGit(prompt *ui.Prompt, cfg *config.Config) error {
+	var err error
+	if cfg.Git.User.Name, err = prompt.Input("Git user name", cfg.Git.User.Name); err != nil {
+		return err
+	}
+	if cfg.Git.User.Email, err = prompt.Input("Git user email", cfg.Git.User.Email); err != nil {
+		return err
+	}
+	return nil
+}
+
+func promptSSH(prompt *ui.Prompt, cfg *config.Config) error {
+	generate, err := prompt.Confirm("Generate an SSH key", cfg.SSH.GenerateKey)
+	if err != nil {
+		return err
+	}
+	cfg.SSH.GenerateKey = generate
+	if !generate {
+		return nil
+	}
+
+	keyTypes := []string{"ed25519", "ecdsa", "rsa"}
+	for i, keyType := range keyTypes {
+		if keyType == cfg.SSH.KeyType {
+			keyTypes[0], keyTypes[i] = keyTypes[i], keyTypes[0]
+			break
+		}
+	}
+	_, keyType, err := prompt.Select("SSH key type", keyTypes)
+	if err != nil {
+		return err
+	}
+
+	// Keep the key file in step with the type unless it was customized
+	if cfg.SSH.KeyFile == "~/.ssh/id_"+cfg.SSH.KeyType {
+		cfg.SSH.KeyFile = "~/.ssh/id_" + keyType
+	}
+	cfg.SSH.KeyType = keyType
+
+	return nil
+}
+
+// promptList lets the user pick from the current items and add new ones
+func promptList(prompt *ui.Prompt, label string, current []string) ([]string, error) {
+	selected, err := prompt.MultiSelect(label, current, current)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := prompt.Input(fmt.Sprintf("Additional %s (comma-separated)", strings.ToLower(label)), "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range strings.Split(extra, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !contains(selected, item) {
+			selected = append(selected, item)
+		}
+	}
+
+	return selected, nil
+}
+
+func promptInt(prompt *ui.Prompt, label string, current int) (int, error) {
+	for {
+		value, err := prompt.Input(label, strconv.Itoa(current))
+		if err != nil {
+			return current, err
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
+			return n, nil
+		}
+		ui.PrintWarning(fmt.Sprintf("%q is not a number", value))
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/jsonc"
+)
+
+var diffJSON bool
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what install would change",
+	Long: `Compare the config with this Mac and list what install would change:
+missing Homebrew formulae and casks, missing runtime versions, global npm,
+pipx, cargo and go packages and editor extensions that are missing or not
+at their pinned version, editor settings that differ, and downloads that are
+missing, changed or pinned to another checksum.
+
+Exits with a non-zero status when install has anything to do.
+
+Examples:
+  setup-mac diff
+  setup-mac diff --json`,
+	RunE: runDiff,
+	// Pending changes are reported by Execute, not a usage error
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "output as JSON")
+}
+
+// Diff actions
+const (
+	DiffInstall = "install"
+	DiffChange  = "change"
+)
+
+// DiffEntry is one change install would make
+type DiffEntry struct {
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	// Action is install or change
+	Action  string `json:"action"`
+	Current string `json:"current,omitempty"`
+	Wanted  string `json:"wanted,omitempty"`
+}
+
+// DiffReport is the result of the diff command
+type DiffReport struct {
+	Changes []DiffEntry `json:"changes"`
+	// Errors lists the checks that could not run
+	Errors []string `json:"errors,omitempty"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ictx := installer.NewContext(cfg, false, verbose)
+	ctx := context.Background()
+	report := DiffReport{Changes: []DiffEntry{}}
+
+	if cfg.Homebrew.Install {
+		changes, err := diffHomebrew(ctx, ictx)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.Changes = append(report.Changes, changes...)
+	}
+
+	if cfg.Runtimes.Manager != "" {
+		for _, status := range installer.RuntimeStatuses(ctx, ictx.Executor, cfg.Runtimes) {
+			for _, version := range status.Missing {
+				report.Changes = append(report.Changes, DiffEntry{Component: "runtimes", Name: status.Tool, Action: DiffInstall, Wanted: version})
+			}
+		}
+	}
+
+	for _, ecosystem := range installer.PackageEcosystems {
+		states, err := installer.PackageStates(ctx, ictx.Executor, cfg.Packages, ecosystem)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Changes = append(report.Changes, diffPackages(ecosystem, states)...)
+	}
+
+	if cfg.VSCode.Editor != "" {
+		changes, err := diffVSCode(ctx, ictx)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.Changes = append(report.Changes, changes...)
+	}
+
+	report.Changes = append(report.Changes, diffDownloads(ictx)...)
+
+	if diffJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDiff(report)
+	}
+
+	if len(report.Changes) > 0 {
+		return fmt.Errorf("%d change(s) to install", len(report.Changes))
+	}
+	return nil
+}
+
+// diffHomebrew lists the configured formulae and casks that are not
+// installed. Without Homebrew all of them are.
+func diffHomebrew(ctx context.Context, ictx *installer.Context) ([]DiffEntry, error) {
+	packages, err := installer.BrewPackages(ictx.Config.Homebrew)
+	if err != nil {
+		return nil, err
+	}
+
+	state := installer.EmptyBrewState()
+	if ictx.Executor.Exists("brew") {
+		if state, err = installer.LoadBrewState(ctx, ictx.Executor); err != nil {
+			return nil, fmt.Errorf("failed to read installed packages: %w", err)
+		}
+	}
+
+	var changes []DiffEntry
+	for _, pkg := range packages.Brews {
+		if _, ok := state.Formula(pkg.Name); !ok {
+			changes = append(changes, DiffEntry{Component: "formula", Name: pkg.Name, Action: DiffInstall})
+		}
+	}
+	for _, pkg := range packages.Casks {
+		if !state.CaskInstalled(pkg.Name) {
+			changes = append(changes, DiffEntry{Component: "cask", Name: pkg.Name, Action: DiffInstall})
+		}
+	}
+	return changes, nil
+}
+
+// diffPackages lists the packages that are missing or not at their pin
+func diffPackages(component string, states []installer.PackageState) []DiffEntry {
+	var changes []DiffEntry
+	for _, state := range states {
+		switch {
+		case state.Missing():
+			changes = append(changes, DiffEntry{Component: component, Name: state.Name, Action: DiffInstall, Wanted: state.Wanted})
+		case state.Mismatched():
+			changes = append(changes, DiffEntry{Component: component, Name: state.Name, Action: DiffChange, Current: state.Installed, Wanted: state.Wanted})
+		}
+	}
+	return changes
+}
+
+// diffVSCode lists the editor extensions to install and the settings that
+// differ from settings.json
+func diffVSCode(ctx context.Context, ictx *installer.Context) ([]DiffEntry, error) {
+	cfg := ictx.Config.VSCode
+	states, err := installer.ExtensionStates(ctx, ictx.Executor, cfg)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffPackages("extension", states)
+
+	keys, err := installer.SettingsChanges(cfg)
+	if err != nil {
+		return changes, fmt.Errorf("settings.json: %w", err)
+	}
+	current := map[string]any{}
+	if content, err := os.ReadFile(installer.VSCodeSettingsPath(cfg.Editor)); err == nil {
+		current, _ = jsonc.Get(content)
+	}
+	for _, key := range keys {
+		change := DiffEntry{Component: "setting", Name: key, Action: DiffChange, Wanted: jsonValue(cfg.Settings[key])}
+		if value, ok := current[key]; ok {
+			change.Current = jsonValue(value)
+		} else {
+			change.Action = DiffInstall
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// diffDownloads lists the downloads that are not installed from their
+// checksum or whose files changed
+func diffDownloads(ictx *installer.Context) []DiffEntry {
+	state := installer.LoadDownloadState()
+	var changes []DiffEntry
+	for _, download := range ictx.Config.Downloads {
+		if state.Current(download, runtime.GOARCH) {
+			continue
+		}
+		change := DiffEntry{Component: "download", Name: download.Name, Action: DiffInstall, Wanted: shortSum(download.SHA256.For(runtime.GOARCH))}
+		if record, ok := state.Downloads[download.Name]; ok {
+			change.Action = DiffChange
+			change.Current = shortSum(record.SHA256)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// shortSum abbreviates a sha256 for the diff output
+func shortSum(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
+
+// jsonValue renders a setting for the diff output
+func jsonValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func printDiff(report DiffReport) {
+	color.New(color.FgCyan, color.Bold).Println("Diff")
+	fmt.Println("──────────────────────────────────────")
+
+	if len(report.Changes) == 0 {
+		color.New(color.FgGreen).Print("  ✓ ")
+		fmt.Println("Nothing to install")
+	}
+	for _, change := range report.Changes {
+		kind := color.New(color.Faint).Sprintf("%-10s", change.Component)
+		switch change.Action {
+		case DiffChange:
+			color.New(color.FgYellow).Print("  ~ ")
+			fmt.Printf("%-28s %s %s → %s\n", change.Name, kind, change.Current, change.Wanted)
+		default:
+			color.New(color.FgGreen).Print("  + ")
+			fmt.Printf("%-28s %s %s\n", change.Name, kind, change.Wanted)
+		}
+	}
+
+	for _, msg := range report.Errors {
+		color.New(color.FgYellow).Print("  ⚠ ")
+		fmt.Println(msg)
+	}
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+)
+
+func TestDiffPackages(t *testing.T) {
+	states := []installer.PackageState{
+		{Ecosystem: "npm", Name: "typescript", Installed: "5.4.5"},
+		{Ecosystem: "npm", Name: "@angular/cli", Wanted: "17.3.0", Installed: "17.3.0"},
+		{Ecosystem: "npm", Name: "prettier"},
+		{Ecosystem: "npm", Name: "eslint", Wanted: "9.0.0"},
+		{Ecosystem: "npm", Name: "pnpm", Wanted: "9.1.0", Installed: "8.15.0"},
+	}
+
+	got := diffPackages("npm", states)
+	want := []DiffEntry{
+		{Component: "npm", Name: "prettier", Action: DiffInstall},
+		{Component: "npm", Name: "eslint", Action: DiffInstall, Wanted: "9.0.0"},
+		{Component: "npm", Name: "pnpm", Action: DiffChange, Current: "8.15.0", Wanted: "9.1.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if changes := diffPackages("npm", states[:2]); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check this Mac for common setup problems",
+	Long: `Check that the config is valid, the Xcode Command Line Tools and Homebrew
+are installed, and new login shells can find brew.
+
+Exits with a non-zero status when a check fails.
+
+Examples:
+  setup-mac doctor`,
+	RunE: runDoctor,
+	// A failed check is reported by Execute, not a usage error
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// DoctorCheck is the result of one doctor check
+type DoctorCheck struct {
+	Name string
+	OK   bool
+	// Detail explains a failed check or how to fix it
+	Detail string
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ictx := installer.NewContext(cfg, false, verbose)
+	ctx := context.Background()
+
+	var checks []DoctorCheck
+
+	result := validateConfig(cfg)
+	check := DoctorCheck{Name: "Config is valid", OK: result.Valid}
+	if !result.Valid {
+		check.Detail = fmt.Sprintf("%d error(s), run setup-mac validate", len(result.Errors))
+	}
+	checks = append(checks, check)
+
+	checks = append(checks, DoctorCheck{
+		Name:   "Xcode Command Line Tools installed",
+		OK:     installer.NewXcodeInstaller(ictx).IsInstalled(ctx),
+		Detail: "run setup-mac install --xcode",
+	})
+
+	if cfg.Homebrew.Install {
+		checks = append(checks, DoctorCheck{
+			Name:   "Homebrew on PATH",
+			OK:     ictx.Executor.Exists("brew"),
+			Detail: "run setup-mac install --homebrew",
+		})
+
+		// Checked without brew on PATH too, a missing block is the usual cause
+		if file := cfg.Homebrew.ShellenvFile; file != "" {
+			shellenv := installer.CheckShellenv(file)
+			checks = append(checks, DoctorCheck{
+				Name:   fmt.Sprintf("brew shellenv loaded from %s", file),
+				OK:     shellenv.Configured,
+				Detail: shellenv.Problem,
+			})
+		}
+	}
+
+	failed := printDoctor(checks)
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// printDoctor prints the checks and returns how many failed
+func printDoctor(checks []DoctorCheck) int {
+	color.New(color.FgCyan, color.Bold).Println("Doctor")
+	fmt.Println("──────────────────────────────────────")
+
+	failed := 0
+	for _, check := range checks {
+		if check.OK {
+			color.New(color.FgGreen).Print("  ✓ ")
+			fmt.Println(check.Name)
+			continue
+		}
+		failed++
+		color.New(color.FgRed).Print("  ✗ ")
+		fmt.Println(check.Name)
+		if check.Detail != "" {
+			fmt.Printf("      %s\n", color.New(color.Faint).Sprint(check.Detail))
+		}
+	}
+	return failed
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
@@ -38,16 +37,22 @@ Please run without sudo:
 }
 
 var (
-	dryRun          bool
-	installAll      bool
-	installXcode    bool
-	installRosetta  bool
-	installHomebrew bool
-	installTerminal bool
-	installShell    bool
-	installMacOS    bool
-	installGit      bool
-	installSSH      bool
+	dryRun           bool
+	installAll       bool
+	installXcode     bool
+	installRosetta   bool
+	installHomebrew  bool
+	installMas       bool
+	installRuntimes  bool
+	installPackages  bool
+	installVSCode    bool
+	installDownloads bool
+	installTerminal  bool
+	installShell     bool
+	installMacOS     bool
+	installGit       bool
+	installSSH       bool
+	installPrune     bool
 )
 
 var installCmd = &cobra.Command{
@@ -61,9 +66,16 @@ Examples:
 
   # Install specific components
   setup-mac install --homebrew
+  setup-mac install --runtimes
+  setup-mac install --packages
+  setup-mac install --vscode
+  setup-mac install --downloads
   setup-mac install --terminal
   setup-mac install --shell
 
+  # Remove Homebrew packages that are not in the config
+  setup-mac install --homebrew --prune --dry-run
+
   # Dry-run mode (show what would be done)
   setup-mac install --all --dry-run
 
@@ -80,11 +92,17 @@ func init() {
 	installCmd.Flags().BoolVar(&installXcode, "xcode", false, "install Xcode Command Line Tools")
 	installCmd.Flags().BoolVar(&installRosetta, "rosetta", false, "install Rosetta 2 (Apple Silicon only)")
 	installCmd.Flags().BoolVar(&installHomebrew, "homebrew", false, "install Homebrew and packages")
+	installCmd.Flags().BoolVar(&installMas, "mas", false, "install Mac App Store apps")
+	installCmd.Flags().BoolVar(&installRuntimes, "runtimes", false, "install language runtimes with mise or asdf")
+	installCmd.Flags().BoolVar(&installPackages, "packages", false, "install global npm, pipx, cargo and go packages")
+	installCmd.Flags().BoolVar(&installVSCode, "vscode", false, "install editor extensions and merge settings")
+	installCmd.Flags().BoolVar(&installDownloads, "downloads", false, "download and verify binaries and archives")
 	installCmd.Flags().BoolVar(&installTerminal, "terminal", false, "install Oh-My-Zsh and Powerlevel10k")
 	installCmd.Flags().BoolVar(&installShell, "shell", false, "configure shell aliases and environment")
 	installCmd.Flags().BoolVar(&installMacOS, "macos", false, "configure macOS defaults")
 	installCmd.Flags().BoolVar(&installGit, "git", false, "configure Git")
 	installCmd.Flags().BoolVar(&installSSH, "ssh", false, "generate SSH key")
+	installCmd.Flags().BoolVar(&installPrune, "prune", false, "uninstall Homebrew packages not in the config")
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
@@ -104,15 +122,18 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load configuration
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Override dry-run from flags
+	// Override dry-run and prune from flags
 	if dryRun {
 		cfg.Settings.DryRun = true
 	}
+	if installPrune {
+		cfg.Homebrew.Prune = true
+	}
 
 	// Create installer context
 	ictx := installer.NewContext(cfg, cfg.Settings.DryRun, verbose)
@@ -201,6 +222,11 @@ func determineInstallers(ictx *installer.Context) []installer.Installer {
 		installers = append(installers, installer.NewXcodeInstaller(ictx))
 		installers = append(installers, installer.NewRosettaInstaller(ictx))
 		installers = append(installers, installer.NewHomebrewInstaller(ictx))
+		installers = append(installers, installer.NewMasInstaller(ictx))
+		installers = append(installers, installer.NewRuntimesInstaller(ictx))
+		installers = append(installers, packageInstallers(ictx)...)
+		installers = append(installers, installer.NewVSCodeInstaller(ictx))
+		installers = append(installers, installer.NewDownloadsInstaller(ictx))
 		installers = append(installers, installer.NewOhMyZshInstaller(ictx))
 		installers = append(installers, installer.NewPowerlevel10kInstaller(ictx))
 		installers = append(installers, installer.NewShellInstaller(ictx))
@@ -222,6 +248,26 @@ func determineInstallers(ictx *installer.Context) []installer.Installer {
 		installers = append(installers, installer.NewHomebrewInstaller(ictx))
 	}
 
+	if installMas {
+		installers = append(installers, installer.NewMasInstaller(ictx))
+	}
+
+	if installRuntimes {
+		installers = append(installers, installer.NewRuntimesInstaller(ictx))
+	}
+
+	if installPackages {
+		installers = append(installers, packageInstallers(ictx)...)
+	}
+
+	if installVSCode {
+		installers = append(installers, installer.NewVSCodeInstaller(ictx))
+	}
+
+	if installDownloads {
+		installers = append(installers, installer.NewDownloadsInstaller(ictx))
+	}
+
 	if installTerminal {
 		installers = append(installers, installer.NewOhMyZshInstaller(ictx))
 		installers = append(installers, installer.NewPowerlevel10kInstaller(ictx))
@@ -245,3 +291,15 @@ func determineInstallers(ictx *installer.Context) []installer.Installer {
 
 	return installers
 }
+
+// packageInstallers returns an installer for each ecosystem with packages
+// configured
+func packageInstallers(ictx *installer.Context) []installer.Installer {
+	var installers []installer.Installer
+	for _, name := range installer.PackageEcosystems {
+		if len(ictx.Config.Packages[name]) > 0 {
+			installers = append(installers, installer.NewPackagesInstaller(ictx, name))
+		}
+	}
+	return installers
+}
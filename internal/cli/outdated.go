@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+)
+
+var outdatedJSON bool
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List components with newer versions available",
+	Long: `List what update would change: outdated Homebrew formulae and casks
+(pinned ones are marked), Oh My Zsh and the git repositories in its custom
+plugins and themes directories, and setup-mac itself.
+
+Exits with a non-zero status when anything other than a pinned formula is
+outdated.
+
+Examples:
+  setup-mac outdated
+  setup-mac outdated --json`,
+	RunE: runOutdated,
+	// Outdated components are reported by Execute, not a usage error
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+
+	outdatedCmd.Flags().BoolVar(&outdatedJSON, "json", false, "output as JSON")
+}
+
+// OutdatedReport is the result of the outdated command
+type OutdatedReport struct {
+	Outdated []installer.OutdatedItem `json:"outdated"`
+	// Errors lists the checks that could not run
+	Errors []string `json:"errors,omitempty"`
+}
+
+func runOutdated(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ictx := installer.NewContext(cfg, false, verbose)
+	ctx := context.Background()
+	report := OutdatedReport{Outdated: []installer.OutdatedItem{}}
+
+	if ictx.Executor.Exists("brew") {
+		items, err := installer.OutdatedBrew(ctx, ictx.Executor)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.Outdated = append(report.Outdated, items...)
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		omzDir := filepath.Join(homeDir, ".oh-my-zsh")
+		if _, err := os.Stat(omzDir); err == nil {
+			items, errs := installer.OutdatedOhMyZsh(ctx, ictx.Executor, omzDir, cfg.Terminal.OhMyZsh.Update.Pins)
+			report.Outdated = append(report.Outdated, items...)
+			for _, err := range errs {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+	}
+
+	if item, err := outdatedSelf(ctx); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("setup-mac: %v", err))
+	} else if item != nil {
+		report.Outdated = append(report.Outdated, *item)
+	}
+
+	if outdatedJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printOutdated(report)
+	}
+
+	count := 0
+	for _, item := range report.Outdated {
+		if !item.Pinned {
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%d component(s) outdated", count)
+	}
+	return nil
+}
+
+// outdatedSelf checks GitHub for a newer setup-mac release
+func outdatedSelf(ctx context.Context) (*installer.OutdatedItem, error) {
+	if Version == "dev" || Version == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	release, isNewer, err := installer.NewVersionChecker(Version).CheckForUpdate(ctx)
+	if err != nil || !isNewer {
+		return nil, err
+	}
+	return &installer.OutdatedItem{Name: "setup-mac", Kind: "setup-mac", Current: Version, Latest: release.TagName}, nil
+}
+
+func printOutdated(report OutdatedReport) {
+	color.New(color.FgCyan, color.Bold).Println("Outdated")
+	fmt.Println("──────────────────────────────────────")
+
+	if len(report.Outdated) == 0 {
+		color.New(color.FgGreen).Print("  ✓ ")
+		fmt.Println("Everything is up to date")
+	}
+	for _, item := range report.Outdated {
+		fmt.Printf("  %-28s %s %s → %s", item.Name, color.New(color.Faint).Sprintf("%-10s", item.Kind), item.Current, item.Latest)
+		if item.Pinned {
+			color.New(color.FgYellow).Print(" (pinned)")
+		}
+		fmt.Println()
+	}
+
+	for _, msg := range report.Errors {
+		color.New(color.FgYellow).Print("  ⚠ ")
+		fmt.Println(msg)
+	}
+}
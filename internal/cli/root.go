@@ -8,11 +8,14 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
 )
 
 var (
 	cfgFile         string
+	profile         string
+	policyFile      string
 	verbose         bool
 	skipUpdateCheck bool
 )
@@ -35,8 +38,9 @@ It installs and configures:
 
 Configuration is done via YAML files.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		// Skip update check for certain commands or if disabled
-		if skipUpdateCheck || cmd.Name() == "version" || cmd.Name() == "help" || cmd.Name() == "completion" {
+		// Skip update check for certain commands or if disabled; outdated
+		// reports the setup-mac version itself
+		if skipUpdateCheck || cmd.Name() == "version" || cmd.Name() == "help" || cmd.Name() == "completion" || cmd.Name() == "outdated" {
 			return
 		}
 
@@ -59,10 +63,29 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default: embedded defaults)")
+	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", os.Getenv("SETUP_MAC_PROFILE"), "profile for conditional config sections (env: SETUP_MAC_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&policyFile, "policy", os.Getenv("SETUP_MAC_POLICY"), "team policy file (env: SETUP_MAC_POLICY, default: "+config.SystemPolicyPath+" if present)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&skipUpdateCheck, "skip-update-check", false, "skip checking for updates")
 }
 
+// loadConfig loads the selected config file, evaluating when: conditions
+// against this machine and the selected profile and enforcing the team policy
+func loadConfig() (*config.Config, error) {
+	opts := config.DefaultOptions()
+	opts.Profile = profile
+
+	if path := config.FindPolicy(policyFile); path != "" {
+		policy, err := config.LoadPolicy(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Policy = policy
+	}
+
+	return config.LoadWithOptions(cfgFile, opts)
+}
+
 // checkForUpdates checks for new versions on GitHub
 func checkForUpdates() {
 	// Don't check for dev versions
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/schedule"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+const (
+	scheduleLogFile = "~/Library/Logs/setup-mac/update.log"
+	schedulePath    = "/opt/homebrew/bin:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+)
+
+var (
+	scheduleUpdate        bool
+	scheduleInterval      string
+	scheduleAt            string
+	scheduleWeekday       string
+	scheduleQuietHours    string
+	scheduleSkipOnBattery bool
+	scheduleLog           string
+	scheduleDryRun        bool
+	scheduleJSON          bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run updates in the background with launchd",
+	Long: `Keep the machine current with a LaunchAgent that runs
+setup-mac update --all --non-interactive on a schedule. setup-mac itself is
+not updated by the agent, run setup-mac update --self for that.
+
+Examples:
+  # Update every Monday at 10:00, but not on battery or at night
+  setup-mac schedule enable --update --interval weekly --weekday mon --at 10:00 \
+    --quiet-hours 22:00-07:00 --skip-on-battery
+
+  setup-mac schedule status
+  setup-mac schedule disable`,
+}
+
+var scheduleEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install and load the update agent",
+	Long: `Write ~/Library/LaunchAgents/` + schedule.Label + `.plist and
+load it with launchctl. Running it again replaces the schedule.
+
+The agent runs this binary with the current --config and --profile, logs to
+--log, and skips runs on battery power or inside the quiet hours when those
+are set.`,
+	RunE: runScheduleEnable,
+}
+
+var scheduleDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Unload and remove the update agent",
+	RunE:  runScheduleDisable,
+}
+
+var scheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the update agent is installed and loaded",
+	RunE:  runScheduleStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleEnableCmd)
+	scheduleCmd.AddCommand(scheduleDisableCmd)
+	scheduleCmd.AddCommand(scheduleStatusCmd)
+
+	scheduleEnableCmd.Flags().BoolVar(&scheduleUpdate, "update", false, "schedule setup-mac update --all")
+	scheduleEnableCmd.Flags().StringVar(&scheduleInterval, "interval", schedule.Weekly, "how often to run (daily, weekly)")
+	scheduleEnableCmd.Flags().StringVar(&scheduleAt, "at", "10:00", "time of day to run (HH:MM)")
+	scheduleEnableCmd.Flags().StringVar(&scheduleWeekday, "weekday", "mon", "day of weekly runs")
+	scheduleEnableCmd.Flags().StringVar(&scheduleQuietHours, "quiet-hours", "", "skip runs in this window, e.g. 22:00-07:00")
+	scheduleEnableCmd.Flags().BoolVar(&scheduleSkipOnBattery, "skip-on-battery", false, "skip runs on battery power")
+	scheduleEnableCmd.Flags().StringVar(&scheduleLog, "log", scheduleLogFile, "log file")
+	scheduleEnableCmd.Flags().BoolVarP(&scheduleDryRun, "dry-run", "n", false, "print the plist without loading it")
+
+	scheduleDisableCmd.Flags().BoolVarP(&scheduleDryRun, "dry-run", "n", false, "show what would be done without making changes")
+
+	scheduleStatusCmd.Flags().BoolVar(&scheduleJSON, "json", false, "output as JSON")
+}
+
+func runScheduleEnable(cmd *cobra.Command, args []string) error {
+	if !scheduleUpdate {
+		return fmt.Errorf("nothing to schedule: use --update")
+	}
+
+	job, err := updateJob()
+	if err != nil {
+		return err
+	}
+
+	if scheduleDryRun {
+		data, err := schedule.Plist(job)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		fmt.Println()
+	}
+
+	exec := executor.New(scheduleDryRun, verbose)
+	path, err := schedule.Enable(context.Background(), exec, job)
+	if err != nil {
+		return err
+	}
+	if scheduleDryRun {
+		return nil
+	}
+
+	when := fmt.Sprintf("daily at %s", scheduleAt)
+	if job.Interval == schedule.Weekly {
+		when = fmt.Sprintf("every %s at %s", job.Weekday, scheduleAt)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Scheduled setup-mac update %s", when))
+	fmt.Printf("  Agent: %s\n", path)
+	fmt.Printf("  Log:   %s\n", job.LogFile)
+	return nil
+}
+
+// updateJob builds the launchd job from the flags
+func updateJob() (schedule.Job, error) {
+	hour, minute, err := schedule.ParseClock(scheduleAt)
+	if err != nil {
+		return schedule.Job{}, err
+	}
+	weekday, err := schedule.ParseWeekday(scheduleWeekday)
+	if err != nil {
+		return schedule.Job{}, err
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return schedule.Job{}, fmt.Errorf("failed to find the setup-mac binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	// Replacing setup-mac itself is left to a person running update --self
+	program := []string{binary, "update", "--all", "--exclude-component", "self", "--non-interactive", "--skip-update-check"}
+	if scheduleSkipOnBattery {
+		program = append(program, "--skip-on-battery")
+	}
+	if scheduleQuietHours != "" {
+		quiet, err := schedule.ParseQuietHours(scheduleQuietHours)
+		if err != nil {
+			return schedule.Job{}, err
+		}
+		if quiet.Contains(time.Date(2000, 1, 1, hour, minute, 0, 0, time.Local)) {
+			return schedule.Job{}, fmt.Errorf("--at %s is inside the quiet hours %s", scheduleAt, quiet)
+		}
+		program = append(program, "--quiet-hours", quiet.String())
+	}
+	// Use the same config as the command that set up the schedule
+	if cfgFile != "" {
+		path, err := filepath.Abs(cfgFile)
+		if err != nil {
+			return schedule.Job{}, err
+		}
+		program = append(program, "--config", path)
+	}
+	if profile != "" {
+		program = append(program, "--profile", profile)
+	}
+	if policyFile != "" {
+		path, err := filepath.Abs(policyFile)
+		if err != nil {
+			return schedule.Job{}, err
+		}
+		program = append(program, "--policy", path)
+	}
+
+	logFile := scheduleLog
+	if homeDir, err := os.UserHomeDir(); err == nil && strings.HasPrefix(logFile, "~/") {
+		logFile = filepath.Join(homeDir, logFile[2:])
+	}
+
+	job := schedule.Job{
+		Label:    schedule.Label,
+		Program:  program,
+		Interval: scheduleInterval,
+		Weekday:  weekday,
+		Hour:     hour,
+		Minute:   minute,
+		LogFile:  logFile,
+		Path:     schedulePath,
+	}
+	return job, job.Validate()
+}
+
+func runScheduleDisable(cmd *cobra.Command, args []string) error {
+	exec := executor.New(scheduleDryRun, verbose)
+	if err := schedule.Disable(context.Background(), exec, schedule.Label); err != nil {
+		return err
+	}
+	if !scheduleDryRun {
+		ui.PrintSuccess("Scheduled updates disabled")
+	}
+	return nil
+}
+
+func runScheduleStatus(cmd *cobra.Command, args []string) error {
+	status := schedule.GetStatus(context.Background(), executor.New(false, verbose), schedule.Label)
+
+	if scheduleJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	color.New(color.FgCyan, color.Bold).Println("Scheduled Updates")
+	fmt.Println("──────────────────────────────────────")
+	switch {
+	case status.Loaded:
+		color.New(color.FgGreen).Print("  ✓ ")
+		fmt.Println("enabled")
+	case status.Installed:
+		color.New(color.FgYellow).Print("  ⚠ ")
+		fmt.Println("installed but not loaded (run setup-mac schedule enable --update again)")
+	default:
+		color.New(color.FgRed).Print("  ✗ ")
+		fmt.Println("disabled")
+		return nil
+	}
+
+	fmt.Printf("  Agent:          %s\n", status.Plist)
+	if status.State != "" {
+		fmt.Printf("  State:          %s\n", status.State)
+	}
+	if status.LastExitCode != "" {
+		fmt.Printf("  Last exit code: %s\n", status.LastExitCode)
+	}
+	if status.LogFile != "" {
+		fmt.Printf("  Log:            %s\n", status.LogFile)
+	}
+	return nil
+}
@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"runtime"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/system"
 )
 
 var jsonOutput bool
@@ -42,23 +41,27 @@ type ComponentStatus struct {
 	Installed   bool   `json:"installed"`
 }
 
+// ServiceStatus compares a configured Homebrew service with its state
+type ServiceStatus struct {
+	Name    string `json:"name"`
+	Desired string `json:"desired"`
+	Actual  string `json:"actual"`
+}
+
 // SystemStatus represents the overall system status
 type SystemStatus struct {
-	System     SystemInfo        `json:"system"`
+	System     system.Info       `json:"system"`
 	Components []ComponentStatus `json:"components"`
-}
-
-// SystemInfo contains system information
-type SystemInfo struct {
-	OS           string `json:"os"`
-	Arch         string `json:"arch"`
-	AppleSilicon bool   `json:"apple_silicon"`
-	MacOSVersion string `json:"macos_version,omitempty"`
+	Services   []ServiceStatus   `json:"services,omitempty"`
+	// Runtimes compares the versions in runtimes.tools with the installed ones
+	Runtimes []installer.RuntimeStatus `json:"runtimes,omitempty"`
+	// Shellenv is set when Homebrew is installed and homebrew.shellenv_file is set
+	Shellenv *installer.ShellenvStatus `json:"shellenv,omitempty"`
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
 	// Load configuration (to get proper context)
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -68,20 +71,27 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
 	// Get system info
-	sysInfo := getSystemInfo(ctx, ictx)
+	sysInfo := system.Detect(ctx, ictx.Executor)
 
 	// Check all installers
 	installers := []installer.Installer{
 		installer.NewXcodeInstaller(ictx),
 		installer.NewRosettaInstaller(ictx),
 		installer.NewHomebrewInstaller(ictx),
+		installer.NewMasInstaller(ictx),
+		installer.NewRuntimesInstaller(ictx),
+	}
+	installers = append(installers, packageInstallers(ictx)...)
+	installers = append(installers,
+		installer.NewVSCodeInstaller(ictx),
+		installer.NewDownloadsInstaller(ictx),
 		installer.NewOhMyZshInstaller(ictx),
 		installer.NewPowerlevel10kInstaller(ictx),
 		installer.NewShellInstaller(ictx),
 		installer.NewMacOSInstaller(ictx),
 		installer.NewGitInstaller(ictx),
 		installer.NewSSHInstaller(ictx),
-	}
+	)
 
 	var components []ComponentStatus
 	for _, inst := range installers {
@@ -97,6 +107,14 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	status := SystemStatus{
 		System:     sysInfo,
 		Components: components,
+		Services:   serviceStatuses(ctx, ictx),
+	}
+	if cfg.Runtimes.Manager != "" {
+		status.Runtimes = installer.RuntimeStatuses(ctx, ictx.Executor, cfg.Runtimes)
+	}
+	if file := cfg.Homebrew.ShellenvFile; file != "" && ictx.Executor.Exists("brew") {
+		shellenv := installer.CheckShellenv(file)
+		status.Shellenv = &shellenv
 	}
 
 	if jsonOutput {
@@ -106,20 +124,27 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	return outputHuman(status)
 }
 
-func getSystemInfo(ctx context.Context, ictx *installer.Context) SystemInfo {
-	info := SystemInfo{
-		OS:           runtime.GOOS,
-		Arch:         runtime.GOARCH,
-		AppleSilicon: runtime.GOARCH == "arm64",
+// serviceStatuses reports the configured Homebrew services
+func serviceStatuses(ctx context.Context, ictx *installer.Context) []ServiceStatus {
+	services := ictx.Config.Homebrew.Services
+	if len(services) == 0 || !ictx.Executor.Exists("brew") {
+		return nil
 	}
 
-	// Get macOS version
-	result, err := ictx.Executor.Run(ctx, "sw_vers", "-productVersion")
-	if err == nil {
-		info.MacOSVersion = strings.TrimSpace(result.Stdout)
+	actual, err := installer.LoadBrewServices(ctx, ictx.Executor)
+	if err != nil {
+		return nil
 	}
 
-	return info
+	var statuses []ServiceStatus
+	for _, s := range services {
+		status := ServiceStatus{Name: s.Name, Desired: installer.DesiredState(s), Actual: "not installed"}
+		if have, ok := actual[s.Name]; ok {
+			status.Actual = installer.ServiceState(have)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
 }
 
 func outputJSON(status SystemStatus) error {
@@ -138,7 +163,10 @@ func outputHuman(status SystemStatus) error {
 		fmt.Printf("  Apple Silicon: %s\n", color.GreenString("Yes"))
 	}
 	if status.System.MacOSVersion != "" {
-		fmt.Printf("  macOS Version: %s", status.System.MacOSVersion)
+		fmt.Printf("  macOS Version: %s\n", status.System.MacOSVersion)
+	}
+	if status.System.Hostname != "" {
+		fmt.Printf("  Hostname:      %s\n", status.System.Hostname)
 	}
 	fmt.Println()
 
@@ -166,5 +194,49 @@ func outputHuman(status SystemStatus) error {
 	fmt.Println()
 	fmt.Printf("  %d/%d components installed\n", installed, len(status.Components))
 
+	if status.Shellenv != nil {
+		if status.Shellenv.Configured {
+			color.New(color.FgGreen).Print("  ✓ ")
+			fmt.Printf("brew shellenv loaded from %s\n", status.Shellenv.File)
+		} else {
+			color.New(color.FgYellow).Print("  ⚠ ")
+			fmt.Printf("%s\n", status.Shellenv.Problem)
+		}
+	}
+
+	if len(status.Services) > 0 {
+		fmt.Println()
+		color.New(color.FgCyan, color.Bold).Println("Services")
+		fmt.Println("──────────────────────────────────────")
+		for _, s := range status.Services {
+			if s.Actual == s.Desired {
+				color.New(color.FgGreen).Print("  ✓ ")
+				fmt.Printf("%-20s %s\n", s.Name, s.Actual)
+			} else {
+				color.New(color.FgYellow).Print("  ⚠ ")
+				fmt.Printf("%-20s %s (want %s)\n", s.Name, s.Actual, s.Desired)
+			}
+		}
+	}
+
+	if len(status.Runtimes) > 0 {
+		fmt.Println()
+		color.New(color.FgCyan, color.Bold).Println("Runtimes")
+		fmt.Println("──────────────────────────────────────")
+		for _, r := range status.Runtimes {
+			installed := strings.Join(r.Installed, ", ")
+			if installed == "" {
+				installed = "none"
+			}
+			if len(r.Missing) == 0 {
+				color.New(color.FgGreen).Print("  ✓ ")
+				fmt.Printf("%-20s %s\n", r.Tool, strings.Join(r.Declared, ", "))
+			} else {
+				color.New(color.FgYellow).Print("  ⚠ ")
+				fmt.Printf("%-20s %s missing (installed: %s)\n", r.Tool, strings.Join(r.Missing, ", "), installed)
+			}
+		}
+	}
+
 	return nil
 }
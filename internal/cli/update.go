@@ -2,23 +2,44 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/schedule"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/system"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
 var (
 	updateAll      bool
-	updateHomebrew bool
-	updateOhMyZsh  bool
+	updateList     bool
 	updateDryRun   bool
+	updateOnly     []string
+	updateExclude  []string
+	updateGreedy   bool
+	updatePick     bool
+	updateJSON     bool
+	updateMarkdown bool
+	updateOutput   string
+
+	updateNonInteractive bool
+	updateSkipOnBattery  bool
+	updateQuietHours     string
+
+	// updateExcludeComponents are left out of --all, e.g. self
+	updateExcludeComponents []string
+
+	// updateComponents holds the per-updater flags, e.g. --homebrew
+	updateComponents = make(map[string]*bool)
 )
 
 var updateCmd = &cobra.Command{
@@ -32,7 +53,25 @@ Examples:
 
   # Update specific components
   setup-mac update --homebrew
-  setup-mac update --ohmyzsh
+  setup-mac update --mas
+  setup-mac update --ohmyzsh --plugins --powerlevel10k
+  setup-mac update --self
+
+  # Show what can be updated
+  setup-mac update --list
+
+  # Upgrade only some packages, or leave some alone
+  setup-mac update --homebrew --only 'node*' --only git
+  setup-mac update --homebrew --exclude docker --exclude 'postgresql@*'
+
+  # Choose from the outdated packages
+  setup-mac update --homebrew --pick
+
+  # Write the change report for a ticket
+  setup-mac update --all --markdown --output changes.md
+
+  # Everything but setup-mac itself
+  setup-mac update --all --exclude-component self
 
   # Dry-run mode
   setup-mac update --all --dry-run`,
@@ -43,19 +82,44 @@ func init() {
 	rootCmd.AddCommand(updateCmd)
 
 	updateCmd.Flags().BoolVarP(&updateDryRun, "dry-run", "n", false, "show what would be done without making changes")
-	updateCmd.Flags().BoolVarP(&updateAll, "all", "a", false, "update all components")
-	updateCmd.Flags().BoolVar(&updateHomebrew, "homebrew", false, "update Homebrew and packages")
-	updateCmd.Flags().BoolVar(&updateOhMyZsh, "ohmyzsh", false, "update Oh My Zsh")
-}
+	updateCmd.Flags().BoolVarP(&updateAll, "all", "a", false, "update all installed components")
+	updateCmd.Flags().BoolVar(&updateList, "list", false, "list the components that can be updated")
 
-// Updater interface for components that support updating
-type Updater interface {
-	Name() string
-	Description() string
-	Update(ctx context.Context) error
+	// setup-mac itself, registered here because the version lives in cli
+	installer.DefaultUpdaters.Register("self", func(ctx *installer.Context) installer.Updater {
+		return installer.NewSelfUpdater(ctx, Version)
+	})
+
+	// One flag per registered updater
+	for _, name := range installer.DefaultUpdaters.Names() {
+		updater, _ := installer.DefaultUpdaters.Get(name, &installer.Context{})
+		updateComponents[name] = updateCmd.Flags().Bool(name, false, "update "+updater.Description())
+	}
+	updateCmd.Flags().StringSliceVar(&updateOnly, "only", nil, "upgrade only these Homebrew packages (globs)")
+	updateCmd.Flags().StringSliceVar(&updateExclude, "exclude", nil, "do not upgrade these Homebrew packages (globs)")
+	updateCmd.Flags().StringSliceVar(&updateExcludeComponents, "exclude-component", nil, "leave these components out of --all, e.g. self")
+	updateCmd.Flags().BoolVar(&updateGreedy, "greedy", false, "also upgrade casks that update themselves")
+	updateCmd.Flags().BoolVar(&updatePick, "pick", false, "choose from the outdated Homebrew packages")
+	updateCmd.Flags().BoolVar(&updateJSON, "json", false, "print the change report as JSON")
+	updateCmd.Flags().BoolVar(&updateMarkdown, "markdown", false, "print the change report as Markdown")
+	updateCmd.Flags().StringVarP(&updateOutput, "output", "o", "", "write the change report to a file instead")
+	updateCmd.Flags().BoolVar(&updateNonInteractive, "non-interactive", false, "never prompt, e.g. when run by launchd")
+	updateCmd.Flags().BoolVar(&updateSkipOnBattery, "skip-on-battery", false, "do nothing when running on battery power")
+	updateCmd.Flags().StringVar(&updateQuietHours, "quiet-hours", "", "do nothing inside this window, e.g. 22:00-07:00")
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
+	if updateList {
+		return runUpdateList()
+	}
+
+	// Keep stdout for the JSON report, everything else goes to stderr
+	reportOut := os.Stdout
+	if updateJSON && updateOutput == "" {
+		restore := stdoutToStderr()
+		defer restore()
+	}
+
 	printBanner()
 
 	// Check if running as root/sudo
@@ -64,19 +128,36 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load configuration
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Override dry-run from flags
+	// Override dry-run, prompts and package selection from flags
 	if updateDryRun {
 		cfg.Settings.DryRun = true
 	}
+	if updateNonInteractive {
+		cfg.Settings.Interactive = false
+	}
+	cfg.Homebrew.Update.Exclude = append(cfg.Homebrew.Update.Exclude, updateExclude...)
+	if updateGreedy {
+		cfg.Homebrew.Update.Greedy = true
+	}
 
 	// Create installer context
 	ictx := installer.NewContext(cfg, cfg.Settings.DryRun, verbose)
 
+	// Scheduled runs wait for a better moment
+	reason, err := skipScheduledUpdate(ictx)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		ui.PrintInfo(fmt.Sprintf("Skipping update: %s", reason))
+		return nil
+	}
+
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -93,7 +174,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	updaters := determineUpdaters(ictx)
 
 	if len(updaters) == 0 {
-		ui.PrintWarning("No components selected. Use --all or specific flags like --homebrew, --ohmyzsh")
+		ui.PrintWarning("No components selected. Use --all or component flags like --homebrew (see update --list)")
 		return nil
 	}
 
@@ -111,6 +192,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 
 	// Run updaters
 	var errors []error
+	var reports []*installer.UpdateReport
 	for i, updater := range updaters {
 		select {
 		case <-ctx.Done():
@@ -121,7 +203,18 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 			fmt.Println(updater.Description())
 			fmt.Println("──────────────────────────────────────")
 
-			if err := updater.Update(ctx); err != nil {
+			start := time.Now()
+			report, err := updater.Update(ctx)
+			if report == nil {
+				report = &installer.UpdateReport{}
+			}
+			report.Component = updater.Name()
+			report.DryRun = cfg.Settings.DryRun
+			report.Duration = time.Since(start)
+			reports = append(reports, report)
+
+			if err != nil {
+				report.Error = err.Error()
 				errors = append(errors, fmt.Errorf("%s: %w", updater.Name(), err))
 				ui.PrintError(fmt.Sprintf("Failed to update %s: %v", updater.Name(), err))
 			}
@@ -129,6 +222,10 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if err := outputReport(reportOut, reports); err != nil {
+		return err
+	}
+
 	// Print summary
 	if len(errors) > 0 {
 		color.New(color.FgYellow).Println("Update completed with errors:")
@@ -142,22 +239,138 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func determineUpdaters(ictx *installer.Context) []Updater {
-	var updaters []Updater
+// skipScheduledUpdate returns why --quiet-hours or --skip-on-battery
+// rule out running now, or "" to go ahead
+func skipScheduledUpdate(ictx *installer.Context) (string, error) {
+	if updateQuietHours != "" {
+		quiet, err := schedule.ParseQuietHours(updateQuietHours)
+		if err != nil {
+			return "", err
+		}
+		if quiet.Contains(time.Now()) {
+			return fmt.Sprintf("inside the quiet hours %s", quiet), nil
+		}
+	}
+
+	// Without a battery (or pmset) there is nothing to wait for
+	if updateSkipOnBattery {
+		if onBattery, err := system.OnBattery(context.Background(), ictx.Executor); err == nil && onBattery {
+			return "running on battery power", nil
+		}
+	}
+	return "", nil
+}
+
+// outputReport prints the summary table, or the JSON or Markdown report to
+// out or --output
+func outputReport(out io.Writer, reports []*installer.UpdateReport) error {
+	var content string
+	switch {
+	case updateJSON:
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		content = string(data) + "\n"
+	case updateMarkdown:
+		content = installer.RenderMarkdown(reports)
+	default:
+		printReportTable(reports)
+		return nil
+	}
 
-	if updateAll {
-		updaters = append(updaters, installer.NewHomebrewUpdater(ictx))
-		updaters = append(updaters, installer.NewOhMyZshUpdater(ictx))
-		return updaters
+	if updateOutput == "" {
+		fmt.Fprint(out, content)
+		if !updateJSON {
+			fmt.Fprintln(out)
+		}
+		return nil
+	}
+	if err := os.WriteFile(updateOutput, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
 	}
+	ui.PrintSuccess(fmt.Sprintf("Report written to %s", updateOutput))
+	return nil
+}
 
-	if updateHomebrew {
-		updaters = append(updaters, installer.NewHomebrewUpdater(ictx))
+// printReportTable prints what each updater changed
+func printReportTable(reports []*installer.UpdateReport) {
+	color.New(color.FgCyan, color.Bold).Println("Changes")
+	fmt.Println("──────────────────────────────────────")
+
+	for _, r := range reports {
+		fmt.Printf("  %-20s %s\n", r.Component, color.New(color.Faint).Sprint(r.Duration.Round(time.Second)))
+		if r.Changes() == 0 && len(r.Skipped) == 0 {
+			fmt.Println("    no changes")
+		}
+		for _, p := range r.Upgraded {
+			color.New(color.FgGreen).Print("    ↑ ")
+			fmt.Printf("%-28s %s → %s\n", p.Name, p.From, p.To)
+		}
+		for _, repo := range r.Repos {
+			color.New(color.FgGreen).Print("    ↑ ")
+			fmt.Printf("%-28s %s (%d commits)\n", repo.Name, repo.Range(), repo.Commits)
+		}
+		for _, s := range r.Skipped {
+			color.New(color.FgYellow).Print("    - ")
+			fmt.Printf("%-28s %s\n", s.Name, s.Reason)
+		}
 	}
+	fmt.Println()
+}
 
-	if updateOhMyZsh {
-		updaters = append(updaters, installer.NewOhMyZshUpdater(ictx))
+// stdoutToStderr sends everything printed to stdout, including colored and
+// spinner output, to stderr until the returned func is called
+func stdoutToStderr() (restore func()) {
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = os.Stderr, color.Error
+	return func() {
+		os.Stdout, color.Output = stdout, colorOutput
 	}
+}
 
+// determineUpdaters returns the updaters selected by the flags, in
+// registry order. --all selects the installed ones.
+func determineUpdaters(ictx *installer.Context) []installer.Updater {
+	var updaters []installer.Updater
+	for _, updater := range installer.DefaultUpdaters.GetAll(ictx) {
+		selected := *updateComponents[updater.Name()]
+		if homebrew, ok := updater.(*installer.HomebrewUpdater); ok {
+			homebrew.Only = updateOnly
+			homebrew.Pick = updatePick
+			selected = selected || len(updateOnly) > 0 || updatePick
+		}
+		if updateAll && !slices.Contains(updateExcludeComponents, updater.Name()) && updater.IsInstalled(context.Background()) {
+			selected = true
+		}
+		if selected {
+			updaters = append(updaters, updater)
+		}
+	}
 	return updaters
 }
+
+// runUpdateList prints the registered updaters and whether each has
+// anything installed to update
+func runUpdateList() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	ictx := installer.NewContext(cfg, true, verbose)
+
+	color.New(color.FgCyan, color.Bold).Println("Updatable Components")
+	fmt.Println("──────────────────────────────────────")
+	for _, updater := range installer.DefaultUpdaters.GetAll(ictx) {
+		if updater.IsInstalled(context.Background()) {
+			color.New(color.FgGreen).Print("  ✓ ")
+			fmt.Printf("%-16s %s\n", "--"+updater.Name(), updater.Description())
+		} else {
+			color.New(color.FgRed).Print("  ✗ ")
+			fmt.Printf("%-16s %s %s\n", "--"+updater.Name(), updater.Description(), color.New(color.Faint).Sprint("(not installed)"))
+		}
+	}
+	fmt.Println()
+	fmt.Println("update --all runs the installed ones.")
+	return nil
+}
@@ -1,13 +1,22 @@
 package cli
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/installer"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/secrets"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/version"
 )
 
 var validateCmd = &cobra.Command{
@@ -41,6 +50,12 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	} else {
 		fmt.Println("Validating embedded default config")
 	}
+	if profile != "" {
+		fmt.Printf("Profile: %s\n", profile)
+	}
+	if path := config.FindPolicy(policyFile); path != "" {
+		fmt.Printf("Policy: %s\n", path)
+	}
 	fmt.Println()
 
 	// Check if config file exists (for custom configs)
@@ -52,7 +67,12 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Try to load the config
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
+	var policyErr *config.PolicyError
+	if errors.As(err, &policyErr) {
+		printPolicyViolations(policyErr)
+		return fmt.Errorf("validation failed with %d policy violation(s)", len(policyErr.Violations))
+	}
 	if err != nil {
 		color.New(color.FgRed).Printf("✗ Configuration invalid: %v\n", err)
 		return fmt.Errorf("validation failed")
@@ -79,30 +99,199 @@ func validateConfig(cfg *config.Config) ValidationResult {
 		// Check for duplicate formulae
 		seen := make(map[string]bool)
 		for _, formula := range cfg.Homebrew.Formulae {
-			if seen[formula] {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate formula: %s", formula))
+			if seen[formula.Name] {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate formula: %s", formula.Name))
+			}
+			seen[formula.Name] = true
+
+			if formula.Name == "" {
+				result.Errors = append(result.Errors, "Formula entry without a name")
+				result.Valid = false
+			}
+			validateArgs(&result, "formula", formula.Name, formula.Args)
+			if formula.VersionConstraint != "" {
+				if _, err := version.Satisfies("0", formula.VersionConstraint); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("Invalid version_constraint for %s: %v", formula.Name, err))
+					result.Valid = false
+				}
 			}
-			seen[formula] = true
 		}
 
 		// Check for duplicate casks
 		seen = make(map[string]bool)
 		for _, cask := range cfg.Homebrew.Casks {
-			if seen[cask] {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate cask: %s", cask))
+			if seen[cask.Name] {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate cask: %s", cask.Name))
+			}
+			seen[cask.Name] = true
+
+			if cask.Name == "" {
+				result.Errors = append(result.Errors, "Cask entry without a name")
+				result.Valid = false
+			}
+			validateArgs(&result, "cask", cask.Name, cask.Args)
+		}
+
+		// Check prune ignore and update exclude globs
+		for _, pattern := range cfg.Homebrew.PruneIgnore {
+			if _, err := path.Match(pattern, ""); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid prune_ignore pattern %q: %v", pattern, err))
+				result.Valid = false
+			}
+		}
+		for _, pattern := range cfg.Homebrew.Update.Exclude {
+			if _, err := path.Match(pattern, ""); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid update.exclude pattern %q: %v", pattern, err))
+				result.Valid = false
+			}
+		}
+
+		// Check App Store apps
+		seenIDs := make(map[int]bool)
+		for _, app := range cfg.Homebrew.MasApps {
+			if app.ID <= 0 {
+				result.Errors = append(result.Errors, fmt.Sprintf("App Store app %q needs a numeric id", app.Name))
+				result.Valid = false
+				continue
+			}
+			if seenIDs[app.ID] {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate App Store app: %d", app.ID))
+			}
+			seenIDs[app.ID] = true
+		}
+
+		// Check services
+		seen = make(map[string]bool)
+		for _, service := range cfg.Homebrew.Services {
+			if seen[service.Name] {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate service: %s", service.Name))
+			}
+			seen[service.Name] = true
+
+			if service.Name == "" {
+				result.Errors = append(result.Errors, "Service entry without a name")
+				result.Valid = false
+			}
+			if service.State != "" && service.State != config.ServiceStarted && service.State != config.ServiceStopped {
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid state for service %s: %s (valid: started, stopped)", service.Name, service.State))
+				result.Valid = false
 			}
-			seen[cask] = true
 		}
 
 		// Check for duplicate taps
 		seen = make(map[string]bool)
 		for _, tap := range cfg.Homebrew.Taps {
-			if seen[tap] {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate tap: %s", tap))
+			if seen[tap.Name] {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Duplicate tap: %s", tap.Name))
+			}
+			seen[tap.Name] = true
+
+			if user, repo, ok := strings.Cut(tap.Name, "/"); !ok || user == "" || repo == "" || strings.Contains(repo, "/") {
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid tap name %q (expected user/repo)", tap.Name))
+				result.Valid = false
 			}
-			seen[tap] = true
+			if tap.URL != "" && !strings.Contains(tap.URL, "://") && !strings.HasPrefix(tap.URL, "git@") && !strings.HasPrefix(tap.URL, "/") {
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid URL for tap %s: %s", tap.Name, tap.URL))
+				result.Valid = false
+			}
+		}
+
+		validateInstallScript(&result, "homebrew.install_script", cfg.Homebrew.InstallScript)
+
+		// Check the Brewfile parses
+		if cfg.Homebrew.Brewfile != "" {
+			packages, err := installer.BrewPackages(cfg.Homebrew)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				result.Valid = false
+			} else {
+				for _, warning := range packages.Warnings {
+					result.Warnings = append(result.Warnings, fmt.Sprintf("Brewfile: %s", warning))
+				}
+			}
+		}
+	}
+
+	// Validate Oh My Zsh config
+	if cfg.Terminal.OhMyZsh.Install {
+		validateInstallScript(&result, "terminal.oh_my_zsh.install_script", cfg.Terminal.OhMyZsh.InstallScript)
+	}
+	switch policy := cfg.Terminal.OhMyZsh.Update.LocalChanges; policy {
+	case config.LocalChangesStash, config.LocalChangesSkip, config.LocalChangesAbort:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("Invalid terminal.oh_my_zsh.update.local_changes: %q (valid: stash, skip, abort)", policy))
+		result.Valid = false
+	}
+
+	// Validate runtimes
+	switch cfg.Runtimes.Manager {
+	case "", config.RuntimeManagerMise, config.RuntimeManagerAsdf:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("Invalid runtimes.manager: %q (valid: mise, asdf)", cfg.Runtimes.Manager))
+		result.Valid = false
+	}
+	for tool, versions := range cfg.Runtimes.Tools {
+		if len(versions) == 0 || slices.Contains(versions, "") {
+			result.Errors = append(result.Errors, fmt.Sprintf("runtimes.tools.%s needs a version", tool))
+			result.Valid = false
 		}
 	}
+	if len(cfg.Runtimes.Tools) > 0 && cfg.Runtimes.Manager == "" {
+		result.Warnings = append(result.Warnings, "runtimes.tools is set but runtimes.manager is empty, nothing will be installed")
+	}
+
+	// Validate global packages
+	for ecosystem, entries := range cfg.Packages {
+		if !slices.Contains(installer.PackageEcosystems, ecosystem) {
+			result.Errors = append(result.Errors, fmt.Sprintf("Unknown packages ecosystem: %q (valid: %s)", ecosystem, strings.Join(installer.PackageEcosystems, ", ")))
+			result.Valid = false
+			continue
+		}
+		for _, entry := range entries {
+			spec := installer.ParsePackageSpec(entry)
+			switch {
+			case spec.Name == "" || strings.HasSuffix(entry, "@"):
+				result.Errors = append(result.Errors, fmt.Sprintf("Invalid packages.%s entry: %q", ecosystem, entry))
+				result.Valid = false
+			case ecosystem == config.PackagesGo && !strings.Contains(spec.Name, "/"):
+				result.Errors = append(result.Errors, fmt.Sprintf("packages.go entry %q must be a package path, e.g. golang.org/x/tools/gopls", entry))
+				result.Valid = false
+			}
+		}
+	}
+
+	// Validate editor config
+	switch cfg.VSCode.Editor {
+	case "", config.EditorCode, config.EditorCursor, config.EditorCodium:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("Invalid vscode.editor: %q (valid: code, cursor, codium)", cfg.VSCode.Editor))
+		result.Valid = false
+	}
+	for _, entry := range cfg.VSCode.Extensions {
+		id := installer.ParsePackageSpec(entry).Name
+		if publisher, name, ok := strings.Cut(id, "."); !ok || publisher == "" || name == "" || strings.HasSuffix(entry, "@") {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid vscode extension %q (expected publisher.name)", entry))
+			result.Valid = false
+		}
+	}
+	if cfg.VSCode.Editor == "" && (len(cfg.VSCode.Extensions) > 0 || len(cfg.VSCode.Settings) > 0) {
+		result.Warnings = append(result.Warnings, "vscode extensions or settings are set but vscode.editor is empty, nothing will be installed")
+	}
+
+	// Validate downloads
+	names := make(map[string]bool)
+	for i, download := range cfg.Downloads {
+		key := fmt.Sprintf("downloads[%d]", i)
+		if download.Name == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s.name must be set", key))
+			result.Valid = false
+		} else if names[download.Name] {
+			result.Errors = append(result.Errors, fmt.Sprintf("Duplicate download name: %s", download.Name))
+			result.Valid = false
+		}
+		names[download.Name] = true
+		validateDownload(&result, key, download)
+	}
 
 	// Validate Git config
 	if cfg.Git.Configure {
@@ -135,9 +324,107 @@ func validateConfig(cfg *config.Config) ValidationResult {
 		}
 	}
 
+	// Validate secret references (without resolving them)
+	resolver := secrets.NewManager(nil)
+	hasSecrets := false
+	for name, value := range cfg.Shell.Environment {
+		if err := resolver.Validate(value); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("shell.environment.%s: %v", name, err))
+			result.Valid = false
+		}
+		hasSecrets = hasSecrets || secrets.IsRef(value)
+	}
+	for key, value := range cfg.Git.Settings {
+		if err := resolver.Validate(value); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("git.settings.%s: %v", key, err))
+			result.Valid = false
+		}
+	}
+	if hasSecrets && cfg.Shell.SecretsFile == "" {
+		result.Errors = append(result.Errors, "shell.secrets_file must be set to use secret references in shell.environment")
+		result.Valid = false
+	}
+
 	return result
 }
 
+// validateArgs checks that extra brew install arguments are flags
+func validateArgs(result *ValidationResult, kind, name string, args []string) {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid args for %s %s: %q is not a --flag", kind, name, arg))
+			result.Valid = false
+		}
+	}
+}
+
+// validateDownload checks the url, checksums, archive type and mode of a
+// download
+func validateDownload(result *ValidationResult, key string, download config.Download) {
+	if download.URL == "" {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s.url must be set", key))
+		result.Valid = false
+	} else if _, err := installer.DownloadURL(download.URL, "darwin", "arm64"); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s.url: %v", key, err))
+		result.Valid = false
+	}
+
+	if len(download.SHA256) == 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s.sha256 must be set", key))
+		result.Valid = false
+	}
+	for _, arch := range slices.Sorted(maps.Keys(download.SHA256)) {
+		sum := download.SHA256[arch]
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.sha256 for %s: %s (expected 64 hex characters)", key, arch, sum))
+			result.Valid = false
+		}
+	}
+
+	switch download.Archive {
+	case "", config.ArchiveTarGz, config.ArchiveZip, config.ArchiveNone:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.archive: %s (valid: tar.gz, zip, none)", key, download.Archive))
+		result.Valid = false
+	}
+	for _, pattern := range download.Files {
+		if _, err := path.Match(pattern, ""); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.files pattern: %s", key, pattern))
+			result.Valid = false
+		}
+	}
+	if download.Mode != "" {
+		if mode, err := strconv.ParseUint(string(download.Mode), 8, 32); err != nil || mode > 0777 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.mode: %s (expected octal, e.g. 0755)", key, download.Mode))
+			result.Valid = false
+		}
+	}
+}
+
+// validateInstallScript checks the source and checksum of an install script
+func validateInstallScript(result *ValidationResult, key string, script config.InstallScript) {
+	switch script.Source {
+	case config.ScriptDownload, "":
+		if script.URL == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s.url must be set to download the script", key))
+			result.Valid = false
+		}
+	case config.ScriptVendored:
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.source: %s (valid: download, vendored)", key, script.Source))
+		result.Valid = false
+	}
+
+	if script.SHA256 != "" {
+		if _, err := hex.DecodeString(script.SHA256); err != nil || len(script.SHA256) != 64 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Invalid %s.sha256: %s (expected 64 hex characters)", key, script.SHA256))
+			result.Valid = false
+		}
+	} else if !installer.ScriptPinned(script) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s is not verified: set sha256 or pin commit to a full SHA", key))
+	}
+}
+
 func printValidationResult(result ValidationResult, cfg *config.Config) {
 	// Print config summary
 	color.New(color.FgCyan, color.Bold).Println("Configuration Summary")
@@ -145,11 +432,20 @@ func printValidationResult(result ValidationResult, cfg *config.Config) {
 
 	// Homebrew
 	if cfg.Homebrew.Install {
+		formulae, casks, taps := len(cfg.Homebrew.Formulae), len(cfg.Homebrew.Casks), len(cfg.Homebrew.Taps)
+		apps := len(cfg.Homebrew.MasApps)
+		if packages, err := installer.BrewPackages(cfg.Homebrew); err == nil {
+			formulae, casks, taps = len(packages.Brews), len(packages.Casks), len(packages.Taps)
+			apps = len(packages.Mas)
+		}
 		fmt.Printf("  Homebrew:     %s (%d formulae, %d casks, %d taps)\n",
 			color.GreenString("enabled"),
-			len(cfg.Homebrew.Formulae),
-			len(cfg.Homebrew.Casks),
-			len(cfg.Homebrew.Taps))
+			formulae,
+			casks,
+			taps)
+		if apps > 0 {
+			fmt.Printf("  App Store:    %d apps\n", apps)
+		}
 	} else {
 		fmt.Printf("  Homebrew:     %s\n", color.YellowString("disabled"))
 	}
@@ -233,3 +529,14 @@ func printValidationResult(result ValidationResult, cfg *config.Config) {
 		color.New(color.FgRed, color.Bold).Println("✗ Configuration has errors")
 	}
 }
+
+func printPolicyViolations(err *config.PolicyError) {
+	color.New(color.FgRed, color.Bold).Println("Policy Violations")
+	fmt.Println("──────────────────────────────────────")
+	for _, v := range err.Violations {
+		color.New(color.FgRed).Printf("  ✗ %s\n", v)
+	}
+	fmt.Println()
+
+	color.New(color.FgRed, color.Bold).Printf("✗ Configuration violates policy %s\n", err.Policy)
+}
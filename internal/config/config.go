@@ -1,46 +1,98 @@
 package config
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 
-	"github.com/spf13/viper"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/system"
+	"go.yaml.in/yaml/v3"
 )
 
+// Options controls how conditional config sections are evaluated
+type Options struct {
+	// System is matched against arch, macos and hostname conditions
+	System system.Info
+	// Profile is matched against profile conditions
+	Profile string
+	// Policy, if set, is enforced on top of the config layers
+	Policy *Policy
+}
+
+// DefaultOptions returns options for the current machine without a profile
+func DefaultOptions() Options {
+	return Options{
+		System: system.Detect(context.Background(), executor.New(false, false)),
+	}
+}
+
 // Load loads configuration from file or uses defaults
 func Load(configPath string) (*Config, error) {
-	v := viper.NewWithOptions(viper.KeyDelimiter("::"))
-	v.SetConfigType("yaml")
+	return LoadWithOptions(configPath, DefaultOptions())
+}
 
+// LoadWithOptions loads configuration, evaluating when: conditions against opts
+func LoadWithOptions(configPath string, opts Options) (*Config, error) {
 	// Load defaults first
-	if err := v.ReadConfig(bytes.NewBufferString(DefaultConfig)); err != nil {
+	defaults, err := parseLayer([]byte(DefaultConfig), configType, opts)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load default config: %w", err)
 	}
 
+	merged := make(map[string]any)
+	sources := make(map[string]string)
+	mergeMaps(merged, defaults, "", defaultsLayer, sources)
+
+	// Locked policy values replace the defaults
+	var violations []Violation
+	if opts.Policy != nil {
+		mergeMaps(merged, opts.Policy.Locked, "", "policy "+opts.Policy.Source, sources)
+	}
+
 	// If custom config provided, merge it
+	configDir := ""
 	if configPath != "" {
 		absPath, err := filepath.Abs(configPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve config path: %w", err)
 		}
 
-		if _, err := os.Stat(absPath); err != nil {
+		content, err := os.ReadFile(absPath)
+		if err != nil {
 			return nil, fmt.Errorf("config file not found: %s", absPath)
 		}
+		configDir = filepath.Dir(absPath)
 
-		v.SetConfigFile(absPath)
-		if err := v.MergeInConfig(); err != nil {
+		layer, err := parseLayer(content, configType, opts)
+		if err != nil {
 			return nil, fmt.Errorf("failed to merge config: %w", err)
 		}
+		if opts.Policy != nil {
+			violations = append(violations, opts.Policy.checkLocked(layer, layerName(configPath))...)
+		}
+		mergeMaps(merged, layer, "", layerName(configPath), sources)
 	}
 
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	if err := decodeInto(merged, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	cfg.Homebrew.Brewfile = resolvePath(configDir, cfg.Homebrew.Brewfile)
+	cfg.Homebrew.LockFile = resolvePath(configDir, cfg.Homebrew.LockFile)
+
+	if opts.Policy != nil {
+		violations = append(violations, opts.Policy.checkConfig(&cfg, sources)...)
+		if len(violations) > 0 {
+			return nil, &PolicyError{Policy: opts.Policy.Source, Violations: violations}
+		}
+	}
+
 	return &cfg, nil
 }
 
@@ -48,3 +100,83 @@ func Load(configPath string) (*Config, error) {
 func LoadDefault() (*Config, error) {
 	return Load("")
 }
+
+// parseLayer parses one YAML document laid out like schema and drops
+// sections and items whose conditions do not match
+func parseLayer(content []byte, schema reflect.Type, opts Options) (map[string]any, error) {
+	layer := make(map[string]any)
+	if err := yaml.Unmarshal(content, &layer); err != nil {
+		return nil, err
+	}
+
+	filtered, keep, err := applyConditions(layer, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		return make(map[string]any), nil
+	}
+
+	return filtered.(map[string]any), nil
+}
+
+// mergeMaps merges src into dst. Nested maps are merged key by key,
+// everything else (including lists) is replaced. The layer that set each
+// value is recorded in sources under its dotted key.
+func mergeMaps(dst, src map[string]any, prefix, layer string, sources map[string]string) {
+	for key, value := range src {
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+
+		srcMap, srcIsMap := value.(map[string]any)
+		if srcIsMap {
+			dstMap, dstIsMap := dst[key].(map[string]any)
+			if !dstIsMap {
+				dstMap = make(map[string]any)
+				dst[key] = dstMap
+			}
+			mergeMaps(dstMap, srcMap, fullKey, layer, sources)
+			continue
+		}
+		dst[key] = value
+		sources[fullKey] = layer
+	}
+}
+
+// decodeInto converts a merged map into result. Unlike viper it keeps the
+// case of map keys, which matters for environment variable names.
+func decodeInto(input map[string]any, result any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           result,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			stringToPackageHook,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(input)
+}
+
+// resolvePath expands ~/ and makes relative paths relative to dir
+func resolvePath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+		return path
+	}
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
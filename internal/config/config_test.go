@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +30,33 @@ func TestLoadDefault(t *testing.T) {
 		t.Error("expected terminal.oh_my_zsh.install to be true")
 	}
 
+	for _, script := range []InstallScript{cfg.Homebrew.InstallScript, cfg.Terminal.OhMyZsh.InstallScript} {
+		if script.Source != ScriptDownload || !strings.Contains(script.URL, "{commit}") || script.Commit == "" {
+			t.Errorf("unexpected install_script default: %+v", script)
+		}
+	}
+
+	if cfg.Terminal.OhMyZsh.Update.LocalChanges != LocalChangesStash {
+		t.Errorf("expected terminal.oh_my_zsh.update.local_changes to be stash, got %q", cfg.Terminal.OhMyZsh.Update.LocalChanges)
+	}
+
+	if cfg.Runtimes.Manager != RuntimeManagerMise || len(cfg.Runtimes.Tools) != 0 {
+		t.Errorf("unexpected runtimes default: %+v", cfg.Runtimes)
+	}
+
+	for _, ecosystem := range []string{PackagesNpm, PackagesPipx, PackagesCargo, PackagesGo} {
+		if packages, ok := cfg.Packages[ecosystem]; !ok || len(packages) != 0 {
+			t.Errorf("expected empty packages.%s default, got %v", ecosystem, cfg.Packages)
+		}
+	}
+
+	if cfg.VSCode.Editor != EditorCode || len(cfg.VSCode.Extensions) != 0 || len(cfg.VSCode.Settings) != 0 {
+		t.Errorf("unexpected vscode default: %+v", cfg.VSCode)
+	}
+	if len(cfg.Downloads) != 0 {
+		t.Errorf("expected no downloads by default, got %+v", cfg.Downloads)
+	}
+
 	if !cfg.Terminal.Powerlevel10k.Install {
 		t.Error("expected terminal.powerlevel10k.install to be true")
 	}
@@ -90,7 +119,7 @@ homebrew:
 		t.Error("expected homebrew.install to be false")
 	}
 
-	if len(cfg.Homebrew.Formulae) != 1 || cfg.Homebrew.Formulae[0] != "custom-formula" {
+	if len(cfg.Homebrew.Formulae) != 1 || cfg.Homebrew.Formulae[0].Name != "custom-formula" {
 		t.Errorf("expected formulae [custom-formula], got %v", cfg.Homebrew.Formulae)
 	}
 }
@@ -101,3 +130,240 @@ func TestLoadNonExistentConfig(t *testing.T) {
 		t.Error("expected error for non-existent config")
 	}
 }
+
+func TestLoadPreservesKeyCase(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "env.yaml")
+
+	configContent := `
+shell:
+  environment:
+    GITHUB_TOKEN: "secret://env:GITHUB_TOKEN"
+    EDITOR: "vim"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.Shell.Environment["GITHUB_TOKEN"] != "secret://env:GITHUB_TOKEN" {
+		t.Errorf("expected GITHUB_TOKEN to keep its case, got %v", cfg.Shell.Environment)
+	}
+
+	if cfg.Shell.Environment["EDITOR"] != "vim" {
+		t.Errorf("expected EDITOR to be overridden, got %v", cfg.Shell.Environment)
+	}
+
+	if _, ok := cfg.Shell.Environment["LANG"]; !ok {
+		t.Errorf("expected default LANG to be kept, got %v", cfg.Shell.Environment)
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "packages.yaml")
+
+	configContent := `
+packages:
+  npm: ["typescript", "@angular/cli@17.3.0"]
+  cargo: ripgrep
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if got := cfg.Packages[PackagesNpm]; len(got) != 2 || got[1] != "@angular/cli@17.3.0" {
+		t.Errorf("unexpected npm packages: %v", got)
+	}
+	if got := cfg.Packages[PackagesCargo]; len(got) != 1 || got[0] != "ripgrep" {
+		t.Errorf("expected a single cargo package, got %v", got)
+	}
+	if _, ok := cfg.Packages[PackagesPipx]; !ok {
+		t.Errorf("expected default pipx packages to be kept, got %v", cfg.Packages)
+	}
+}
+
+func TestLoadVSCodeSettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vscode.yaml")
+
+	configContent := `
+vscode:
+  editor: cursor
+  extensions: [golang.Go]
+  settings:
+    editor.fontSize: 14
+    "[python]":
+      editor.defaultFormatter: ms-python.black-formatter
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.VSCode.Editor != EditorCursor || len(cfg.VSCode.Extensions) != 1 || cfg.VSCode.Extensions[0] != "golang.Go" {
+		t.Errorf("unexpected vscode config: %+v", cfg.VSCode)
+	}
+	if cfg.VSCode.Settings["editor.fontSize"] != 14 {
+		t.Errorf("expected editor.fontSize to keep its case, got %v", cfg.VSCode.Settings)
+	}
+	python, ok := cfg.VSCode.Settings["[python]"].(map[string]any)
+	if !ok || python["editor.defaultFormatter"] != "ms-python.black-formatter" {
+		t.Errorf("unexpected [python] settings: %#v", cfg.VSCode.Settings["[python]"])
+	}
+}
+
+func TestLoadDownloads(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "downloads.yaml")
+
+	configContent := `
+downloads:
+  - name: deploy
+    url: "https://artifacts.example.com/deploy/1.4.0/deploy_{{os}}_{{arch}}.tar.gz"
+    sha256:
+      arm64: aaaa
+      amd64: bbbb
+    files: ["*/deploy"]
+  - name: lint
+    url: https://artifacts.example.com/lint
+    sha256: cccc
+    dest: ~/bin
+    mode: 0750
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if len(cfg.Downloads) != 2 {
+		t.Fatalf("expected 2 downloads, got %+v", cfg.Downloads)
+	}
+	deploy, lint := cfg.Downloads[0], cfg.Downloads[1]
+	if deploy.SHA256.For("arm64") != "aaaa" || deploy.SHA256.For("amd64") != "bbbb" || deploy.SHA256.For("386") != "" {
+		t.Errorf("unexpected deploy checksums: %v", deploy.SHA256)
+	}
+	if len(deploy.Files) != 1 || deploy.Files[0] != "*/deploy" {
+		t.Errorf("unexpected deploy files: %v", deploy.Files)
+	}
+	if lint.SHA256.For("arm64") != "cccc" || lint.Dest != "~/bin" || lint.Mode != "0750" {
+		t.Errorf("unexpected lint download: %+v", lint)
+	}
+}
+
+func TestLoadResolvesBrewfilePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("homebrew:\n  brewfile: Brewfile\n"), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if want := filepath.Join(tmpDir, "Brewfile"); cfg.Homebrew.Brewfile != want {
+		t.Errorf("expected brewfile %s, got %s", want, cfg.Homebrew.Brewfile)
+	}
+}
+
+func TestLoadPackageObjects(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	configContent := `
+homebrew:
+  taps:
+    - homebrew/cask-fonts
+    - name: acme/tools
+      url: git@github.com:acme/homebrew-tools.git
+  formulae:
+    - git
+    - name: node@20
+      pin: true
+    - name: python@3.12
+      version_constraint: "<3.13"
+    - name: neovim
+      args: ["--HEAD"]
+  casks:
+    - iterm2
+    - name: firefox
+      args: ["--no-quarantine", "--appdir=~/Applications"]
+      greedy: true
+  services:
+    - postgresql@16
+    - name: redis
+      state: stopped
+    - name: colima
+      run_at_login: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	wantFormulae := []Formula{
+		{Name: "git"},
+		{Name: "node@20", Pin: true},
+		{Name: "python@3.12", VersionConstraint: "<3.13"},
+		{Name: "neovim", Args: []string{"--HEAD"}},
+	}
+	if !reflect.DeepEqual(cfg.Homebrew.Formulae, wantFormulae) {
+		t.Errorf("formulae = %+v, want %+v", cfg.Homebrew.Formulae, wantFormulae)
+	}
+
+	wantCasks := []Cask{
+		{Name: "iterm2"},
+		{Name: "firefox", Args: []string{"--no-quarantine", "--appdir=~/Applications"}, Greedy: true},
+	}
+	if !reflect.DeepEqual(cfg.Homebrew.Casks, wantCasks) {
+		t.Errorf("casks = %+v, want %+v", cfg.Homebrew.Casks, wantCasks)
+	}
+
+	wantTaps := []Tap{
+		{Name: "homebrew/cask-fonts"},
+		{Name: "acme/tools", URL: "git@github.com:acme/homebrew-tools.git"},
+	}
+	if !reflect.DeepEqual(cfg.Homebrew.Taps, wantTaps) {
+		t.Errorf("taps = %+v, want %+v", cfg.Homebrew.Taps, wantTaps)
+	}
+
+	services := cfg.Homebrew.Services
+	if len(services) != 3 || !services[0].Started() || !services[0].AtLogin() ||
+		services[1].Started() || !services[2].Started() || services[2].AtLogin() {
+		t.Errorf("unexpected services %+v", services)
+	}
+
+	// Entries without options are written back as plain names
+	out, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	for _, want := range []string{"- git\n", "name: node@20", "- iterm2\n", "greedy: true", "- homebrew/cask-fonts\n", "url: git@github.com", "- postgresql@16\n", "run_at_login: false"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// sectionOrder lists the top-level keys in the order they appear in defaults.yaml
+var sectionOrder = []string{
+	"version", "settings", "homebrew", "terminal", "runtimes", "packages",
+	"vscode", "downloads", "shell", "macos", "git", "ssh",
+}
+
+// Override returns the values of cfg that differ from base as a nested map.
+// Lists are compared as a whole, since a merged list replaces the default one.
+func Override(base, cfg *Config) (map[string]any, error) {
+	baseMap, err := toMap(base)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgMap, err := toMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffMaps(baseMap, cfgMap), nil
+}
+
+// MarshalOverride renders the minimal YAML override that turns base into cfg
+func MarshalOverride(base, cfg *Config) ([]byte, error) {
+	override, err := Override(base, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalSections(override)
+}
+
+// Marshal renders a complete config as YAML, keeping the section order of defaults.yaml
+func Marshal(cfg *Config) ([]byte, error) {
+	return marshalSections(cfg)
+}
+
+// marshalSections writes the top-level sections of v in sectionOrder,
+// separated by blank lines and indented like defaults.yaml. Sections
+// missing from sectionOrder follow in their own order.
+func marshalSections(v any) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	sections := make(map[string][]*yaml.Node)
+	order := slices.Clone(sectionOrder)
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key := doc.Content[i].Value
+		sections[key] = doc.Content[i : i+2]
+		if !slices.Contains(order, key) {
+			order = append(order, key)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, section := range order {
+		pair, ok := sections[section]
+		if !ok {
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&yaml.Node{Kind: yaml.MappingNode, Content: pair}); err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", section, err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// toMap converts a config into the generic map form used by YAML
+func toMap(cfg *Config) (map[string]any, error) {
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	m := make(map[string]any)
+	if err := yaml.Unmarshal(out, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	return m, nil
+}
+
+func diffMaps(base, cfg map[string]any) map[string]any {
+	diff := make(map[string]any)
+
+	for key, value := range cfg {
+		baseValue, ok := base[key]
+		if !ok {
+			diff[key] = value
+			continue
+		}
+
+		valueMap, isMap := value.(map[string]any)
+		baseValueMap, baseIsMap := baseValue.(map[string]any)
+		if isMap && baseIsMap {
+			if nested := diffMaps(baseValueMap, valueMap); len(nested) > 0 {
+				diff[key] = nested
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(baseValue, value) {
+			diff[key] = value
+		}
+	}
+
+	return diff
+}
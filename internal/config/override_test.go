@@ -0,0 +1,183 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOverrideUnchanged(t *testing.T) {
+	base, _ := LoadDefault()
+	cfg, _ := LoadDefault()
+
+	override, err := Override(base, cfg)
+	if err != nil {
+		t.Fatalf("failed to compute override: %v", err)
+	}
+
+	if len(override) != 0 {
+		t.Errorf("expected empty override, got %v", override)
+	}
+}
+
+func TestOverrideOnlyChangedValues(t *testing.T) {
+	base, _ := LoadDefault()
+	cfg, _ := LoadDefault()
+
+	cfg.Git.User.Name = "Jane Doe"
+	cfg.MacOS.Defaults.Dock.TileSize = 36
+	cfg.Homebrew.Casks = NewCasks([]string{"iterm2"})
+
+	override, err := Override(base, cfg)
+	if err != nil {
+		t.Fatalf("failed to compute override: %v", err)
+	}
+
+	if len(override) != 3 {
+		t.Errorf("expected 3 changed sections, got %v", override)
+	}
+
+	git := override["git"].(map[string]any)
+	if len(git) != 1 {
+		t.Errorf("expected only git.user, got %v", git)
+	}
+
+	homebrew := override["homebrew"].(map[string]any)
+	if _, ok := homebrew["formulae"]; ok {
+		t.Error("unchanged formulae should not be in override")
+	}
+}
+
+func TestMarshalOverrideRoundTrip(t *testing.T) {
+	base, _ := LoadDefault()
+	cfg, _ := LoadDefault()
+
+	cfg.SSH.KeyType = "rsa"
+	cfg.SSH.KeyFile = "~/.ssh/id_rsa"
+	cfg.Homebrew.Formulae = append(cfg.Homebrew.Formulae, Formula{Name: "eza"})
+	cfg.Terminal.Powerlevel10k.Style = "lean"
+
+	out, err := MarshalOverride(base, cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal override: %v", err)
+	}
+
+	if strings.Contains(string(out), "dock") {
+		t.Errorf("override should not contain unchanged sections:\n%s", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "override.yaml")
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatalf("failed to write override: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load override: %v", err)
+	}
+
+	override, err := Override(cfg, loaded)
+	if err != nil {
+		t.Fatalf("failed to compute override: %v", err)
+	}
+
+	if len(override) != 0 {
+		t.Errorf("loaded override differs from source config: %v", override)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	cfg, _ := LoadDefault()
+	cfg.Git.User.Email = "jane@example.com"
+
+	out, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	if !strings.HasPrefix(string(out), "version:") {
+		t.Errorf("expected version first, got:\n%s", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "full.yaml")
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	override, err := Override(cfg, loaded)
+	if err != nil {
+		t.Fatalf("failed to compute override: %v", err)
+	}
+
+	if len(override) != 0 {
+		t.Errorf("round-tripped config differs: %v", override)
+	}
+}
+
+func TestSectionOrderCoversSchema(t *testing.T) {
+	fields := reflect.TypeOf(Config{})
+	for i := 0; i < fields.NumField(); i++ {
+		section := strings.Split(fields.Field(i).Tag.Get("yaml"), ",")[0]
+		if !slices.Contains(sectionOrder, section) {
+			t.Errorf("section %s is missing from sectionOrder", section)
+		}
+	}
+}
+
+func TestMarshalOverrideRoundTripAllSections(t *testing.T) {
+	base, _ := LoadDefault()
+	cfg, _ := LoadDefault()
+
+	cfg.Settings.BackupDotfiles = !cfg.Settings.BackupDotfiles
+	cfg.Homebrew.Formulae = append(cfg.Homebrew.Formulae, Formula{Name: "eza"})
+	cfg.Terminal.Powerlevel10k.Style = "lean"
+	cfg.Runtimes = RuntimesConfig{Manager: RuntimeManagerMise, Tools: map[string][]string{"node": {"20"}}}
+	cfg.Packages[PackagesNpm] = []string{"typescript"}
+	cfg.VSCode.Editor = EditorCursor
+	cfg.VSCode.Extensions = []string{"golang.go"}
+	cfg.Downloads = []Download{{Name: "tool", URL: "https://example.com/tool", SHA256: Checksums{"*": "abc"}, Mode: "0755"}}
+	cfg.Shell.Aliases["tf"] = "terraform"
+	cfg.MacOS.Defaults.Dock.TileSize = 36
+	cfg.Git.User.Name = "Jane Doe"
+	cfg.SSH.KeyType = "rsa"
+
+	out, err := MarshalOverride(base, cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal override: %v", err)
+	}
+
+	fields := reflect.TypeOf(Config{})
+	for i := 0; i < fields.NumField(); i++ {
+		section := strings.Split(fields.Field(i).Tag.Get("yaml"), ",")[0]
+		if section != "version" && !strings.Contains(string(out), "\n"+section+":") && !strings.HasPrefix(string(out), section+":") {
+			t.Errorf("override is missing the %s section:\n%s", section, out)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "override.yaml")
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatalf("failed to write override: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load override: %v", err)
+	}
+
+	override, err := Override(cfg, loaded)
+	if err != nil {
+		t.Fatalf("failed to compute override: %v", err)
+	}
+
+	if len(override) != 0 {
+		t.Errorf("loaded override differs from source config: %v", override)
+	}
+}
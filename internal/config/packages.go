@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Service states
+const (
+	ServiceStarted = "started"
+	ServiceStopped = "stopped"
+)
+
+// NewFormulae converts plain formula names to Formula entries
+func NewFormulae(names []string) []Formula {
+	formulae := make([]Formula, len(names))
+	for i, name := range names {
+		formulae[i] = Formula{Name: name}
+	}
+	return formulae
+}
+
+// NewCasks converts plain cask names to Cask entries
+func NewCasks(names []string) []Cask {
+	casks := make([]Cask, len(names))
+	for i, name := range names {
+		casks[i] = Cask{Name: name}
+	}
+	return casks
+}
+
+// NewTaps converts plain tap names to Tap entries
+func NewTaps(names []string) []Tap {
+	taps := make([]Tap, len(names))
+	for i, name := range names {
+		taps[i] = Tap{Name: name}
+	}
+	return taps
+}
+
+// FormulaNames returns the names of the configured formulae
+func (h HomebrewConfig) FormulaNames() []string {
+	names := make([]string, len(h.Formulae))
+	for i, f := range h.Formulae {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// CaskNames returns the names of the configured casks
+func (h HomebrewConfig) CaskNames() []string {
+	names := make([]string, len(h.Casks))
+	for i, c := range h.Casks {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// TapNames returns the names of the configured taps
+func (h HomebrewConfig) TapNames() []string {
+	names := make([]string, len(h.Taps))
+	for i, t := range h.Taps {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// MarshalYAML writes a formula without options as its plain name
+func (f Formula) MarshalYAML() (any, error) {
+	if !f.Pin && f.VersionConstraint == "" && len(f.Args) == 0 {
+		return f.Name, nil
+	}
+
+	type plain Formula
+	return plain(f), nil
+}
+
+// MarshalYAML writes a cask without options as its plain name
+func (c Cask) MarshalYAML() (any, error) {
+	if !c.Greedy && len(c.Args) == 0 {
+		return c.Name, nil
+	}
+
+	type plain Cask
+	return plain(c), nil
+}
+
+// MarshalYAML writes a tap without URL as its plain name
+func (t Tap) MarshalYAML() (any, error) {
+	if t.URL == "" {
+		return t.Name, nil
+	}
+
+	type plain Tap
+	return plain(t), nil
+}
+
+// Started reports whether the service should be running
+func (s Service) Started() bool {
+	return s.State == "" || s.State == ServiceStarted
+}
+
+// AtLogin reports whether a started service should start at login
+func (s Service) AtLogin() bool {
+	return s.RunAtLogin == nil || *s.RunAtLogin
+}
+
+// MarshalYAML writes a service with default options as its plain name
+func (s Service) MarshalYAML() (any, error) {
+	if s.Started() && s.AtLogin() {
+		return s.Name, nil
+	}
+
+	type plain Service
+	return plain(s), nil
+}
+
+// stringToPackageHook lets package lists mix plain names and objects. It
+// also reads a single sha256 as Checksums and an unquoted octal mode.
+func stringToPackageHook(from, to reflect.Type, data any) (any, error) {
+	if to == reflect.TypeOf(FileMode("")) && from.Kind() == reflect.Int {
+		return FileMode(fmt.Sprintf("%04o", data)), nil
+	}
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+
+	switch to {
+	case reflect.TypeOf(Formula{}):
+		return Formula{Name: data.(string)}, nil
+	case reflect.TypeOf(Cask{}):
+		return Cask{Name: data.(string)}, nil
+	case reflect.TypeOf(Tap{}):
+		return Tap{Name: data.(string)}, nil
+	case reflect.TypeOf(Service{}):
+		return Service{Name: data.(string)}, nil
+	case reflect.TypeOf(Checksums{}):
+		return Checksums{"*": data.(string)}, nil
+	}
+	return data, nil
+}
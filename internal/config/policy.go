@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+)
+
+// SystemPolicyPath is loaded when no policy is given explicitly, so that a
+// team policy can be installed by MDM
+const SystemPolicyPath = "/Library/Application Support/setup-mac/policy.yaml"
+
+// defaultsLayer names the embedded defaults in policy violations
+const defaultsLayer = "embedded defaults"
+
+// sshKeyStrength orders SSH key types from weakest to strongest
+var sshKeyStrength = map[string]int{
+	"rsa":     1,
+	"ecdsa":   2,
+	"ed25519": 3,
+}
+
+// Policy contains team rules that personal configs cannot override
+type Policy struct {
+	// Locked mirrors the config structure. Its values are enforced and
+	// no config layer may set them to anything else.
+	Locked   map[string]any `yaml:"locked" mapstructure:"locked"`
+	Homebrew HomebrewPolicy `yaml:"homebrew" mapstructure:"homebrew"`
+	SSH      SSHPolicy      `yaml:"ssh" mapstructure:"ssh"`
+
+	// Source is the file the policy was loaded from
+	Source string `yaml:"-" mapstructure:"-"`
+}
+
+// policyDocumentType is the layout of a policy file for when: conditions.
+// Locked values are laid out like the config.
+var policyDocumentType = reflect.TypeOf(struct {
+	Policy struct {
+		Locked   Config         `yaml:"locked"`
+		Homebrew HomebrewPolicy `yaml:"homebrew"`
+		SSH      SSHPolicy      `yaml:"ssh"`
+	} `yaml:"policy"`
+}{})
+
+// HomebrewPolicy restricts Homebrew packages
+type HomebrewPolicy struct {
+	Formulae PackageRules `yaml:"formulae" mapstructure:"formulae"`
+	Casks    PackageRules `yaml:"casks" mapstructure:"casks"`
+	Taps     PackageRules `yaml:"taps" mapstructure:"taps"`
+}
+
+// PackageRules lists glob patterns of allowed and denied packages.
+// An empty allow list allows everything not denied.
+type PackageRules struct {
+	Allow []string `yaml:"allow" mapstructure:"allow"`
+	Deny  []string `yaml:"deny" mapstructure:"deny"`
+}
+
+// SSHPolicy restricts SSH key generation
+type SSHPolicy struct {
+	// MinKeyType is the weakest accepted key type (rsa < ecdsa < ed25519)
+	MinKeyType string `yaml:"min_key_type" mapstructure:"min_key_type"`
+}
+
+// Violation describes one config value that breaks the policy
+type Violation struct {
+	// Layer is the config layer that set the value
+	Layer   string
+	Key     string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s (set by %s)", v.Key, v.Message, v.Layer)
+}
+
+// PolicyError is returned when a config breaks the policy
+type PolicyError struct {
+	Policy     string
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	lines := []string{fmt.Sprintf("config violates policy %s:", e.Policy)}
+	for _, v := range e.Violations {
+		lines = append(lines, "  - "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadPolicy loads a policy document. The rules live under a top-level
+// policy: key and may use when: conditions like the config.
+func LoadPolicy(policyPath string, opts Options) (*Policy, error) {
+	content, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("policy file not found: %s", policyPath)
+	}
+
+	doc, err := parseLayer(content, policyDocumentType, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	raw, ok := doc["policy"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("policy file %s has no policy: section", policyPath)
+	}
+
+	var p Policy
+	if err := decodeInto(raw, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	p.Source = policyPath
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", policyPath, err)
+	}
+
+	return &p, nil
+}
+
+// FindPolicy returns the explicit policy path, or the system policy if it exists
+func FindPolicy(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if _, err := os.Stat(SystemPolicyPath); err == nil {
+		return SystemPolicyPath
+	}
+	return ""
+}
+
+// validate checks glob patterns and the SSH key type
+func (p *Policy) validate() error {
+	rules := map[string]PackageRules{
+		"homebrew.formulae": p.Homebrew.Formulae,
+		"homebrew.casks":    p.Homebrew.Casks,
+		"homebrew.taps":     p.Homebrew.Taps,
+	}
+	for key, r := range rules {
+		for _, pattern := range append(append([]string{}, r.Allow...), r.Deny...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %q", key, pattern)
+			}
+		}
+	}
+
+	if p.SSH.MinKeyType != "" {
+		if _, ok := sshKeyStrength[p.SSH.MinKeyType]; !ok {
+			return fmt.Errorf("ssh.min_key_type: unknown key type %q (valid: rsa, ecdsa, ed25519)", p.SSH.MinKeyType)
+		}
+	}
+
+	return nil
+}
+
+// Allows reports whether a package name passes the rules
+func (r PackageRules) Allows(name string) (bool, string) {
+	for _, pattern := range r.Deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false, fmt.Sprintf("denied by pattern %q", pattern)
+		}
+	}
+
+	if len(r.Allow) == 0 {
+		return true, ""
+	}
+	for _, pattern := range r.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true, ""
+		}
+	}
+	return false, "not in allow list"
+}
+
+// checkLocked reports values in a config layer that differ from locked values
+func (p *Policy) checkLocked(layer map[string]any, layerName string) []Violation {
+	var violations []Violation
+	walkLocked(p.Locked, layer, "", func(key string, want, got any) {
+		violations = append(violations, Violation{
+			Layer:   layerName,
+			Key:     key,
+			Message: fmt.Sprintf("locked to %v, got %v", want, got),
+		})
+	})
+	return violations
+}
+
+// walkLocked calls report for every locked leaf that the layer sets differently
+func walkLocked(locked, layer map[string]any, prefix string, report func(key string, want, got any)) {
+	keys := make([]string, 0, len(locked))
+	for key := range locked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		want := locked[key]
+		got, ok := layer[key]
+		if !ok {
+			continue
+		}
+
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+
+		wantMap, wantIsMap := want.(map[string]any)
+		gotMap, gotIsMap := got.(map[string]any)
+		switch {
+		case wantIsMap && gotIsMap:
+			walkLocked(wantMap, gotMap, fullKey, report)
+		case fmt.Sprint(want) != fmt.Sprint(got):
+			report(fullKey, want, got)
+		}
+	}
+}
+
+// checkConfig reports packages and SSH settings in the merged config that
+// break the rules. sources maps config keys to the layer that set them.
+func (p *Policy) checkConfig(cfg *Config, sources map[string]string) []Violation {
+	var violations []Violation
+
+	source := func(key string) string {
+		if layer, ok := sources[key]; ok {
+			return layer
+		}
+		return defaultsLayer
+	}
+
+	check := func(key, layer string, rules PackageRules, names []string) {
+		for _, name := range names {
+			if ok, reason := rules.Allows(name); !ok {
+				violations = append(violations, Violation{
+					Layer:   layer,
+					Key:     key,
+					Message: fmt.Sprintf("%s %s", name, reason),
+				})
+			}
+		}
+	}
+	check("homebrew.formulae", source("homebrew.formulae"), p.Homebrew.Formulae, cfg.Homebrew.FormulaNames())
+	check("homebrew.casks", source("homebrew.casks"), p.Homebrew.Casks, cfg.Homebrew.CaskNames())
+	check("homebrew.taps", source("homebrew.taps"), p.Homebrew.Taps, cfg.Homebrew.TapNames())
+
+	// Brewfile entries are checked too. Parse errors are reported by
+	// validate and install.
+	if cfg.Homebrew.Brewfile != "" {
+		if bf, err := brewfile.ParseFile(cfg.Homebrew.Brewfile); err == nil {
+			layer := "brewfile " + cfg.Homebrew.Brewfile
+			var formulae, casks, taps []string
+			for _, pkg := range bf.Brews {
+				formulae = append(formulae, pkg.Name)
+			}
+			for _, pkg := range bf.Casks {
+				casks = append(casks, pkg.Name)
+			}
+			for _, tap := range bf.Taps {
+				taps = append(taps, tap.Name)
+			}
+			check("homebrew.formulae", layer, p.Homebrew.Formulae, formulae)
+			check("homebrew.casks", layer, p.Homebrew.Casks, casks)
+			check("homebrew.taps", layer, p.Homebrew.Taps, taps)
+		}
+	}
+
+	if p.SSH.MinKeyType != "" && cfg.SSH.GenerateKey {
+		keyType := cfg.SSH.KeyType
+		if keyType == "" {
+			keyType = "ed25519"
+		}
+		if sshKeyStrength[keyType] < sshKeyStrength[p.SSH.MinKeyType] {
+			violations = append(violations, Violation{
+				Layer:   source("ssh.key_type"),
+				Key:     "ssh.key_type",
+				Message: fmt.Sprintf("%s is weaker than the required %s", keyType, p.SSH.MinKeyType),
+			})
+		}
+	}
+
+	return violations
+}
+
+// layerName describes a config file in policy violations
+func layerName(configPath string) string {
+	return "config " + filepath.Clean(configPath)
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+const testPolicy = `
+policy:
+  locked:
+    git:
+      settings:
+        pull.rebase: "true"
+  homebrew:
+    casks:
+      deny: ["*torrent*"]
+    taps:
+      allow: ["homebrew/*"]
+  ssh:
+    min_key_type: ed25519
+`
+
+func TestPackageRulesAllows(t *testing.T) {
+	rules := PackageRules{Allow: []string{"homebrew/*", "acme/*"}, Deny: []string{"acme/legacy"}}
+
+	tests := map[string]bool{
+		"homebrew/cask-fonts": true,
+		"acme/tools":          true,
+		"acme/legacy":         false,
+		"evil/tap":            false,
+	}
+	for name, want := range tests {
+		if got, _ := rules.Allows(name); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if ok, _ := (PackageRules{}).Allows("anything"); !ok {
+		t.Error("expected empty rules to allow everything")
+	}
+}
+
+func TestLoadWithPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := LoadPolicy(writeFile(t, dir, "policy.yaml", testPolicy), Options{})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	configPath := writeFile(t, dir, "config.yaml", `
+homebrew:
+  casks: [iterm2]
+`)
+
+	cfg, err := LoadWithOptions(configPath, Options{Policy: policy})
+	if err != nil {
+		t.Fatalf("expected compliant config to load: %v", err)
+	}
+
+	if cfg.Git.Settings["pull.rebase"] != "true" {
+		t.Errorf("expected locked pull.rebase to be applied, got %q", cfg.Git.Settings["pull.rebase"])
+	}
+}
+
+func TestLoadWithPolicyViolations(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := LoadPolicy(writeFile(t, dir, "policy.yaml", testPolicy), Options{})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	configPath := writeFile(t, dir, "config.yaml", `
+homebrew:
+  casks: [iterm2, qbittorrent]
+  taps: [homebrew/cask-fonts, evil/tap]
+git:
+  settings:
+    pull.rebase: "false"
+ssh:
+  key_type: rsa
+`)
+
+	_, err = LoadWithOptions(configPath, Options{Policy: policy})
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected PolicyError, got %v", err)
+	}
+
+	wantKeys := []string{"git.settings.pull.rebase", "homebrew.casks", "homebrew.taps", "ssh.key_type"}
+	if len(policyErr.Violations) != len(wantKeys) {
+		t.Fatalf("expected %d violations, got %v", len(wantKeys), policyErr.Violations)
+	}
+	for i, v := range policyErr.Violations {
+		if v.Key != wantKeys[i] {
+			t.Errorf("violation %d: expected key %s, got %s", i, wantKeys[i], v.Key)
+		}
+		if !strings.Contains(v.Layer, "config.yaml") {
+			t.Errorf("violation %d: expected layer to name the config file, got %q", i, v.Layer)
+		}
+	}
+}
+
+func TestPolicyViolationInDefaults(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := LoadPolicy(writeFile(t, dir, "policy.yaml", `
+policy:
+  homebrew:
+    formulae:
+      deny: ["wget"]
+`), Options{})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	_, err = LoadWithOptions("", Options{Policy: policy})
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected PolicyError, got %v", err)
+	}
+	if policyErr.Violations[0].Layer != defaultsLayer {
+		t.Errorf("expected violation from %s, got %q", defaultsLayer, policyErr.Violations[0].Layer)
+	}
+}
+
+func TestLoadPolicyWithConditions(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "policy.yaml", `
+policy:
+  locked:
+    ssh:
+      when:
+        profile: work
+      key_type: ed25519
+  ssh:
+    when:
+      profile: work
+    min_key_type: ed25519
+`)
+
+	home, err := LoadPolicy(path, Options{Profile: "home"})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if len(home.Locked) != 0 || home.SSH.MinKeyType != "" {
+		t.Errorf("expected work rules to be dropped, got %+v", home)
+	}
+
+	work, err := LoadPolicy(path, Options{Profile: "work"})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	locked, _ := work.Locked["ssh"].(map[string]any)
+	if locked["key_type"] != "ed25519" || work.SSH.MinKeyType != "ed25519" {
+		t.Errorf("expected work rules to apply, got %+v", work)
+	}
+}
+
+func TestLoadInvalidPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	invalid := map[string]string{
+		"missing section": "homebrew:\n  install: true\n",
+		"bad pattern":     "policy:\n  homebrew:\n    casks:\n      deny: [\"[\"]\n",
+		"bad key type":    "policy:\n  ssh:\n    min_key_type: dsa\n",
+	}
+	for name, content := range invalid {
+		if _, err := LoadPolicy(writeFile(t, dir, "policy.yaml", content), Options{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPolicyChecksBrewfile(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := LoadPolicy(writeFile(t, dir, "policy.yaml", testPolicy), Options{})
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	writeFile(t, dir, "Brewfile", "cask \"qbittorrent\"\n")
+	configPath := writeFile(t, dir, "config.yaml", "homebrew:\n  brewfile: Brewfile\n")
+
+	_, err = LoadWithOptions(configPath, Options{Policy: policy})
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected PolicyError, got %v", err)
+	}
+	if !strings.HasPrefix(policyErr.Violations[0].Layer, "brewfile ") {
+		t.Errorf("expected violation from the Brewfile, got %q", policyErr.Violations[0].Layer)
+	}
+}
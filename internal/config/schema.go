@@ -2,14 +2,18 @@ package config
 
 // Config represents the root configuration structure
 type Config struct {
-	Version  string         `yaml:"version" mapstructure:"version"`
-	Settings SettingsConfig `yaml:"settings" mapstructure:"settings"`
-	Homebrew HomebrewConfig `yaml:"homebrew" mapstructure:"homebrew"`
-	Terminal TerminalConfig `yaml:"terminal" mapstructure:"terminal"`
-	Shell    ShellConfig    `yaml:"shell" mapstructure:"shell"`
-	MacOS    MacOSConfig    `yaml:"macos" mapstructure:"macos"`
-	Git      GitConfig      `yaml:"git" mapstructure:"git"`
-	SSH      SSHConfig      `yaml:"ssh" mapstructure:"ssh"`
+	Version   string         `yaml:"version" mapstructure:"version"`
+	Settings  SettingsConfig `yaml:"settings" mapstructure:"settings"`
+	Homebrew  HomebrewConfig `yaml:"homebrew" mapstructure:"homebrew"`
+	Terminal  TerminalConfig `yaml:"terminal" mapstructure:"terminal"`
+	Runtimes  RuntimesConfig `yaml:"runtimes" mapstructure:"runtimes"`
+	Packages  PackagesConfig `yaml:"packages" mapstructure:"packages"`
+	VSCode    VSCodeConfig   `yaml:"vscode" mapstructure:"vscode"`
+	Downloads []Download     `yaml:"downloads" mapstructure:"downloads"`
+	Shell     ShellConfig    `yaml:"shell" mapstructure:"shell"`
+	MacOS     MacOSConfig    `yaml:"macos" mapstructure:"macos"`
+	Git       GitConfig      `yaml:"git" mapstructure:"git"`
+	SSH       SSHConfig      `yaml:"ssh" mapstructure:"ssh"`
 }
 
 // SettingsConfig contains global settings
@@ -21,10 +25,89 @@ type SettingsConfig struct {
 
 // HomebrewConfig contains Homebrew installation settings
 type HomebrewConfig struct {
-	Install  bool     `yaml:"install" mapstructure:"install"`
-	Formulae []string `yaml:"formulae" mapstructure:"formulae"`
-	Casks    []string `yaml:"casks" mapstructure:"casks"`
-	Taps     []string `yaml:"taps" mapstructure:"taps"`
+	Install     bool      `yaml:"install" mapstructure:"install"`
+	Formulae    []Formula `yaml:"formulae" mapstructure:"formulae"`
+	Casks       []Cask    `yaml:"casks" mapstructure:"casks"`
+	Taps        []Tap     `yaml:"taps" mapstructure:"taps"`
+	Brewfile    string    `yaml:"brewfile" mapstructure:"brewfile"`
+	LockFile    string    `yaml:"lock_file" mapstructure:"lock_file"`
+	Prune       bool      `yaml:"prune" mapstructure:"prune"`
+	PruneIgnore []string  `yaml:"prune_ignore" mapstructure:"prune_ignore"`
+	MasApps     []MasApp  `yaml:"mas_apps" mapstructure:"mas_apps"`
+	Services    []Service `yaml:"services" mapstructure:"services"`
+	// ShellenvFile gets the brew shellenv block; empty leaves the shell alone
+	ShellenvFile  string               `yaml:"shellenv_file" mapstructure:"shellenv_file"`
+	InstallScript InstallScript        `yaml:"install_script" mapstructure:"install_script"`
+	Update        HomebrewUpdateConfig `yaml:"update" mapstructure:"update"`
+}
+
+// HomebrewUpdateConfig controls which packages update --homebrew upgrades
+type HomebrewUpdateConfig struct {
+	// Exclude lists formulae and casks (globs) that are never upgraded
+	Exclude []string `yaml:"exclude" mapstructure:"exclude"`
+	// Greedy also upgrades casks that update themselves
+	Greedy bool `yaml:"greedy" mapstructure:"greedy"`
+}
+
+// Install script sources
+const (
+	ScriptDownload = "download"
+	ScriptVendored = "vendored"
+)
+
+// InstallScript controls how a remote install script is fetched and checked
+// before it runs
+type InstallScript struct {
+	// Source is download (default) or vendored, the copy built into setup-mac
+	Source string `yaml:"source" mapstructure:"source"`
+	// URL is the download location; {commit} is replaced by Commit
+	URL    string `yaml:"url" mapstructure:"url"`
+	Commit string `yaml:"commit" mapstructure:"commit"`
+	// SHA256 is the expected checksum of the script
+	SHA256 string `yaml:"sha256" mapstructure:"sha256"`
+	// Show prints the script and asks before running it
+	Show bool `yaml:"show" mapstructure:"show"`
+}
+
+// Service is a formula run by brew services. In YAML it is either a plain
+// name (started at login) or an object with the desired state.
+type Service struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// State is started (default) or stopped
+	State string `yaml:"state,omitempty" mapstructure:"state"`
+	// RunAtLogin registers a started service to start at login (default
+	// true); false runs it only until the next logout
+	RunAtLogin *bool `yaml:"run_at_login,omitempty" mapstructure:"run_at_login"`
+}
+
+// MasApp is a Mac App Store app installed with mas
+type MasApp struct {
+	ID   int    `yaml:"id" mapstructure:"id"`
+	Name string `yaml:"name" mapstructure:"name"`
+}
+
+// Formula is a Homebrew formula. In YAML it is either a plain name or an
+// object with pin, version_constraint and install args.
+type Formula struct {
+	Name              string   `yaml:"name" mapstructure:"name"`
+	Pin               bool     `yaml:"pin,omitempty" mapstructure:"pin"`
+	VersionConstraint string   `yaml:"version_constraint,omitempty" mapstructure:"version_constraint"`
+	Args              []string `yaml:"args,omitempty" mapstructure:"args"`
+}
+
+// Cask is a Homebrew cask. In YAML it is either a plain name or an object
+// with install args and greedy upgrades.
+type Cask struct {
+	Name   string   `yaml:"name" mapstructure:"name"`
+	Args   []string `yaml:"args,omitempty" mapstructure:"args"`
+	Greedy bool     `yaml:"greedy,omitempty" mapstructure:"greedy"`
+}
+
+// Tap is a Homebrew tap. In YAML it is either a plain name or an object
+// with a custom git URL.
+type Tap struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	URL  string `yaml:"url,omitempty" mapstructure:"url"`
 }
 
 // TerminalConfig contains terminal-related settings
@@ -35,9 +118,28 @@ type TerminalConfig struct {
 
 // OhMyZshConfig contains Oh-My-Zsh settings
 type OhMyZshConfig struct {
-	Install bool     `yaml:"install" mapstructure:"install"`
-	Plugins []string `yaml:"plugins" mapstructure:"plugins"`
-	Theme   string   `yaml:"theme" mapstructure:"theme"`
+	Install       bool                `yaml:"install" mapstructure:"install"`
+	Plugins       []string            `yaml:"plugins" mapstructure:"plugins"`
+	Theme         string              `yaml:"theme" mapstructure:"theme"`
+	InstallScript InstallScript       `yaml:"install_script" mapstructure:"install_script"`
+	Update        OhMyZshUpdateConfig `yaml:"update" mapstructure:"update"`
+}
+
+// Policies for a git checkout with uncommitted changes
+const (
+	LocalChangesStash = "stash"
+	LocalChangesSkip  = "skip"
+	LocalChangesAbort = "abort"
+)
+
+// OhMyZshUpdateConfig controls how update --ohmyzsh treats the Oh My Zsh,
+// plugin and theme checkouts
+type OhMyZshUpdateConfig struct {
+	// LocalChanges is stash, skip or abort
+	LocalChanges string `yaml:"local_changes" mapstructure:"local_changes"`
+	// Pins maps a checkout (oh-my-zsh or a plugin or theme directory name)
+	// to the tag, branch or commit it stays on
+	Pins map[string]string `yaml:"pins" mapstructure:"pins"`
 }
 
 // Powerlevel10kConfig contains Powerlevel10k settings
@@ -46,11 +148,102 @@ type Powerlevel10kConfig struct {
 	Style   string `yaml:"style" mapstructure:"style"`
 }
 
+// Runtime version managers
+const (
+	RuntimeManagerMise = "mise"
+	RuntimeManagerAsdf = "asdf"
+)
+
+// RuntimesConfig contains language runtime versions managed by mise or asdf
+type RuntimesConfig struct {
+	// Manager is mise, asdf or empty to skip runtimes
+	Manager string `yaml:"manager" mapstructure:"manager"`
+	// Tools maps a tool (node, python, go, java, terraform) to its
+	// versions. The first version is the global default.
+	Tools map[string][]string `yaml:"tools" mapstructure:"tools"`
+}
+
+// Package ecosystems
+const (
+	PackagesNpm   = "npm"
+	PackagesPipx  = "pipx"
+	PackagesCargo = "cargo"
+	PackagesGo    = "go"
+)
+
+// PackagesConfig maps an ecosystem (npm, pipx, cargo, go) to its global
+// packages. A package is pinned with name@version; go packages are module
+// paths such as golang.org/x/tools/gopls@v0.15.2.
+type PackagesConfig map[string][]string
+
+// Editors configured by the vscode section
+const (
+	EditorCode   = "code"
+	EditorCursor = "cursor"
+	EditorCodium = "codium"
+)
+
+// VSCodeConfig contains editor extensions and user settings
+type VSCodeConfig struct {
+	// Editor is the command line binary: code, cursor, codium or empty to
+	// skip the editor
+	Editor string `yaml:"editor" mapstructure:"editor"`
+	// Extensions are publisher.name IDs, pinned with publisher.name@version
+	Extensions []string `yaml:"extensions" mapstructure:"extensions"`
+	// Settings are merged into the editor's user settings.json
+	Settings map[string]any `yaml:"settings" mapstructure:"settings"`
+}
+
+// Archive types for downloads
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+	ArchiveNone  = "none"
+)
+
+// Download is a binary or archive fetched from a URL, verified and
+// installed into a directory
+type Download struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// URL may contain {{os}} and {{arch}}, e.g. darwin and arm64
+	URL string `yaml:"url" mapstructure:"url"`
+	// SHA256 is the checksum of the download, one for all architectures
+	// or one per architecture
+	SHA256 Checksums `yaml:"sha256" mapstructure:"sha256"`
+	// Archive is tar.gz, zip or none for a plain file; empty guesses from
+	// the URL
+	Archive string `yaml:"archive" mapstructure:"archive"`
+	// Files are globs of the archive paths to install, all files when
+	// empty. For a plain file the first entry renames it.
+	Files []string `yaml:"files" mapstructure:"files"`
+	// Dest is the directory the files are installed into
+	Dest string `yaml:"dest" mapstructure:"dest"`
+	// Mode is the octal file mode, e.g. "0755"; empty keeps the archive's
+	Mode FileMode `yaml:"mode" mapstructure:"mode"`
+}
+
+// FileMode is an octal file mode. YAML reads an unquoted 0755 as a
+// number, which is turned back into its octal digits.
+type FileMode string
+
+// Checksums maps an architecture (arm64, amd64) to a sha256. A single
+// checksum for all architectures has the key "*".
+type Checksums map[string]string
+
+// For returns the checksum for an architecture
+func (c Checksums) For(arch string) string {
+	if sum, ok := c[arch]; ok {
+		return sum
+	}
+	return c["*"]
+}
+
 // ShellConfig contains shell customization settings
 type ShellConfig struct {
 	Aliases     map[string]string `yaml:"aliases" mapstructure:"aliases"`
 	Environment map[string]string `yaml:"environment" mapstructure:"environment"`
 	ZshrcExtras []string          `yaml:"zshrc_extras" mapstructure:"zshrc_extras"`
+	SecretsFile string            `yaml:"secrets_file" mapstructure:"secrets_file"`
 }
 
 // MacOSConfig contains macOS system settings
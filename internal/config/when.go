@@ -0,0 +1,229 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/system"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/version"
+)
+
+// whenKey is the key that makes a section or list item conditional
+const whenKey = "when"
+
+// Condition restricts a section or list item to matching machines.
+// All set fields must match.
+type Condition struct {
+	Arch     string `yaml:"arch" mapstructure:"arch"`
+	MacOS    string `yaml:"macos" mapstructure:"macos"`
+	Hostname string `yaml:"hostname" mapstructure:"hostname"`
+	Profile  string `yaml:"profile" mapstructure:"profile"`
+}
+
+// archAliases maps alternative architecture names to Go's names
+var archAliases = map[string]string{
+	"x86_64":        "amd64",
+	"intel":         "amd64",
+	"aarch64":       "arm64",
+	"apple_silicon": "arm64",
+}
+
+// Matches reports whether the condition holds on the given system and profile
+func (c Condition) Matches(info system.Info, profile string) (bool, error) {
+	if c.Arch != "" {
+		arch := strings.ToLower(c.Arch)
+		if alias, ok := archAliases[arch]; ok {
+			arch = alias
+		}
+		if arch != info.Arch {
+			return false, nil
+		}
+	}
+
+	if c.MacOS != "" {
+		if info.MacOSVersion == "" {
+			return false, nil
+		}
+		ok, err := version.Satisfies(info.MacOSVersion, c.MacOS)
+		if err != nil {
+			return false, fmt.Errorf("macos: %w", err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	if c.Hostname != "" {
+		ok, err := matchHostname(c.Hostname, info.Hostname)
+		if err != nil {
+			return false, fmt.Errorf("hostname: %w", err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	if c.Profile != "" && c.Profile != profile {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// matchHostname matches a glob against the full and the short hostname
+func matchHostname(pattern, hostname string) (bool, error) {
+	pattern = strings.ToLower(pattern)
+	hostname = strings.ToLower(hostname)
+
+	short, _, _ := strings.Cut(hostname, ".")
+	for _, name := range []string{hostname, short} {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseCondition converts the raw value of a when: key into a Condition
+func parseCondition(raw any) (Condition, error) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return Condition{}, fmt.Errorf("when: must be a mapping, got %T", raw)
+	}
+
+	var c Condition
+	for key, value := range m {
+		s := fmt.Sprint(value)
+		switch key {
+		case "arch":
+			c.Arch = s
+		case "macos":
+			c.MacOS = s
+		case "hostname":
+			c.Hostname = s
+		case "profile":
+			c.Profile = s
+		default:
+			return Condition{}, fmt.Errorf("when: unknown condition %q (valid: arch, macos, hostname, profile)", key)
+		}
+	}
+	return c, nil
+}
+
+// configType is the schema a config layer is filtered against
+var configType = reflect.TypeOf(Config{})
+
+// applyConditions removes sections and list items whose when: condition
+// does not match and strips the when: keys from the rest. t is the schema
+// type of node: when: is a condition in sections (struct fields) and in list
+// items written as {value: x, when: ...}, which are replaced by x. In maps
+// with user keys, such as shell.aliases, it is an ordinary key.
+func applyConditions(node any, t reflect.Type, opts Options) (any, bool, error) {
+	if t == nil {
+		return node, true, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			matches, err := matchCondition(n, opts)
+			if err != nil || !matches {
+				return nil, false, err
+			}
+			return n, true, filterMap(n, func(key string) reflect.Type { return fieldType(t, key) }, opts)
+		case reflect.Map:
+			return n, true, filterMap(n, func(string) reflect.Type { return t.Elem() }, opts)
+		}
+
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return node, true, nil
+		}
+		items := make([]any, 0, len(n))
+		for i, item := range n {
+			if m, ok := item.(map[string]any); ok && isConditionalItem(m) {
+				matches, err := matchCondition(m, opts)
+				if err != nil {
+					return nil, false, fmt.Errorf("item %d: %w", i, err)
+				}
+				if !matches {
+					continue
+				}
+				item = m["value"]
+			}
+
+			filtered, keep, err := applyConditions(item, t.Elem(), opts)
+			if err != nil {
+				return nil, false, fmt.Errorf("item %d: %w", i, err)
+			}
+			if keep {
+				items = append(items, filtered)
+			}
+		}
+		return items, true, nil
+	}
+
+	return node, true, nil
+}
+
+// filterMap applies the conditions below each key of n. typeOf returns the
+// schema type of a key's value.
+func filterMap(n map[string]any, typeOf func(key string) reflect.Type, opts Options) error {
+	for key, value := range n {
+		filtered, keep, err := applyConditions(value, typeOf(key), opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if keep {
+			n[key] = filtered
+		} else {
+			delete(n, key)
+		}
+	}
+	return nil
+}
+
+// matchCondition evaluates and removes the when: key of a section or list
+// item. Without one the section always matches.
+func matchCondition(n map[string]any, opts Options) (bool, error) {
+	raw, ok := n[whenKey]
+	if !ok {
+		return true, nil
+	}
+	cond, err := parseCondition(raw)
+	if err != nil {
+		return false, err
+	}
+	delete(n, whenKey)
+	return cond.Matches(opts.System, opts.Profile)
+}
+
+// isConditionalItem reports whether a list item is a {value: x, when: ...}
+// wrapper
+func isConditionalItem(m map[string]any) bool {
+	_, hasValue := m["value"]
+	_, hasWhen := m[whenKey]
+	return hasValue && hasWhen && len(m) == 2
+}
+
+// fieldType returns the type of the struct field with the given yaml key, or
+// nil for an unknown key
+func fieldType(t reflect.Type, key string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); name == key {
+			return field.Type
+		}
+	}
+	return nil
+}
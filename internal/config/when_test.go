@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/system"
+)
+
+func TestConditionMatches(t *testing.T) {
+	info := system.Info{Arch: "arm64", MacOSVersion: "14.5", Hostname: "build-03.local"}
+
+	tests := []struct {
+		name string
+		cond Condition
+		want bool
+	}{
+		{"empty", Condition{}, true},
+		{"arch", Condition{Arch: "arm64"}, true},
+		{"arch alias", Condition{Arch: "apple_silicon"}, true},
+		{"other arch", Condition{Arch: "x86_64"}, false},
+		{"macos", Condition{MacOS: ">=14"}, true},
+		{"old macos", Condition{MacOS: "<14"}, false},
+		{"hostname glob", Condition{Hostname: "build-*"}, true},
+		{"hostname full", Condition{Hostname: "*.local"}, true},
+		{"other hostname", Condition{Hostname: "dev-*"}, false},
+		{"profile", Condition{Profile: "work"}, true},
+		{"other profile", Condition{Profile: "home"}, false},
+		{"all", Condition{Arch: "arm64", MacOS: "14", Hostname: "build-*", Profile: "work"}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.cond.Matches(info, "work")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConditionUnknownMacOSVersion(t *testing.T) {
+	ok, err := Condition{MacOS: ">=14"}.Matches(system.Info{Arch: "arm64"}, "")
+	if err != nil || ok {
+		t.Errorf("expected macos condition to fail without a version, got %v, %v", ok, err)
+	}
+}
+
+func TestLoadWithConditions(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "conditional.yaml")
+
+	configContent := `
+homebrew:
+  casks:
+    - iterm2
+    - value: docker
+      when:
+        arch: arm64
+    - value: virtualbox
+      when:
+        arch: amd64
+terminal:
+  oh_my_zsh:
+    plugins:
+      - git
+      - value: kubectl
+        when:
+          profile: work
+git:
+  when:
+    hostname: "build-*"
+  user:
+    name: "Build Bot"
+macos:
+  when:
+    macos: ">=14"
+  configure: false
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	opts := Options{
+		System:  system.Info{Arch: "arm64", MacOSVersion: "13.6", Hostname: "build-01"},
+		Profile: "home",
+	}
+
+	cfg, err := LoadWithOptions(configPath, opts)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if want := []string{"iterm2", "docker"}; !reflect.DeepEqual(cfg.Homebrew.CaskNames(), want) {
+		t.Errorf("expected casks %v, got %v", want, cfg.Homebrew.Casks)
+	}
+
+	if want := []string{"git"}; !reflect.DeepEqual(cfg.Terminal.OhMyZsh.Plugins, want) {
+		t.Errorf("expected plugins %v, got %v", want, cfg.Terminal.OhMyZsh.Plugins)
+	}
+
+	if cfg.Git.User.Name != "Build Bot" {
+		t.Errorf("expected git section to apply on build host, got %q", cfg.Git.User.Name)
+	}
+
+	if !cfg.MacOS.Configure {
+		t.Error("expected macos section to be skipped on macOS 13")
+	}
+}
+
+func TestLoadKeepsWhenInUserMaps(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "aliases.yaml")
+
+	// An alias named when is not a condition
+	configContent := `
+shell:
+  when:
+    profile: work
+  aliases:
+    when: "date +%s"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadWithOptions(configPath, Options{Profile: "work"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if got := cfg.Shell.Aliases["when"]; got != "date +%s" {
+		t.Errorf("expected alias when, got %q", got)
+	}
+}
+
+func TestLoadWithInvalidCondition(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "invalid.yaml")
+
+	configContent := `
+homebrew:
+  when:
+    cpu: m1
+  install: false
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	if _, err := LoadWithOptions(configPath, Options{}); err == nil {
+		t.Error("expected error for unknown condition")
+	}
+}
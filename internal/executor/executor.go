@@ -19,6 +19,9 @@ type Executor struct {
 	Verbose bool
 	Stdout  io.Writer
 	Stderr  io.Writer
+
+	redacted []string
+	env      []string
 }
 
 // New creates a new Executor
@@ -31,6 +34,36 @@ func New(dryRun, verbose bool) *Executor {
 	}
 }
 
+// Redact hides a value in printed commands and Result.Command, e.g. a resolved
+// secret. Stdout and Stderr are returned as-is so callers can parse them.
+func (e *Executor) Redact(value string) {
+	if value != "" {
+		e.redacted = append(e.redacted, value)
+	}
+}
+
+// SetEnv sets an environment variable for all commands run afterwards
+func (e *Executor) SetEnv(key, value string) {
+	e.env = append(e.env, key+"="+value)
+}
+
+// command creates an exec.Cmd with the extra environment applied
+func (e *Executor) command(ctx context.Context, name string, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
+	return cmd
+}
+
+// redact replaces redacted values in s with a mask
+func (e *Executor) redact(s string) string {
+	for _, value := range e.redacted {
+		s = strings.ReplaceAll(s, value, "********")
+	}
+	return s
+}
+
 // Result contains command execution result
 type Result struct {
 	Command  string
@@ -43,10 +76,20 @@ type Result struct {
 
 // Run executes a command and returns the result
 func (e *Executor) Run(ctx context.Context, name string, args ...string) (*Result, error) {
-	cmdStr := formatCommand(name, args)
+	return e.run(ctx, e.DryRun, name, args)
+}
+
+// Query executes a read-only command. It runs in dry-run mode too, so that
+// dry runs can show what would change.
+func (e *Executor) Query(ctx context.Context, name string, args ...string) (*Result, error) {
+	return e.run(ctx, false, name, args)
+}
+
+func (e *Executor) run(ctx context.Context, dryRun bool, name string, args []string) (*Result, error) {
+	cmdStr := e.redact(formatCommand(name, args))
 	startTime := time.Now()
 
-	if e.DryRun {
+	if dryRun {
 		color.New(color.FgYellow).Fprintf(e.Stdout, "[DRY-RUN] %s\n", cmdStr)
 		return &Result{
 			Command:  cmdStr,
@@ -60,7 +103,7 @@ func (e *Executor) Run(ctx context.Context, name string, args ...string) (*Resul
 		color.New(color.FgCyan).Fprintf(e.Stdout, "[EXEC] %s\n", cmdStr)
 	}
 
-	cmd := exec.CommandContext(ctx, name, args...)
+	cmd := e.command(ctx, name, args)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -96,7 +139,7 @@ func (e *Executor) RunShell(ctx context.Context, command string) (*Result, error
 
 // RunInteractive executes a command with interactive I/O
 func (e *Executor) RunInteractive(ctx context.Context, name string, args ...string) error {
-	cmdStr := formatCommand(name, args)
+	cmdStr := e.redact(formatCommand(name, args))
 
 	if e.DryRun {
 		color.New(color.FgYellow).Fprintf(e.Stdout, "[DRY-RUN] %s\n", cmdStr)
@@ -107,7 +150,7 @@ func (e *Executor) RunInteractive(ctx context.Context, name string, args ...stri
 		color.New(color.FgCyan).Fprintf(e.Stdout, "[EXEC] %s\n", cmdStr)
 	}
 
-	cmd := exec.CommandContext(ctx, name, args...)
+	cmd := e.command(ctx, name, args)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
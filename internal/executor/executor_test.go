@@ -94,3 +94,70 @@ func TestExecutorFailingCommand(t *testing.T) {
 		t.Errorf("expected exit code 1, got %d", result.ExitCode)
 	}
 }
+
+func TestExecutorRedact(t *testing.T) {
+	var stdout bytes.Buffer
+	exec := New(true, false)
+	exec.Stdout = &stdout
+	exec.Redact("s3cr3t")
+
+	result, err := exec.Run(context.Background(), "git", "config", "--global", "github.token", "s3cr3t")
+	if err != nil {
+		t.Fatalf("dry-run should not return error: %v", err)
+	}
+
+	if bytes.Contains(stdout.Bytes(), []byte("s3cr3t")) {
+		t.Errorf("secret leaked in output: %s", stdout.String())
+	}
+
+	if result.Command != "git config --global github.token ********" {
+		t.Errorf("unexpected command: %s", result.Command)
+	}
+}
+
+func TestExecutorRedactVerbose(t *testing.T) {
+	var stdout bytes.Buffer
+	exec := New(false, true)
+	exec.Stdout = &stdout
+	exec.Redact("s3cr3t")
+
+	result, err := exec.Run(context.Background(), "echo", "s3cr3t")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Contains(stdout.Bytes(), []byte("s3cr3t")) {
+		t.Errorf("secret leaked in output: %s", stdout.String())
+	}
+
+	if result.Stdout != "s3cr3t\n" {
+		t.Errorf("expected raw stdout, got %q", result.Stdout)
+	}
+}
+
+func TestExecutorSetEnv(t *testing.T) {
+	exec := New(false, false)
+	exec.SetEnv("SETUP_MAC_TEST_VALUE", "from-executor")
+
+	result, err := exec.RunShell(context.Background(), "echo $SETUP_MAC_TEST_VALUE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Stdout != "from-executor\n" {
+		t.Errorf("expected environment to be passed, got %q", result.Stdout)
+	}
+}
+
+func TestExecutorQueryRunsInDryRun(t *testing.T) {
+	exec := New(true, false)
+
+	result, err := exec.Query(context.Background(), "echo", "hello")
+	if err != nil {
+		t.Fatalf("failed to run query: %v", err)
+	}
+
+	if result.DryRun || result.Stdout != "hello\n" {
+		t.Errorf("expected query to run, got %+v", result)
+	}
+}
@@ -0,0 +1,204 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// brewInstallStatus is the outcome for one package of a batched brew install
+type brewInstallStatus int
+
+const (
+	brewStatusUnknown brewInstallStatus = iota
+	brewStatusInstalled
+	brewStatusAlreadyInstalled
+	brewStatusFailed
+)
+
+var (
+	brewFormulaPoured    = regexp.MustCompile(`^🍺\s+\S*/Cellar/([^/\s]+)/`)
+	brewCaskInstalled    = regexp.MustCompile(`^🍺\s+(\S+) was successfully installed`)
+	brewAlreadyInstalled = regexp.MustCompile(`^Warning: (\S+) \S+ is already installed`)
+	brewCaskAlready      = regexp.MustCompile(`^Warning: Cask '([^']+)' is already installed`)
+	brewNotUpgrading     = regexp.MustCompile(`^Warning: Not upgrading (\S+), the latest version is already installed`)
+	brewNoFormula        = regexp.MustCompile(`No available formula(?: or cask)? with the name "([^"]+)"`)
+	brewNotFound         = regexp.MustCompile(`^Error: No (?:formulae or casks|formulae|casks?) found for (\S+?)\.?$`)
+	brewCaskUnavailable  = regexp.MustCompile(`^Error: Cask '([^']+)' is unavailable`)
+	brewInstalling       = regexp.MustCompile(`^==> Installing (?:Cask )?(\S+)$`)
+	brewNamedError       = regexp.MustCompile(`^Error: (\S+): `)
+)
+
+// brewBatch is a group of packages installed with one brew command
+type brewBatch struct {
+	args     []string
+	packages []brewfile.Package
+}
+
+// groupByArgs groups packages sharing the same extra arguments, keeping the
+// order in which groups first appear
+func groupByArgs(packages []brewfile.Package) []brewBatch {
+	var batches []brewBatch
+	index := make(map[string]int)
+
+	for _, pkg := range packages {
+		key := strings.Join(pkg.Args, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(batches)
+			index[key] = i
+			batches = append(batches, brewBatch{args: pkg.Args})
+		}
+		batches[i].packages = append(batches[i].packages, pkg)
+	}
+
+	return batches
+}
+
+// shortName strips the tap from a fully qualified formula or cask name
+func shortName(name string) string {
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// parseBrewInstallOutput reads per-package results from the output of a
+// `brew install` for several packages. Errors without a package name are
+// attributed to the package brew was installing at the time.
+func parseBrewInstallOutput(output string, names []string) map[string]brewInstallStatus {
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[shortName(name)] = true
+	}
+
+	statuses := make(map[string]brewInstallStatus)
+	set := func(name string, status brewInstallStatus) {
+		name = shortName(name)
+		if !requested[name] {
+			return
+		}
+		// A failure wins over anything reported earlier
+		if statuses[name] != brewStatusFailed {
+			statuses[name] = status
+		}
+	}
+
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		if m := brewInstalling.FindStringSubmatch(line); m != nil {
+			current = shortName(m[1])
+			continue
+		}
+
+		switch {
+		case brewFormulaPoured.MatchString(line):
+			set(brewFormulaPoured.FindStringSubmatch(line)[1], brewStatusInstalled)
+		case brewCaskInstalled.MatchString(line):
+			set(brewCaskInstalled.FindStringSubmatch(line)[1], brewStatusInstalled)
+		case brewCaskAlready.MatchString(line):
+			set(brewCaskAlready.FindStringSubmatch(line)[1], brewStatusAlreadyInstalled)
+		case brewNotUpgrading.MatchString(line):
+			set(brewNotUpgrading.FindStringSubmatch(line)[1], brewStatusAlreadyInstalled)
+		case brewAlreadyInstalled.MatchString(line):
+			set(brewAlreadyInstalled.FindStringSubmatch(line)[1], brewStatusAlreadyInstalled)
+		case brewNoFormula.MatchString(line):
+			set(brewNoFormula.FindStringSubmatch(line)[1], brewStatusFailed)
+		case brewNotFound.MatchString(line):
+			set(brewNotFound.FindStringSubmatch(line)[1], brewStatusFailed)
+		case brewCaskUnavailable.MatchString(line):
+			set(brewCaskUnavailable.FindStringSubmatch(line)[1], brewStatusFailed)
+		case strings.HasPrefix(line, "Error:"):
+			if m := brewNamedError.FindStringSubmatch(line); m != nil && requested[shortName(m[1])] {
+				set(m[1], brewStatusFailed)
+			} else if current != "" {
+				set(current, brewStatusFailed)
+			}
+		}
+	}
+
+	return statuses
+}
+
+// installBatch installs packages with as few brew commands as possible and
+// returns the packages whose result is unclear and that should be retried
+// one by one
+func (h *HomebrewInstaller) installBatch(ctx context.Context, cask bool, packages []brewfile.Package) []brewfile.Package {
+	kind, prefix := "formulae", "Installed"
+	if cask {
+		kind, prefix = "casks", "Installed cask"
+	}
+
+	var retry []brewfile.Package
+	for _, batch := range groupByArgs(packages) {
+		// A single package gains nothing from batching
+		if len(batch.packages) == 1 {
+			retry = append(retry, batch.packages[0])
+			continue
+		}
+
+		names := make([]string, len(batch.packages))
+		for i, pkg := range batch.packages {
+			names[i] = pkg.Name
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Installing %d %s: %s", len(names), kind, strings.Join(names, ", ")))
+		spinner.Start()
+
+		args := []string{"install"}
+		if cask {
+			args = append(args, "--cask")
+		}
+		args = append(append(args, names...), batch.args...)
+
+		result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+		if result != nil && result.DryRun {
+			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install %d %s", len(names), kind))
+			continue
+		}
+
+		var statuses map[string]brewInstallStatus
+		if result != nil {
+			statuses = parseBrewInstallOutput(result.Stdout+"\n"+result.Stderr, names)
+		}
+
+		var done, failed []brewfile.Package
+		for _, pkg := range batch.packages {
+			status := statuses[shortName(pkg.Name)]
+			// On success brew installed everything it did not complain about
+			if err == nil && status == brewStatusUnknown {
+				status = brewStatusInstalled
+			}
+
+			switch status {
+			case brewStatusInstalled, brewStatusAlreadyInstalled:
+				done = append(done, pkg)
+			default:
+				failed = append(failed, pkg)
+			}
+		}
+
+		if len(failed) == 0 {
+			spinner.Success(fmt.Sprintf("%s %d %s", prefix, len(done), kind))
+		} else {
+			spinner.Warning(fmt.Sprintf("%s %d of %d %s, retrying %d individually", prefix, len(done), len(names), kind, len(failed)))
+		}
+		for _, pkg := range done {
+			if statuses[shortName(pkg.Name)] == brewStatusAlreadyInstalled {
+				ui.PrintInfo(fmt.Sprintf("Already installed: %s", pkg.Name))
+			} else {
+				ui.PrintSuccess(fmt.Sprintf("%s: %s", prefix, pkg.Name))
+			}
+		}
+
+		retry = append(retry, failed...)
+	}
+
+	return retry
+}
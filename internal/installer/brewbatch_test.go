@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+// fakeBinary writes an executable shell script to dir and puts dir on PATH
+func fakeBinary(t *testing.T, dir, name, script string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("failed to write fake %s: %v", name, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Output of `brew install jq wget notaformula` with one unknown formula
+const brewFormulaeOutput = `==> Fetching dependencies for jq: oniguruma
+==> Fetching oniguruma
+==> Downloading https://ghcr.io/v2/homebrew/core/oniguruma/manifests/6.9.9
+==> Fetching jq
+==> Installing dependencies for jq: oniguruma
+==> Installing jq dependency: oniguruma
+==> Pouring oniguruma--6.9.9.arm64_sonoma.bottle.tar.gz
+🍺  /opt/homebrew/Cellar/oniguruma/6.9.9: 15 files, 1.4MB
+==> Installing jq
+==> Pouring jq--1.7.1.arm64_sonoma.bottle.tar.gz
+🍺  /opt/homebrew/Cellar/jq/1.7.1: 19 files, 1.3MB
+Warning: wget 1.24.5 is already installed and up-to-date.
+To reinstall 1.24.5, run:
+  brew reinstall wget
+Warning: No available formula with the name "notaformula". Did you mean notmuch?
+Error: No formulae or casks found for notaformula.
+`
+
+// Output of `brew install --cask rectangle iterm2 docker acme/tools/widget`
+const brewCasksOutput = `==> Downloading https://github.com/rxhanson/Rectangle/releases/download/v0.80/Rectangle0.80.dmg
+==> Installing Cask rectangle
+==> Moving App 'Rectangle.app' to '/Applications/Rectangle.app'
+🍺  rectangle was successfully installed!
+Warning: Cask 'iterm2' is already installed.
+==> Installing Cask docker
+Error: It seems there is already an App at '/Applications/Docker.app'.
+==> Installing Cask widget
+🍺  widget was successfully installed!
+`
+
+func TestParseBrewInstallOutputFormulae(t *testing.T) {
+	got := parseBrewInstallOutput(brewFormulaeOutput, []string{"jq", "wget", "notaformula"})
+	want := map[string]brewInstallStatus{
+		"jq":          brewStatusInstalled,
+		"wget":        brewStatusAlreadyInstalled,
+		"notaformula": brewStatusFailed,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseBrewInstallOutputCasks(t *testing.T) {
+	got := parseBrewInstallOutput(brewCasksOutput, []string{"rectangle", "iterm2", "docker", "acme/tools/widget"})
+	want := map[string]brewInstallStatus{
+		"rectangle": brewStatusInstalled,
+		"iterm2":    brewStatusAlreadyInstalled,
+		"docker":    brewStatusFailed,
+		"widget":    brewStatusInstalled,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGroupByArgs(t *testing.T) {
+	batches := groupByArgs([]brewfile.Package{
+		{Name: "a"},
+		{Name: "b", Args: []string{"--HEAD"}},
+		{Name: "c"},
+	})
+
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+	if len(batches[0].packages) != 2 || batches[0].packages[1].Name != "c" {
+		t.Errorf("expected a and c in the first batch, got %+v", batches[0].packages)
+	}
+	if !reflect.DeepEqual(batches[1].args, []string{"--HEAD"}) {
+		t.Errorf("expected --HEAD batch, got %v", batches[1].args)
+	}
+}
+
+func TestInstallBatchRetriesFailures(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	fakeBinary(t, dir, "brew", `
+echo "$*" >> "`+calls+`"
+for arg in "$@"; do
+  case "$arg" in
+    install) ;;
+    typo) echo "Error: No available formula with the name \"typo\"." >&2; exit 1 ;;
+    *) echo "🍺  /opt/homebrew/Cellar/$arg/1.0: 3 files, 12KB" ;;
+  esac
+done
+`)
+
+	h := NewHomebrewInstaller(NewContext(&config.Config{}, false, false))
+	retry := h.installBatch(context.Background(), false, []brewfile.Package{
+		{Name: "jq"}, {Name: "typo"}, {Name: "tree"}, {Name: "head", Args: []string{"--HEAD"}},
+	})
+
+	// typo failed the batch before tree was reached, head is alone in its group
+	var names []string
+	for _, pkg := range retry {
+		names = append(names, pkg.Name)
+	}
+	if want := []string{"typo", "tree", "head"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected retries %v, got %v", want, names)
+	}
+
+	log, _ := os.ReadFile(calls)
+	if got := strings.TrimSpace(string(log)); got != "install jq typo tree" {
+		t.Errorf("expected one batched install, got:\n%s", got)
+	}
+}
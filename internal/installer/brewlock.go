@@ -0,0 +1,277 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/version"
+)
+
+// brewLockVersion is the format version written to brew.lock.json
+const brewLockVersion = 1
+
+// BrewLock records the exact versions of the configured formulae and casks
+// so that other machines can be compared against it
+type BrewLock struct {
+	Version  int                  `json:"version"`
+	Formulae map[string]LockEntry `json:"formulae"`
+	Casks    map[string]LockEntry `json:"casks"`
+}
+
+// LockEntry is the resolved version of one package
+type LockEntry struct {
+	Version string `json:"version"`
+	Pinned  bool   `json:"pinned,omitempty"`
+}
+
+// NewBrewLock builds a lock from the installed state. Packages that are not
+// installed are left out.
+func NewBrewLock(state *BrewState, packages *brewfile.Brewfile) *BrewLock {
+	lock := &BrewLock{
+		Version:  brewLockVersion,
+		Formulae: make(map[string]LockEntry),
+		Casks:    make(map[string]LockEntry),
+	}
+
+	for _, pkg := range packages.Brews {
+		if f, ok := state.Formula(pkg.Name); ok {
+			lock.Formulae[pkg.Name] = LockEntry{Version: f.Installed(), Pinned: f.Pinned}
+		}
+	}
+	for _, pkg := range packages.Casks {
+		if c, ok := state.Cask(pkg.Name); ok && c.Version != "" {
+			lock.Casks[pkg.Name] = LockEntry{Version: c.Version}
+		}
+	}
+
+	return lock
+}
+
+// ReadBrewLock reads a lock file
+func ReadBrewLock(path string) (*BrewLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock BrewLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Version > brewLockVersion {
+		return nil, fmt.Errorf("%s: unsupported lock file version %d", path, lock.Version)
+	}
+	return &lock, nil
+}
+
+// Write writes the lock file, creating its directory if needed
+func (l *BrewLock) Write(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Diff compares the lock with the lock of another machine and describes
+// every difference, sorted by package
+func (l *BrewLock) Diff(current *BrewLock) []string {
+	var diffs []string
+	diffs = append(diffs, diffEntries("formula", l.Formulae, current.Formulae)...)
+	diffs = append(diffs, diffEntries("cask", l.Casks, current.Casks)...)
+	return diffs
+}
+
+func diffEntries(kind string, locked, current map[string]LockEntry) []string {
+	names := make(map[string]bool)
+	for name := range locked {
+		names[name] = true
+	}
+	for name := range current {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, name := range sorted {
+		want, inLock := locked[name]
+		have, installed := current[name]
+
+		switch {
+		case !installed:
+			diffs = append(diffs, fmt.Sprintf("%s %s: locked at %s, not installed", kind, name, want.Version))
+		case !inLock:
+			diffs = append(diffs, fmt.Sprintf("%s %s: %s installed, not in lock", kind, name, have.Version))
+		case want.Version != have.Version:
+			diffs = append(diffs, fmt.Sprintf("%s %s: locked at %s, %s installed", kind, name, want.Version, have.Version))
+		case want.Pinned != have.Pinned:
+			diffs = append(diffs, fmt.Sprintf("%s %s: pinned is %t in lock, %t here", kind, name, want.Pinned, have.Pinned))
+		}
+	}
+	return diffs
+}
+
+// Installed returns the newest installed version of the formula
+func (f *BrewFormula) Installed() string {
+	installed := ""
+	for _, v := range f.Versions {
+		if installed == "" || version.Compare(bottleVersion(v), bottleVersion(installed)) > 0 {
+			installed = v
+		}
+	}
+	return installed
+}
+
+// bottleVersion strips the Homebrew revision suffix (1.7.1_1 → 1.7.1)
+func bottleVersion(v string) string {
+	base, _, _ := strings.Cut(v, "_")
+	return base
+}
+
+// splitUpgrades sorts the outdated formulae into the ones that can be upgraded
+// and the ones held back because the new version breaks their
+// version_constraint. Pinned formulae are skipped by brew itself.
+func splitUpgrades(state *BrewState, formulae []config.Formula) (upgrade, held []string) {
+	constraints := make(map[*BrewFormula]string)
+	for _, f := range formulae {
+		if installed, ok := state.Formula(f.Name); ok && f.VersionConstraint != "" {
+			constraints[installed] = f.VersionConstraint
+		}
+	}
+
+	for _, f := range state.Formulae {
+		if !f.Outdated || f.Pinned {
+			continue
+		}
+		if constraint, ok := constraints[f]; ok {
+			if ok, err := version.Satisfies(bottleVersion(f.Latest), constraint); err != nil || !ok {
+				held = append(held, f.Name)
+				continue
+			}
+		}
+		upgrade = append(upgrade, f.Name)
+	}
+
+	sort.Strings(upgrade)
+	sort.Strings(held)
+	return upgrade, held
+}
+
+// pinFormulae runs brew pin for formulae with pin: true that are not pinned yet
+func pinFormulae(ctx context.Context, exec *executor.Executor, state *BrewState, formulae []config.Formula, dryRun bool) {
+	for _, f := range formulae {
+		if !f.Pin {
+			continue
+		}
+
+		installed, ok := state.Formula(f.Name)
+		if ok && installed.Pinned {
+			continue
+		}
+		if !ok && !dryRun {
+			ui.PrintWarning(fmt.Sprintf("Cannot pin %s: not installed", f.Name))
+			continue
+		}
+
+		result, err := exec.Run(ctx, "brew", "pin", f.Name)
+		switch {
+		case err != nil:
+			ui.PrintWarning(fmt.Sprintf("Failed to pin %s: %v", f.Name, err))
+		case result.DryRun:
+			ui.PrintDryRun(fmt.Sprintf("brew pin %s", f.Name))
+		default:
+			ui.PrintSuccess(fmt.Sprintf("Pinned: %s", f.Name))
+		}
+	}
+}
+
+// formulaePinned checks if every formula with pin: true is installed and pinned
+func formulaePinned(state *BrewState, formulae []config.Formula) bool {
+	for _, f := range formulae {
+		if !f.Pin {
+			continue
+		}
+		if installed, ok := state.Formula(f.Name); !ok || !installed.Pinned {
+			return false
+		}
+	}
+	return true
+}
+
+// brewLockCurrent checks if the lock file at path matches the installed
+// versions. Without a lock file configured there is nothing to write.
+func brewLockCurrent(state *BrewState, packages *brewfile.Brewfile, path string) bool {
+	if path == "" {
+		return true
+	}
+	locked, err := ReadBrewLock(path)
+	if err != nil {
+		return false
+	}
+	return len(locked.Diff(NewBrewLock(state, packages))) == 0
+}
+
+// checkConstraints warns about installed formulae outside their version_constraint
+func checkConstraints(state *BrewState, formulae []config.Formula) {
+	for _, f := range formulae {
+		if f.VersionConstraint == "" {
+			continue
+		}
+		installed, ok := state.Formula(f.Name)
+		if !ok {
+			continue
+		}
+
+		v := bottleVersion(installed.Installed())
+		if ok, err := version.Satisfies(v, f.VersionConstraint); err == nil && !ok {
+			ui.PrintWarning(fmt.Sprintf("%s %s does not satisfy %s (install a versioned formula such as %s@<major> instead)",
+				f.Name, v, f.VersionConstraint, f.Name))
+		}
+	}
+}
+
+// writeBrewLock records the installed versions in the configured lock file
+func writeBrewLock(ctx context.Context, exec *executor.Executor, cfg config.HomebrewConfig, dryRun bool) {
+	if cfg.LockFile == "" {
+		return
+	}
+	if dryRun {
+		ui.PrintDryRun(fmt.Sprintf("Write lock file %s", cfg.LockFile))
+		return
+	}
+
+	packages, err := BrewPackages(cfg)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Lock file not written: %v", err))
+		return
+	}
+	state, err := LoadBrewState(ctx, exec)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Lock file not written: %v", err))
+		return
+	}
+
+	if err := NewBrewLock(state, packages).Write(cfg.LockFile); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to write lock file: %v", err))
+		return
+	}
+	ui.PrintInfo(fmt.Sprintf("Lock file written: %s", cfg.LockFile))
+}
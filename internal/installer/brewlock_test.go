@@ -0,0 +1,100 @@
+package installer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestNewBrewLock(t *testing.T) {
+	state := loadFixture(t, "brew-info-installed.json", ParseBrewInfo)
+	packages := &brewfile.Brewfile{
+		Brews: []brewfile.Package{{Name: "jq"}, {Name: "node@18"}, {Name: "not-installed"}},
+		Casks: []brewfile.Package{{Name: "iterm2"}, {Name: "docker"}},
+	}
+
+	lock := NewBrewLock(state, packages)
+
+	want := map[string]LockEntry{
+		"jq":      {Version: "1.7.1"},
+		"node@18": {Version: "18.19.0", Pinned: true},
+	}
+	if !reflect.DeepEqual(lock.Formulae, want) {
+		t.Errorf("formulae = %+v, want %+v", lock.Formulae, want)
+	}
+	if len(lock.Casks) != 1 || lock.Casks["iterm2"].Version != "3.5.2" {
+		t.Errorf("casks = %+v", lock.Casks)
+	}
+}
+
+func TestBrewLockRoundTripAndDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "brew.lock.json")
+	locked := &BrewLock{
+		Version: brewLockVersion,
+		Formulae: map[string]LockEntry{
+			"jq":   {Version: "1.7.1"},
+			"node": {Version: "22.1.0", Pinned: true},
+			"wget": {Version: "1.24.5"},
+		},
+		Casks: map[string]LockEntry{"iterm2": {Version: "3.5.2"}},
+	}
+	if err := locked.Write(path); err != nil {
+		t.Fatalf("failed to write lock: %v", err)
+	}
+
+	read, err := ReadBrewLock(path)
+	if err != nil {
+		t.Fatalf("failed to read lock: %v", err)
+	}
+	if diffs := read.Diff(locked); len(diffs) != 0 {
+		t.Errorf("expected round trip without differences, got %v", diffs)
+	}
+
+	current := &BrewLock{
+		Formulae: map[string]LockEntry{
+			"jq":   {Version: "1.7.1"},
+			"node": {Version: "22.1.0"},
+			"tree": {Version: "2.1.1"},
+		},
+		Casks: map[string]LockEntry{"iterm2": {Version: "3.5.4"}},
+	}
+
+	want := []string{
+		"formula node: pinned is true in lock, false here",
+		"formula tree: 2.1.1 installed, not in lock",
+		"formula wget: locked at 1.24.5, not installed",
+		"cask iterm2: locked at 3.5.2, 3.5.4 installed",
+	}
+	if diffs := read.Diff(current); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("diff = %q, want %q", diffs, want)
+	}
+}
+
+func TestSplitUpgrades(t *testing.T) {
+	state := loadFixture(t, "brew-info-installed.json", ParseBrewInfo)
+
+	// node@18 is pinned, python@3.12 would move to 3.12.5
+	upgrade, held := splitUpgrades(state, []config.Formula{
+		{Name: "python@3.12", VersionConstraint: "<3.12.5"},
+	})
+	if len(upgrade) != 0 || !reflect.DeepEqual(held, []string{"python@3.12"}) {
+		t.Errorf("upgrade = %v, held = %v", upgrade, held)
+	}
+
+	upgrade, held = splitUpgrades(state, []config.Formula{
+		{Name: "python@3.12", VersionConstraint: "3.12"},
+	})
+	if !reflect.DeepEqual(upgrade, []string{"python@3.12"}) || len(held) != 0 {
+		t.Errorf("upgrade = %v, held = %v", upgrade, held)
+	}
+}
+
+func TestBrewFormulaInstalled(t *testing.T) {
+	f := &BrewFormula{Versions: []string{"1.10.0", "1.9.2_1"}}
+	if got := f.Installed(); got != "1.10.0" {
+		t.Errorf("Installed() = %q, want 1.10.0", got)
+	}
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// OutdatedPackage is a formula or cask reported by brew outdated --json=v2
+type OutdatedPackage struct {
+	Name              string   `json:"name"`
+	InstalledVersions []string `json:"installed_versions"`
+	CurrentVersion    string   `json:"current_version"`
+	Pinned            bool     `json:"pinned"`
+	Cask              bool     `json:"-"`
+	// Greedy is set for casks that update themselves
+	Greedy bool `json:"-"`
+}
+
+// Installed returns the newest installed version
+func (p OutdatedPackage) Installed() string {
+	if len(p.InstalledVersions) == 0 {
+		return ""
+	}
+	return p.InstalledVersions[len(p.InstalledVersions)-1]
+}
+
+// String describes the upgrade, e.g. "git 2.44.0 → 2.45.1"
+func (p OutdatedPackage) String() string {
+	return fmt.Sprintf("%s %s → %s", p.Name, p.Installed(), p.CurrentVersion)
+}
+
+// BrewOutdated holds the outdated formulae and casks
+type BrewOutdated struct {
+	Formulae []OutdatedPackage `json:"formulae"`
+	Casks    []OutdatedPackage `json:"casks"`
+}
+
+// ParseBrewOutdated reads brew outdated --json=v2 output
+func ParseBrewOutdated(data []byte) (*BrewOutdated, error) {
+	var outdated BrewOutdated
+	if err := json.Unmarshal(data, &outdated); err != nil {
+		return nil, fmt.Errorf("failed to parse brew outdated: %w", err)
+	}
+	for i := range outdated.Casks {
+		outdated.Casks[i].Cask = true
+	}
+	return &outdated, nil
+}
+
+// LoadBrewOutdated queries the outdated packages. With greedy, casks that
+// update themselves are included.
+func LoadBrewOutdated(ctx context.Context, exec *executor.Executor, greedy bool) (*BrewOutdated, error) {
+	args := []string{"outdated", "--json=v2"}
+	if greedy {
+		args = append(args, "--greedy")
+	}
+
+	result, err := exec.Query(ctx, "brew", args...)
+	if err != nil {
+		return nil, fmt.Errorf("brew outdated failed: %w", err)
+	}
+	return ParseBrewOutdated([]byte(result.Stdout))
+}
+
+// addGreedyCasks adds the casks only listed by brew outdated --greedy,
+// all of them with all set, otherwise those named in greedy. The others are
+// returned as skipped.
+func addGreedyCasks(casks, greedyCasks []OutdatedPackage, all bool, greedy []string) ([]OutdatedPackage, []SkippedPackage) {
+	listed := make(map[string]bool)
+	for _, c := range casks {
+		listed[c.Name] = true
+	}
+
+	var skipped []SkippedPackage
+	for _, c := range greedyCasks {
+		switch {
+		case listed[c.Name]:
+		case all || matchesPackage(greedy, c.Name):
+			c.Greedy = true
+			casks = append(casks, c)
+		default:
+			skipped = append(skipped, SkippedPackage{Name: c.Name, Kind: "cask", Reason: SkipAutoUpdates})
+		}
+	}
+	return casks, skipped
+}
+
+// selectUpgrades drops pinned and held packages and applies the only and
+// exclude globs. Globs match the full name or the name without the tap.
+func selectUpgrades(packages []OutdatedPackage, only, exclude, held []string) (selected []OutdatedPackage, skipped []SkippedPackage) {
+	for _, p := range packages {
+		reason := ""
+		switch {
+		case p.Pinned:
+			reason = SkipPinned
+		case matchesPackage(held, p.Name):
+			reason = SkipHeld
+		case len(only) > 0 && !matchesPackage(only, p.Name):
+			reason = SkipNotSelected
+		case matchesPackage(exclude, p.Name):
+			reason = SkipExcluded
+		}
+
+		if reason == "" {
+			selected = append(selected, p)
+		} else {
+			skipped = append(skipped, SkippedPackage{Name: p.Name, Kind: packageKind(p), Reason: reason})
+		}
+	}
+	return selected, skipped
+}
+
+// matchesPackage reports whether a glob matches the name or its short form
+func matchesPackage(patterns []string, name string) bool {
+	return ignored(patterns, name, path.Base(name))
+}
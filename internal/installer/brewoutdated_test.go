@@ -0,0 +1,172 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestParseBrewOutdated(t *testing.T) {
+	outdated := loadFixture(t, "brew-outdated.json", ParseBrewOutdated)
+
+	if len(outdated.Formulae) != 4 || len(outdated.Casks) != 1 {
+		t.Fatalf("expected 4 formulae and 1 cask, got %d and %d", len(outdated.Formulae), len(outdated.Casks))
+	}
+	if got := outdated.Formulae[0].String(); got != "git 2.44.0 → 2.45.1" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if !outdated.Formulae[2].Pinned || outdated.Formulae[0].Cask || !outdated.Casks[0].Cask {
+		t.Errorf("unexpected flags: %+v", outdated)
+	}
+}
+
+func TestSelectUpgrades(t *testing.T) {
+	outdated := loadFixture(t, "brew-outdated.json", ParseBrewOutdated)
+	packages := append(outdated.Formulae, outdated.Casks...)
+
+	tests := []struct {
+		name    string
+		only    []string
+		exclude []string
+		held    []string
+		want    []string
+		skipped []string
+	}{
+		{"pinned skipped", nil, nil, nil,
+			[]string{"git", "postgresql@16", "hashicorp/tap/terraform", "docker"},
+			[]string{"node: pinned"}},
+		{"exclude globs", nil, []string{"docker", "postgresql@*"}, nil,
+			[]string{"git", "hashicorp/tap/terraform"},
+			[]string{"postgresql@16: excluded", "node: pinned", "docker: excluded"}},
+		{"only short name", []string{"terraform"}, nil, nil,
+			[]string{"hashicorp/tap/terraform"},
+			[]string{"git: not selected", "postgresql@16: not selected", "node: pinned", "docker: not selected"}},
+		{"only and exclude", []string{"git", "docker"}, []string{"docker"}, nil,
+			[]string{"git"},
+			[]string{"postgresql@16: not selected", "node: pinned", "hashicorp/tap/terraform: not selected", "docker: excluded"}},
+		{"held", nil, nil, []string{"git"},
+			[]string{"postgresql@16", "hashicorp/tap/terraform", "docker"},
+			[]string{"git: version_constraint", "node: pinned"}},
+	}
+
+	for _, tt := range tests {
+		selected, skipped := selectUpgrades(packages, tt.only, tt.exclude, tt.held)
+		if got := outdatedNames(selected); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+
+		var reasons []string
+		for _, s := range skipped {
+			reasons = append(reasons, s.Name+": "+s.Reason)
+		}
+		if !reflect.DeepEqual(reasons, tt.skipped) {
+			t.Errorf("%s: skipped %v, want %v", tt.name, reasons, tt.skipped)
+		}
+	}
+}
+
+func TestAddGreedyCasks(t *testing.T) {
+	casks := loadFixture(t, "brew-outdated.json", ParseBrewOutdated).Casks
+	greedy := loadFixture(t, "brew-outdated-greedy.json", ParseBrewOutdated).Casks
+
+	got, skipped := addGreedyCasks(casks, greedy, false, []string{"firefox"})
+	if names := outdatedNames(got); !reflect.DeepEqual(names, []string{"docker", "firefox"}) {
+		t.Errorf("per-cask greedy: got %v", names)
+	}
+	if got[0].Greedy || !got[1].Greedy {
+		t.Errorf("expected only firefox to be greedy: %+v", got)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "slack" || skipped[0].Reason != SkipAutoUpdates {
+		t.Errorf("expected slack to be skipped, got %+v", skipped)
+	}
+
+	got, skipped = addGreedyCasks(casks, greedy, true, nil)
+	if names := outdatedNames(got); !reflect.DeepEqual(names, []string{"docker", "firefox", "slack"}) || len(skipped) != 0 {
+		t.Errorf("all greedy: got %v, skipped %v", names, skipped)
+	}
+}
+
+func TestHomebrewUpdaterUpgradesSelection(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	outdated, _ := filepath.Abs(filepath.Join("testdata", "brew-outdated.json"))
+	greedy, _ := filepath.Abs(filepath.Join("testdata", "brew-outdated-greedy.json"))
+	// Nothing is outdated once brew upgrade has run
+	fakeBinary(t, dir, "brew", `
+if [ "$1" = "outdated" ] && [ -f "`+calls+`" ] && grep -q upgrade "`+calls+`"; then
+  echo '{"formulae": [], "casks": []}'
+  exit 0
+fi
+case "$1 $2 $3" in
+  "info --json=v2 --installed") echo '{"formulae": [], "casks": []}' ;;
+  "outdated --json=v2 --greedy") cat "`+greedy+`" ;;
+  "outdated --json=v2 ") cat "`+outdated+`" ;;
+  *) echo "$*" >> "`+calls+`" ;;
+esac
+`)
+
+	cfg := &config.Config{}
+	cfg.Homebrew.Casks = []config.Cask{{Name: "firefox", Greedy: true}}
+	cfg.Homebrew.Update.Exclude = []string{"postgresql@*"}
+	u := NewHomebrewUpdater(NewContext(cfg, false, false))
+	u.Only = []string{"git", "docker", "firefox", "postgresql@16"}
+
+	report, err := u.Update(context.Background())
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	want := "update\nupgrade --formula git\nupgrade --cask --greedy docker firefox\ncleanup"
+	if got := strings.TrimSpace(string(log)); got != want {
+		t.Errorf("unexpected brew calls:\n%s\nwant:\n%s", got, want)
+	}
+
+	wantUpgraded := []PackageChange{
+		{Name: "git", Kind: "formula", From: "2.44.0", To: "2.45.1"},
+		{Name: "docker", Kind: "cask", From: "4.29.0", To: "4.30.0"},
+		{Name: "firefox", Kind: "cask", From: "125.0.3", To: "126.0"},
+	}
+	if !reflect.DeepEqual(report.Upgraded, wantUpgraded) {
+		t.Errorf("unexpected upgrades: %+v", report.Upgraded)
+	}
+	if len(report.Skipped) != 4 {
+		t.Errorf("expected postgresql@16, node, terraform and slack to be skipped, got %+v", report.Skipped)
+	}
+}
+
+func TestHomebrewUpdaterFailedFormulaeSkipsCasks(t *testing.T) {
+	dir := t.TempDir()
+	outdated, _ := filepath.Abs(filepath.Join("testdata", "brew-outdated.json"))
+	fakeBinary(t, dir, "brew", `
+case "$1 $2 $3" in
+  "info --json=v2 --installed") echo '{"formulae": [], "casks": []}' ;;
+  "outdated --json=v2 "*) cat "`+outdated+`" ;;
+  "upgrade --formula git") exit 1 ;;
+esac
+`)
+
+	u := NewHomebrewUpdater(NewContext(&config.Config{}, false, false))
+	u.Only = []string{"git", "docker"}
+
+	report, err := u.Update(context.Background())
+	if err == nil {
+		t.Fatal("expected the failed formula upgrade to be returned")
+	}
+
+	// docker was never upgraded, so it is neither upgraded nor failed
+	want := []SkippedPackage{{Name: "git", Kind: "formula", Reason: SkipFailed}}
+	if len(report.Upgraded) != 0 || !reflect.DeepEqual(report.Skipped[len(report.Skipped)-1:], want) {
+		t.Errorf("unexpected report: upgraded %+v, skipped %+v", report.Upgraded, report.Skipped)
+	}
+	for _, s := range report.Skipped {
+		if s.Name == "docker" && s.Reason == SkipFailed {
+			t.Errorf("expected docker not to be recorded as failed: %+v", report.Skipped)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// PruneCandidates returns the top-level formulae and the casks that were
+// installed on request but are not declared in packages. Formulae other
+// formulae depend on are kept, as are names matching an ignore glob.
+func PruneCandidates(state *BrewState, packages *brewfile.Brewfile, ignore []string) (formulae, casks []string) {
+	keep := make(map[*BrewFormula]bool)
+	for _, pkg := range packages.Brews {
+		if f, ok := state.Formula(pkg.Name); ok {
+			keep[f] = true
+		}
+	}
+	// mas is installed for the App Store apps
+	if len(packages.Mas) > 0 {
+		if f, ok := state.Formula("mas"); ok {
+			keep[f] = true
+		}
+	}
+
+	required := make(map[string]bool)
+	for _, f := range state.Formulae {
+		for _, dep := range f.Dependencies {
+			required[dep] = true
+		}
+	}
+
+	for _, f := range state.Formulae {
+		if !f.OnRequest || len(f.Versions) == 0 || keep[f] || required[f.FullName] {
+			continue
+		}
+		if ignored(ignore, f.Name, f.FullName) {
+			continue
+		}
+		formulae = append(formulae, f.FullName)
+	}
+
+	declared := make(map[*BrewCask]bool)
+	for _, pkg := range packages.Casks {
+		if c, ok := state.Cask(pkg.Name); ok {
+			declared[c] = true
+		}
+	}
+
+	for _, c := range state.Casks {
+		if c.Version == "" || declared[c] || ignored(ignore, c.Token, c.FullToken) {
+			continue
+		}
+		casks = append(casks, c.FullToken)
+	}
+
+	sort.Strings(formulae)
+	sort.Strings(casks)
+	return formulae, casks
+}
+
+// declaredPackages adds the formulae the config uses without listing them
+// as formulae, service formulae and the runtime manager, to packages
+func declaredPackages(cfg *config.Config, packages *brewfile.Brewfile) *brewfile.Brewfile {
+	declared := *packages
+	declared.Brews = append([]brewfile.Package{}, packages.Brews...)
+	for _, service := range cfg.Homebrew.Services {
+		declared.Brews = append(declared.Brews, brewfile.Package{Name: service.Name})
+	}
+	if cfg.Runtimes.Manager != "" {
+		declared.Brews = append(declared.Brews, brewfile.Package{Name: cfg.Runtimes.Manager})
+	}
+	return &declared
+}
+
+// ignored reports whether any of the names matches an ignore glob
+func ignored(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prune uninstalls undeclared formulae and casks after confirmation
+func (h *HomebrewInstaller) prune(ctx context.Context, packages *brewfile.Brewfile) error {
+	ui.PrintStep("Looking for packages not in the config...")
+
+	state, err := LoadBrewState(ctx, h.ctx.Executor)
+	if err != nil {
+		return err
+	}
+
+	declared := declaredPackages(h.ctx.Config, packages)
+	formulae, casks := PruneCandidates(state, declared, h.ctx.Config.Homebrew.PruneIgnore)
+	if len(formulae)+len(casks) == 0 {
+		ui.PrintInfo("No undeclared packages to remove")
+		return nil
+	}
+
+	if len(formulae) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Formulae not in the config: %s", strings.Join(formulae, ", ")))
+	}
+	if len(casks) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Casks not in the config: %s", strings.Join(casks, ", ")))
+	}
+
+	if !h.ctx.DryRun {
+		label := fmt.Sprintf("Uninstall %d package(s)?", len(formulae)+len(casks))
+		confirm, err := h.ctx.Prompt.Confirm(label, false)
+		if err != nil || !confirm {
+			ui.PrintInfo("Prune skipped (add packages to homebrew.prune_ignore to keep them)")
+			return nil
+		}
+	}
+
+	if len(formulae) > 0 {
+		h.uninstall(ctx, "formulae", append([]string{"uninstall", "--formula"}, formulae...))
+	}
+	if len(casks) > 0 {
+		h.uninstall(ctx, "casks", append([]string{"uninstall", "--cask"}, casks...))
+	}
+
+	return nil
+}
+
+func (h *HomebrewInstaller) uninstall(ctx context.Context, kind string, args []string) {
+	count := len(args) - 2
+
+	spinner := ui.NewSpinner(fmt.Sprintf("Uninstalling %d %s...", count, kind))
+	spinner.Start()
+
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+	switch {
+	case err != nil:
+		spinner.Fail(fmt.Sprintf("Failed to uninstall %s: %s", kind, strings.TrimSpace(result.Stderr)))
+	case result.DryRun:
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would uninstall %d %s", count, kind))
+	default:
+		spinner.Success(fmt.Sprintf("Uninstalled %d %s", count, kind))
+	}
+}
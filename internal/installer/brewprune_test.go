@@ -0,0 +1,55 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	state := loadFixture(t, "brew-info-installed.json", ParseBrewInfo)
+	packages := &brewfile.Brewfile{
+		// python3 is an alias of python@3.12, which is not installed on request
+		Brews: []brewfile.Package{{Name: "jq"}, {Name: "python3"}},
+		Casks: []brewfile.Package{{Name: "iterm2"}},
+	}
+
+	formulae, casks := PruneCandidates(state, packages, []string{"font-*"})
+
+	// oniguruma is a dependency of jq and never a candidate
+	if want := []string{"acme/tools/widget", "node@18"}; !reflect.DeepEqual(formulae, want) {
+		t.Errorf("formulae = %v, want %v", formulae, want)
+	}
+	if want := []string{"visual-studio-code"}; !reflect.DeepEqual(casks, want) {
+		t.Errorf("casks = %v, want %v", casks, want)
+	}
+
+	// Ignore globs match short and full names
+	formulae, _ = PruneCandidates(state, packages, []string{"acme/*/*", "node@*"})
+	if len(formulae) != 0 {
+		t.Errorf("expected all formulae ignored, got %v", formulae)
+	}
+}
+
+func TestPruneCandidatesKeepsRuntimeManager(t *testing.T) {
+	state, err := ParseBrewInfo([]byte(`{"formulae": [
+		{"name": "mise", "full_name": "mise", "installed": [{"version": "2024.6.0", "installed_on_request": true}]},
+		{"name": "redis", "full_name": "redis", "installed": [{"version": "7.2.5", "installed_on_request": true}]},
+		{"name": "wget", "full_name": "wget", "installed": [{"version": "1.24.5", "installed_on_request": true}]}
+	]}`))
+	if err != nil {
+		t.Fatalf("failed to parse state: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Homebrew.Services = []config.Service{{Name: "redis"}}
+	cfg.Runtimes.Manager = config.RuntimeManagerMise
+
+	// mise is installed by install --runtimes and redis by homebrew.services
+	formulae, _ := PruneCandidates(state, declaredPackages(cfg, &brewfile.Brewfile{}), nil)
+	if want := []string{"wget"}; !reflect.DeepEqual(formulae, want) {
+		t.Errorf("formulae = %v, want %v", formulae, want)
+	}
+}
@@ -0,0 +1,201 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// BrewService is a service reported by brew services list --json
+type BrewService struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	User   string `json:"user"`
+	// File is the launchd plist, set while the service is registered
+	File     string `json:"file"`
+	ExitCode *int   `json:"exit_code"`
+}
+
+// Running reports whether the service is running
+func (s BrewService) Running() bool {
+	return s.Status == "started" || s.Status == "scheduled"
+}
+
+// AtLogin reports whether the service is registered to start at login
+func (s BrewService) AtLogin() bool {
+	return strings.Contains(s.File, "/LaunchAgents/")
+}
+
+// ParseBrewServices reads brew services list --json output, keyed by name
+func ParseBrewServices(data []byte) (map[string]BrewService, error) {
+	var list []BrewService
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse brew services: %w", err)
+	}
+
+	services := make(map[string]BrewService, len(list))
+	for _, s := range list {
+		services[s.Name] = s
+	}
+	return services, nil
+}
+
+// LoadBrewServices queries the state of all Homebrew services
+func LoadBrewServices(ctx context.Context, exec *executor.Executor) (map[string]BrewService, error) {
+	result, err := exec.Query(ctx, "brew", "services", "list", "--json")
+	if err != nil {
+		return nil, fmt.Errorf("brew services list failed: %w", err)
+	}
+	// brew prints nothing at all without services
+	if strings.TrimSpace(result.Stdout) == "" {
+		return map[string]BrewService{}, nil
+	}
+	return ParseBrewServices([]byte(result.Stdout))
+}
+
+// ServiceState describes a service as `started`, `running` (started, but not
+// at login), `stopped` or the brew status for anything else
+func ServiceState(s BrewService) string {
+	switch {
+	case s.Running() && s.AtLogin():
+		return "started"
+	case s.Running():
+		return "running"
+	case s.Status == "none" || s.Status == "stopped" || s.Status == "":
+		return "stopped"
+	}
+	return s.Status
+}
+
+// DesiredState describes a configured service like ServiceState
+func DesiredState(s config.Service) string {
+	switch {
+	case !s.Started():
+		return "stopped"
+	case s.AtLogin():
+		return "started"
+	}
+	return "running"
+}
+
+// serviceAction is one brew services command
+type serviceAction struct {
+	name string
+	verb string
+}
+
+// planServices returns the brew services commands that bring the actual
+// state to the desired one. Services brew does not know are skipped.
+func planServices(desired []config.Service, actual map[string]BrewService) (actions []serviceAction, unknown []string) {
+	for _, want := range desired {
+		have, ok := actual[want.Name]
+		if !ok {
+			unknown = append(unknown, want.Name)
+			continue
+		}
+
+		switch {
+		case !want.Started():
+			if have.Running() || have.AtLogin() {
+				actions = append(actions, serviceAction{want.Name, "stop"})
+			}
+		case want.AtLogin():
+			if !have.Running() || !have.AtLogin() {
+				actions = append(actions, serviceAction{want.Name, "start"})
+			}
+		default:
+			// brew services run does not unregister a login service
+			if have.AtLogin() {
+				actions = append(actions, serviceAction{want.Name, "stop"})
+				actions = append(actions, serviceAction{want.Name, "run"})
+			} else if !have.Running() {
+				actions = append(actions, serviceAction{want.Name, "run"})
+			}
+		}
+	}
+	return actions, unknown
+}
+
+// reconcileServices starts and stops the configured services
+func (h *HomebrewInstaller) reconcileServices(ctx context.Context, services []config.Service) error {
+	actual, err := LoadBrewServices(ctx, h.ctx.Executor)
+	if err != nil {
+		return err
+	}
+
+	actions, unknown := planServices(services, actual)
+	for _, name := range unknown {
+		if h.ctx.DryRun {
+			ui.PrintDryRun(fmt.Sprintf("brew services start %s (once %s is installed)", name, name))
+			continue
+		}
+		ui.PrintWarning(fmt.Sprintf("Service %s not found (is the formula installed and does it have a service?)", name))
+	}
+
+	if len(actions) == 0 && len(unknown) == 0 {
+		ui.PrintInfo("Services already in the configured state")
+		return nil
+	}
+
+	for _, action := range actions {
+		runServiceAction(ctx, h.ctx.Executor, action)
+	}
+	return nil
+}
+
+// servicesInState checks if the configured services need no brew services command
+func (h *HomebrewInstaller) servicesInState(ctx context.Context, services []config.Service) bool {
+	if len(services) == 0 {
+		return true
+	}
+	actual, err := LoadBrewServices(ctx, h.ctx.Executor)
+	if err != nil {
+		return false
+	}
+	actions, unknown := planServices(services, actual)
+	return len(actions) == 0 && len(unknown) == 0
+}
+
+// StopServices stops all configured services that are running or registered
+// at login
+func StopServices(ctx context.Context, exec *executor.Executor, services []config.Service) error {
+	actual, err := LoadBrewServices(ctx, exec)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range services {
+		if have, ok := actual[s.Name]; ok && (have.Running() || have.AtLogin()) {
+			runServiceAction(ctx, exec, serviceAction{s.Name, "stop"})
+		} else {
+			ui.PrintInfo(fmt.Sprintf("Service not running: %s", s.Name))
+		}
+	}
+	return nil
+}
+
+func runServiceAction(ctx context.Context, exec *executor.Executor, action serviceAction) {
+	spinner := ui.NewSpinner(fmt.Sprintf("brew services %s %s", action.verb, action.name))
+	spinner.Start()
+
+	result, err := exec.Run(ctx, "brew", "services", action.verb, action.name)
+	switch {
+	case err != nil:
+		spinner.Fail(fmt.Sprintf("Failed to %s service %s: %s", action.verb, action.name, strings.TrimSpace(result.Stderr)))
+	case result.DryRun:
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would %s service: %s", action.verb, action.name))
+	default:
+		spinner.Success(fmt.Sprintf("Service %s: %s", serviceVerbDone[action.verb], action.name))
+	}
+}
+
+var serviceVerbDone = map[string]string{
+	"start": "started",
+	"run":   "running",
+	"stop":  "stopped",
+}
@@ -0,0 +1,50 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestParseBrewServices(t *testing.T) {
+	services := loadFixture(t, "brew-services.json", ParseBrewServices)
+
+	want := map[string]string{
+		"postgresql@16": "started",
+		"redis":         "started",
+		"colima":        "stopped",
+		"unbound":       "error",
+	}
+	for name, state := range want {
+		if got := ServiceState(services[name]); got != state {
+			t.Errorf("%s: state = %q, want %q", name, got, state)
+		}
+	}
+}
+
+func TestPlanServices(t *testing.T) {
+	services := loadFixture(t, "brew-services.json", ParseBrewServices)
+	noLogin := false
+
+	actions, unknown := planServices([]config.Service{
+		{Name: "postgresql@16"},                       // already started
+		{Name: "redis", State: config.ServiceStopped}, // running, stop
+		{Name: "colima", RunAtLogin: &noLogin},        // run without login
+		{Name: "postgresql@16", RunAtLogin: &noLogin}, // unregister, then run
+		{Name: "mysql"},                               // not installed
+	}, services)
+
+	want := []serviceAction{
+		{"redis", "stop"},
+		{"colima", "run"},
+		{"postgresql@16", "stop"},
+		{"postgresql@16", "run"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %+v, want %+v", actions, want)
+	}
+	if !reflect.DeepEqual(unknown, []string{"mysql"}) {
+		t.Errorf("unknown = %v, want [mysql]", unknown)
+	}
+}
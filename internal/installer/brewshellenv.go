@@ -0,0 +1,107 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+const (
+	shellenvStartMarker = "# Homebrew (managed by setup-mac)"
+	shellenvEndMarker   = "# End Homebrew"
+)
+
+// brewBinary returns the brew executable for the architecture. An existing
+// install at the other prefix is used if the native one is missing.
+func brewBinary(arch string) string {
+	native, other := homebrewPath, homebrewPathIntel
+	if arch != "arm64" {
+		native, other = other, native
+	}
+
+	if _, err := os.Stat(native); err != nil {
+		if _, err := os.Stat(other); err == nil {
+			return other
+		}
+	}
+	return native
+}
+
+// shellenvBlock returns the managed block that puts brew on the PATH
+func shellenvBlock(brew string) string {
+	return strings.Join([]string{
+		shellenvStartMarker,
+		fmt.Sprintf(`eval "$(%s shellenv)"`, brew),
+		shellenvEndMarker,
+	}, "\n")
+}
+
+// ShellenvStatus reports whether the login shell loads brew shellenv
+type ShellenvStatus struct {
+	File       string `json:"file"`
+	Configured bool   `json:"configured"`
+	// Problem explains why the block is missing or outdated
+	Problem string `json:"problem,omitempty"`
+}
+
+// CheckShellenv checks that file contains the managed block for the
+// architecture's brew
+func CheckShellenv(file string) ShellenvStatus {
+	status := ShellenvStatus{File: file}
+	path := expandHome(file)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		status.Problem = fmt.Sprintf("%s not found", file)
+		return status
+	}
+
+	brew := brewBinary(runtime.GOARCH)
+	want := shellenvBlock(brew)
+	switch {
+	case strings.Contains(string(content), want):
+		status.Configured = true
+	case strings.Contains(string(content), shellenvStartMarker):
+		status.Problem = fmt.Sprintf("managed block in %s does not use %s", file, brew)
+	case strings.Contains(string(content), "brew shellenv"):
+		// Set up by hand, e.g. following the Homebrew installer's hint
+		status.Configured = true
+	default:
+		status.Problem = fmt.Sprintf(`%s does not load brew, add: eval "$(%s shellenv)"`, file, brew)
+	}
+	return status
+}
+
+// persistShellenv writes the brew shellenv block into the login shell file
+func (h *HomebrewInstaller) persistShellenv() error {
+	file := h.ctx.Config.Homebrew.ShellenvFile
+	if file == "" {
+		return nil
+	}
+
+	brew := brewBinary(runtime.GOARCH)
+	if h.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf(`Add eval "$(%s shellenv)" to %s`, brew, file))
+		return nil
+	}
+
+	path := expandHome(file)
+	if content, err := os.ReadFile(path); err == nil {
+		if strings.Contains(string(content), shellenvBlock(brew)) {
+			return nil
+		}
+		// Keep shellenv lines the user added by hand
+		if !strings.Contains(string(content), shellenvStartMarker) && strings.Contains(string(content), "brew shellenv") {
+			return nil
+		}
+	}
+
+	if err := updateManagedBlock(path, shellenvStartMarker, shellenvEndMarker, shellenvBlock(brew)); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Added brew shellenv to %s", file))
+	return nil
+}
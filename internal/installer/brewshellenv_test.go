@@ -0,0 +1,59 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestPersistShellenvIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zprofile")
+	if err := os.WriteFile(path, []byte("export LANG=en_US.UTF-8\n"), 0644); err != nil {
+		t.Fatalf("failed to write .zprofile: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Homebrew.ShellenvFile = path
+	h := NewHomebrewInstaller(NewContext(cfg, false, false))
+
+	if status := CheckShellenv(path); status.Configured {
+		t.Fatalf("expected missing shellenv, got %+v", status)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := h.persistShellenv(); err != nil {
+			t.Fatalf("failed to persist shellenv: %v", err)
+		}
+	}
+
+	content, _ := os.ReadFile(path)
+	brew := brewBinary(runtime.GOARCH)
+	if n := strings.Count(string(content), "brew shellenv"); n != 1 {
+		t.Errorf("expected one shellenv line, got %d:\n%s", n, content)
+	}
+	if !strings.HasPrefix(string(content), "export LANG=en_US.UTF-8\n") || !strings.Contains(string(content), brew+" shellenv") {
+		t.Errorf("unexpected .zprofile:\n%s", content)
+	}
+	if status := CheckShellenv(path); !status.Configured {
+		t.Errorf("expected shellenv configured, got %+v", status)
+	}
+}
+
+func TestCheckShellenvOtherPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zprofile")
+	other := homebrewPathIntel
+	if brewBinary(runtime.GOARCH) == other {
+		other = homebrewPath
+	}
+	if err := os.WriteFile(path, []byte(shellenvBlock(other)+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write .zprofile: %v", err)
+	}
+
+	if status := CheckShellenv(path); status.Configured || status.Problem == "" {
+		t.Errorf("expected outdated block to be reported, got %+v", status)
+	}
+}
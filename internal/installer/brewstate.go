@@ -0,0 +1,288 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// BrewState is the Homebrew package state reported by brew info --json=v2
+type BrewState struct {
+	Formulae []*BrewFormula
+	Casks    []*BrewCask
+
+	formulaIndex map[string]*BrewFormula
+	caskIndex    map[string]*BrewCask
+}
+
+// BrewFormula is an installed formula
+type BrewFormula struct {
+	Name     string
+	FullName string
+	Tap      string
+	Aliases  []string
+
+	// Versions lists the installed versions, Latest the current stable version
+	Versions []string
+	Latest   string
+
+	// Dependencies lists the full names of the installed runtime dependencies
+	Dependencies []string
+
+	Pinned   bool
+	Outdated bool
+	// OnRequest is true if the formula was installed explicitly rather
+	// than as a dependency
+	OnRequest bool
+}
+
+// BrewCask is an installed (or queried) cask
+type BrewCask struct {
+	Token     string
+	FullToken string
+	Tap       string
+
+	// Version is the installed version, Latest the current version
+	Version string
+	Latest  string
+
+	Outdated    bool
+	AutoUpdates bool
+	// Apps lists the .app bundles the cask installs
+	Apps []string
+}
+
+// brewInfoJSON mirrors the parts of brew info --json=v2 that are used
+type brewInfoJSON struct {
+	Formulae []struct {
+		Name     string   `json:"name"`
+		FullName string   `json:"full_name"`
+		Tap      string   `json:"tap"`
+		Aliases  []string `json:"aliases"`
+		Oldnames []string `json:"oldnames"`
+		Versions struct {
+			Stable string `json:"stable"`
+		} `json:"versions"`
+		Pinned    bool `json:"pinned"`
+		Outdated  bool `json:"outdated"`
+		Installed []struct {
+			Version             string `json:"version"`
+			InstalledOnRequest  bool   `json:"installed_on_request"`
+			RuntimeDependencies []struct {
+				FullName string `json:"full_name"`
+			} `json:"runtime_dependencies"`
+		} `json:"installed"`
+	} `json:"formulae"`
+	Casks []struct {
+		Token       string                       `json:"token"`
+		FullToken   string                       `json:"full_token"`
+		Tap         string                       `json:"tap"`
+		Version     string                       `json:"version"`
+		Installed   *string                      `json:"installed"`
+		Outdated    bool                         `json:"outdated"`
+		AutoUpdates bool                         `json:"auto_updates"`
+		Artifacts   []map[string]json.RawMessage `json:"artifacts"`
+	} `json:"casks"`
+}
+
+// ParseBrewInfo builds a BrewState from brew info --json=v2 output
+func ParseBrewInfo(data []byte) (*BrewState, error) {
+	var info brewInfoJSON
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse brew info: %w", err)
+	}
+
+	state := &BrewState{
+		formulaIndex: make(map[string]*BrewFormula),
+		caskIndex:    make(map[string]*BrewCask),
+	}
+
+	for _, f := range info.Formulae {
+		formula := &BrewFormula{
+			Name:     f.Name,
+			FullName: f.FullName,
+			Tap:      f.Tap,
+			Aliases:  f.Aliases,
+			Latest:   f.Versions.Stable,
+			Pinned:   f.Pinned,
+			Outdated: f.Outdated,
+		}
+		for _, keg := range f.Installed {
+			formula.Versions = append(formula.Versions, keg.Version)
+			formula.OnRequest = formula.OnRequest || keg.InstalledOnRequest
+			for _, dep := range keg.RuntimeDependencies {
+				formula.Dependencies = append(formula.Dependencies, dep.FullName)
+			}
+		}
+
+		state.Formulae = append(state.Formulae, formula)
+		names := []string{f.Name, f.FullName}
+		names = append(names, f.Aliases...)
+		names = append(names, f.Oldnames...)
+		for _, name := range names {
+			if _, ok := state.formulaIndex[name]; name != "" && !ok {
+				state.formulaIndex[name] = formula
+			}
+		}
+	}
+
+	for _, c := range info.Casks {
+		cask := &BrewCask{
+			Token:       c.Token,
+			FullToken:   c.FullToken,
+			Tap:         c.Tap,
+			Latest:      c.Version,
+			Outdated:    c.Outdated,
+			AutoUpdates: c.AutoUpdates,
+			Apps:        caskApps(c.Artifacts),
+		}
+		if c.Installed != nil {
+			cask.Version = *c.Installed
+		}
+
+		state.Casks = append(state.Casks, cask)
+		for _, name := range []string{c.Token, c.FullToken} {
+			if name != "" {
+				state.caskIndex[name] = cask
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// caskApps extracts .app names from cask artifacts. App entries are either
+// a source path or an object with the target name.
+func caskApps(artifacts []map[string]json.RawMessage) []string {
+	var apps []string
+	for _, artifact := range artifacts {
+		raw, ok := artifact["app"]
+		if !ok {
+			continue
+		}
+
+		var entries []json.RawMessage
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			continue
+		}
+
+		// A target object renames the preceding source
+		for _, entry := range entries {
+			var source string
+			if err := json.Unmarshal(entry, &source); err == nil {
+				apps = append(apps, filepath.Base(source))
+				continue
+			}
+
+			var target struct {
+				Target string `json:"target"`
+			}
+			if err := json.Unmarshal(entry, &target); err == nil && target.Target != "" && len(apps) > 0 {
+				apps[len(apps)-1] = filepath.Base(target.Target)
+			}
+		}
+	}
+	return apps
+}
+
+// EmptyBrewState returns a state without any installed packages
+func EmptyBrewState() *BrewState {
+	state, _ := ParseBrewInfo([]byte(`{}`))
+	return state
+}
+
+// LoadBrewState queries all installed formulae and casks with one brew call.
+// The query also runs in dry-run mode.
+func LoadBrewState(ctx context.Context, exec *executor.Executor) (*BrewState, error) {
+	result, err := exec.Query(ctx, "brew", "info", "--json=v2", "--installed")
+	if err != nil {
+		return nil, fmt.Errorf("brew info failed: %w", err)
+	}
+	return ParseBrewInfo([]byte(result.Stdout))
+}
+
+// LoadCaskInfo queries casks that may not be installed, e.g. to learn their app names
+func LoadCaskInfo(ctx context.Context, exec *executor.Executor, casks []string) (*BrewState, error) {
+	if len(casks) == 0 {
+		return EmptyBrewState(), nil
+	}
+
+	args := append([]string{"info", "--json=v2", "--cask"}, casks...)
+	result, err := exec.Query(ctx, "brew", args...)
+	if err != nil {
+		return nil, fmt.Errorf("brew info failed: %w", err)
+	}
+	return ParseBrewInfo([]byte(result.Stdout))
+}
+
+// Formula returns an installed formula by name, full name, alias or old name.
+// Versioned formulae are distinct: node@18 does not satisfy node.
+func (s *BrewState) Formula(name string) (*BrewFormula, bool) {
+	f, ok := s.formulaIndex[name]
+	if !ok || len(f.Versions) == 0 {
+		return nil, false
+	}
+	return f, true
+}
+
+// Cask returns a cask by token or full token
+func (s *BrewState) Cask(name string) (*BrewCask, bool) {
+	c, ok := s.caskIndex[name]
+	return c, ok
+}
+
+// CaskInstalled reports whether a cask is installed through Homebrew
+func (s *BrewState) CaskInstalled(name string) bool {
+	c, ok := s.Cask(name)
+	return ok && c.Version != ""
+}
+
+// OnRequest returns the names of formulae installed explicitly, sorted
+func (s *BrewState) OnRequest() []string {
+	var names []string
+	for _, f := range s.Formulae {
+		if f.OnRequest {
+			names = append(names, f.FullName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InstalledCasks returns the tokens of installed casks, sorted
+func (s *BrewState) InstalledCasks() []string {
+	var names []string
+	for _, c := range s.Casks {
+		if c.Version != "" {
+			names = append(names, c.FullToken)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applicationDirs are searched for apps installed outside Homebrew
+func applicationDirs() []string {
+	dirs := []string{"/Applications"}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(homeDir, "Applications"))
+	}
+	return dirs
+}
+
+// appInstalled reports whether any of the cask's apps exists in dirs
+func (c *BrewCask) appInstalled(dirs []string) (string, bool) {
+	for _, app := range c.Apps {
+		for _, dir := range dirs {
+			if _, err := os.Stat(filepath.Join(dir, app)); err == nil {
+				return app, true
+			}
+		}
+	}
+	return "", false
+}
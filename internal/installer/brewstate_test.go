@@ -0,0 +1,123 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadFixture reads a file from testdata and parses it with parse
+func loadFixture[T any](t *testing.T, name string, parse func([]byte) (T, error)) T {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	parsed, err := parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse fixture: %v", err)
+	}
+	return parsed
+}
+
+func TestBrewStateFormulae(t *testing.T) {
+	state := loadFixture(t, "brew-info-installed.json", ParseBrewInfo)
+
+	jq, ok := state.Formula("jq")
+	if !ok {
+		t.Fatal("expected jq to be installed")
+	}
+	if !jq.OnRequest || jq.Pinned || jq.Outdated || !reflect.DeepEqual(jq.Versions, []string{"1.7.1"}) {
+		t.Errorf("unexpected jq state: %+v", jq)
+	}
+
+	// A versioned formula does not satisfy the unversioned one
+	if _, ok := state.Formula("node"); ok {
+		t.Error("expected node not to be installed when only node@18 is")
+	}
+
+	node, ok := state.Formula("node@18")
+	if !ok || !node.Pinned || !node.Outdated || node.Latest != "18.20.4" {
+		t.Errorf("unexpected node@18 state: %+v", node)
+	}
+
+	// Aliases resolve to the real formula
+	if python, ok := state.Formula("python3"); !ok || python.Name != "python@3.12" {
+		t.Errorf("expected python3 alias to resolve to python@3.12, got %+v", python)
+	}
+
+	// Tap formulae are found by full name
+	if _, ok := state.Formula("acme/tools/widget"); !ok {
+		t.Error("expected acme/tools/widget to be installed")
+	}
+
+	want := []string{"acme/tools/widget", "jq", "node@18"}
+	if got := state.OnRequest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected on-request formulae %v, got %v", want, got)
+	}
+}
+
+func TestBrewStateCasks(t *testing.T) {
+	state := loadFixture(t, "brew-info-installed.json", ParseBrewInfo)
+
+	code, ok := state.Cask("visual-studio-code")
+	if !ok || !state.CaskInstalled("visual-studio-code") {
+		t.Fatal("expected visual-studio-code to be installed")
+	}
+	if code.Version != "1.90.0" || code.Latest != "1.92.2" || !code.Outdated {
+		t.Errorf("unexpected visual-studio-code state: %+v", code)
+	}
+	if !reflect.DeepEqual(code.Apps, []string{"Visual Studio Code.app"}) {
+		t.Errorf("unexpected apps: %v", code.Apps)
+	}
+
+	if font, _ := state.Cask("font-meslo-lg-nerd-font"); len(font.Apps) != 0 {
+		t.Errorf("expected font cask without apps, got %v", font.Apps)
+	}
+
+	want := []string{"font-meslo-lg-nerd-font", "iterm2", "visual-studio-code"}
+	if got := state.InstalledCasks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected casks %v, got %v", want, got)
+	}
+}
+
+func TestBrewStateCaskApps(t *testing.T) {
+	state := loadFixture(t, "brew-info-casks.json", ParseBrewInfo)
+
+	if state.CaskInstalled("docker") {
+		t.Error("expected docker not to be installed")
+	}
+
+	tests := map[string][]string{
+		"docker":           {"Docker.app"},
+		"intellij-idea-ce": {"IntelliJ IDEA CE.app"},
+		"zed":              {"Zed Editor.app"},
+	}
+	for token, want := range tests {
+		cask, ok := state.Cask(token)
+		if !ok {
+			t.Errorf("%s: missing from state", token)
+			continue
+		}
+		if !reflect.DeepEqual(cask.Apps, want) {
+			t.Errorf("%s: expected apps %v, got %v", token, want, cask.Apps)
+		}
+	}
+
+	// Manually installed apps are found by their real name
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "IntelliJ IDEA CE.app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	idea, _ := state.Cask("intellij-idea-ce")
+	if app, ok := idea.appInstalled([]string{dir}); !ok || app != "IntelliJ IDEA CE.app" {
+		t.Errorf("expected IntelliJ IDEA CE.app to be found, got %q", app)
+	}
+	docker, _ := state.Cask("docker")
+	if _, ok := docker.appInstalled([]string{dir}); ok {
+		t.Error("expected Docker.app not to be found")
+	}
+}
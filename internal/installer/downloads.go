@@ -0,0 +1,523 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+const (
+	defaultDownloadDest = "~/.local/bin"
+	downloadCacheDir    = "~/Library/Caches/setup-mac/downloads"
+	downloadStateFile   = "~/Library/Application Support/setup-mac/downloads.json"
+)
+
+// DownloadsInstaller downloads, verifies and installs binaries and archives
+type DownloadsInstaller struct {
+	ctx *Context
+	// CacheDir keeps partial downloads so they can be resumed
+	CacheDir string
+	// StateFile records what was installed from which checksum
+	StateFile string
+	client    *http.Client
+}
+
+// NewDownloadsInstaller creates a new downloads installer
+func NewDownloadsInstaller(ctx *Context) *DownloadsInstaller {
+	return &DownloadsInstaller{
+		ctx:       ctx,
+		CacheDir:  expandHome(downloadCacheDir),
+		StateFile: expandHome(downloadStateFile),
+		client:    &http.Client{},
+	}
+}
+
+// Name returns the installer name
+func (d *DownloadsInstaller) Name() string {
+	return "downloads"
+}
+
+// Description returns the installer description
+func (d *DownloadsInstaller) Description() string {
+	return "Downloaded Binaries and Archives"
+}
+
+// IsInstalled checks if every download is installed from its checksum
+func (d *DownloadsInstaller) IsInstalled(ctx context.Context) bool {
+	state := loadDownloadState(d.StateFile)
+	for _, download := range d.ctx.Config.Downloads {
+		if !state.Current(download, runtime.GOARCH) {
+			return false
+		}
+	}
+	return true
+}
+
+// Install downloads the files that are missing, changed or installed from
+// another checksum
+func (d *DownloadsInstaller) Install(ctx context.Context) error {
+	downloads := d.ctx.Config.Downloads
+	if len(downloads) == 0 {
+		ui.PrintInfo("No downloads configured")
+		return nil
+	}
+
+	state := loadDownloadState(d.StateFile)
+	failed := 0
+	for _, download := range downloads {
+		if state.Current(download, runtime.GOARCH) {
+			ui.PrintInfo(fmt.Sprintf("Download already installed: %s", download.Name))
+			continue
+		}
+
+		source, err := DownloadURL(download.URL, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("%s: %v", download.Name, err))
+			failed++
+			continue
+		}
+		dest := expandHome(downloadDest(download))
+		if d.ctx.DryRun {
+			ui.PrintDryRun(fmt.Sprintf("Would download %s to %s", source, dest))
+			continue
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Downloading %s...", download.Name))
+		spinner.Start()
+		installed, err := d.install(ctx, download, source, dest)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to install %s: %v", download.Name, err))
+			failed++
+			continue
+		}
+		spinner.Success(fmt.Sprintf("Installed %s (%d file(s) in %s)", download.Name, len(installed), dest))
+
+		state.Downloads[download.Name] = DownloadRecord{URL: source, SHA256: download.SHA256.For(runtime.GOARCH), Files: installed}
+		if err := state.save(d.StateFile); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to record %s: %v", download.Name, err))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d download(s) failed", failed)
+	}
+	return nil
+}
+
+// install fetches and verifies a download and installs its files, returning
+// the installed paths with their sha256
+func (d *DownloadsInstaller) install(ctx context.Context, download config.Download, source, dest string) (map[string]string, error) {
+	sum := download.SHA256.For(runtime.GOARCH)
+	if sum == "" {
+		return nil, fmt.Errorf("no sha256 for %s", runtime.GOARCH)
+	}
+
+	if err := os.MkdirAll(d.CacheDir, 0755); err != nil {
+		return nil, err
+	}
+	file := filepath.Join(d.CacheDir, strings.ToLower(sum)+".part")
+	if err := fetch(ctx, d.client, source, file, sum); err != nil {
+		return nil, err
+	}
+	defer os.Remove(file)
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	mode, err := parseMode(download.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	switch archiveType(download, source) {
+	case config.ArchiveTarGz:
+		return extractTarGz(file, dest, download.Files, mode)
+	case config.ArchiveZip:
+		return extractZip(file, dest, download.Files, mode)
+	default:
+		name := path.Base(urlPath(source))
+		if len(download.Files) > 0 {
+			name = download.Files[0]
+		}
+		if mode == 0 {
+			mode = 0755
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		installed := make(map[string]string)
+		if err := installFile(f, dest, name, mode, installed); err != nil {
+			return nil, err
+		}
+		return installed, nil
+	}
+}
+
+// DownloadURL expands {{os}} and {{arch}} in a download URL
+func DownloadURL(raw, goos, goarch string) (string, error) {
+	tmpl, err := template.New("url").Funcs(template.FuncMap{
+		"os":   func() string { return goos },
+		"arch": func() string { return goarch },
+	}).Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid url template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("invalid url template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func downloadDest(download config.Download) string {
+	if download.Dest == "" {
+		return defaultDownloadDest
+	}
+	return download.Dest
+}
+
+// archiveType returns the configured archive type or guesses it from the URL
+func archiveType(download config.Download, source string) string {
+	if download.Archive != "" {
+		return download.Archive
+	}
+	switch p := strings.ToLower(urlPath(source)); {
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return config.ArchiveTarGz
+	case strings.HasSuffix(p, ".zip"):
+		return config.ArchiveZip
+	}
+	return config.ArchiveNone
+}
+
+func urlPath(source string) string {
+	if u, err := url.Parse(source); err == nil {
+		return u.Path
+	}
+	return source
+}
+
+func parseMode(mode config.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(string(mode), 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("invalid mode %q", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+// fetch downloads source into file, resuming a partial file with a Range
+// request, and checks the sha256 of the result. A file that fails the
+// check is removed so the next run starts over.
+func fetch(ctx context.Context, client *http.Client, source, file, sum string) error {
+	if verifyFile(file, sum) == nil {
+		return nil
+	}
+
+	out, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", source, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range, start over
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is as long as the download but did not verify,
+		// download it again from the start
+		out.Close()
+		resp.Body.Close()
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		return fetch(ctx, client, source, file, sum)
+	default:
+		return fmt.Errorf("failed to download %s: %s", source, resp.Status)
+	}
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("download of %s interrupted, it resumes on the next run: %w", source, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := verifyFile(file, sum); err != nil {
+		os.Remove(file)
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+// verifyFile compares the sha256 of a file with sum
+func verifyFile(file, sum string) error {
+	got, err := fileSHA256(file)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, sum) {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, got, sum)
+	}
+	return nil
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// archiveEntry checks an archive path and reports whether it is selected by
+// the file globs. Absolute paths and .. components are rejected.
+func archiveEntry(name string, files []string) (bool, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, `\`) {
+		return false, fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	if len(files) == 0 {
+		return true, nil
+	}
+	for _, pattern := range files {
+		if ok, _ := path.Match(pattern, clean); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func extractTarGz(file, dest string, files []string, mode os.FileMode) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tar.gz archive: %w", err)
+	}
+	archive := tar.NewReader(gz)
+
+	installed := make(map[string]string)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return installed, fmt.Errorf("invalid tar.gz archive: %w", err)
+		}
+		selected, err := archiveEntry(header.Name, files)
+		if err != nil {
+			return installed, err
+		}
+		// Links could point outside dest, only regular files are installed
+		if !selected || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		m := mode
+		if m == 0 {
+			m = os.FileMode(header.Mode).Perm()
+		}
+		if err := installFile(archive, dest, path.Base(header.Name), m, installed); err != nil {
+			return installed, err
+		}
+	}
+	return installed, checkSelected(installed, files)
+}
+
+func extractZip(file, dest string, files []string, mode os.FileMode) (map[string]string, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	installed := make(map[string]string)
+	for _, entry := range archive.File {
+		selected, err := archiveEntry(entry.Name, files)
+		if err != nil {
+			return installed, err
+		}
+		if !selected || !entry.Mode().IsRegular() {
+			continue
+		}
+		m := mode
+		if m == 0 {
+			m = entry.Mode().Perm()
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return installed, err
+		}
+		err = installFile(r, dest, path.Base(entry.Name), m, installed)
+		r.Close()
+		if err != nil {
+			return installed, err
+		}
+	}
+	return installed, checkSelected(installed, files)
+}
+
+// checkSelected fails an archive without any of the requested files
+func checkSelected(installed map[string]string, files []string) error {
+	if len(installed) == 0 {
+		if len(files) > 0 {
+			return fmt.Errorf("archive has no files matching %s", strings.Join(files, ", "))
+		}
+		return fmt.Errorf("archive has no files")
+	}
+	return nil
+}
+
+// installFile writes r to dest/name through a temporary file and records
+// its sha256. Files are installed flat, so two archive paths with the same
+// name are an error.
+func installFile(r io.Reader, dest, name string, mode os.FileMode, installed map[string]string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("unsafe file name: %q", name)
+	}
+	target := filepath.Join(dest, name)
+	if _, ok := installed[target]; ok {
+		return fmt.Errorf("archive has more than one %s", name)
+	}
+
+	tmp, err := os.CreateTemp(dest, "."+name+"-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dest, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to install %s: %w", target, err)
+	}
+	installed[target] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// DownloadState records the installed downloads
+type DownloadState struct {
+	Downloads map[string]DownloadRecord `json:"downloads"`
+}
+
+// DownloadRecord is a download installed from a checksum, with the sha256
+// of each installed file
+type DownloadRecord struct {
+	URL    string            `json:"url"`
+	SHA256 string            `json:"sha256"`
+	Files  map[string]string `json:"files"`
+}
+
+// LoadDownloadState reads the state file of the downloads installer
+func LoadDownloadState() *DownloadState {
+	return loadDownloadState(expandHome(downloadStateFile))
+}
+
+// loadDownloadState reads a state file; a missing or broken file is empty
+func loadDownloadState(file string) *DownloadState {
+	state := &DownloadState{Downloads: make(map[string]DownloadRecord)}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil || state.Downloads == nil {
+		return &DownloadState{Downloads: make(map[string]DownloadRecord)}
+	}
+	return state
+}
+
+func (s *DownloadState) save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// Current reports whether a download was installed from its checksum into
+// its destination and the files are unchanged
+func (s *DownloadState) Current(download config.Download, arch string) bool {
+	record, ok := s.Downloads[download.Name]
+	sum := download.SHA256.For(arch)
+	if !ok || sum == "" || !strings.EqualFold(record.SHA256, sum) || len(record.Files) == 0 {
+		return false
+	}
+	dest := expandHome(downloadDest(download))
+	for file, want := range record.Files {
+		if filepath.Dir(file) != dest {
+			return false
+		}
+		if got, err := fileSHA256(file); err != nil || got != want {
+			return false
+		}
+	}
+	return true
+}
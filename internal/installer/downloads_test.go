@@ -0,0 +1,268 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// artifactServer serves files by path with Range support and records the
+// requests
+type artifactServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newArtifactServer(t *testing.T, files map[string][]byte) *artifactServer {
+	s := &artifactServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, strings.TrimSpace(r.URL.Path+" "+r.Header.Get("Range")))
+		s.mu.Unlock()
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *artifactServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newTestDownloads(t *testing.T, downloads ...config.Download) *DownloadsInstaller {
+	t.Helper()
+	cfg := &config.Config{Downloads: downloads}
+	d := NewDownloadsInstaller(NewContext(cfg, false, false))
+	d.CacheDir = t.TempDir()
+	d.StateFile = filepath.Join(t.TempDir(), "downloads.json")
+	return d
+}
+
+func TestDownloadURL(t *testing.T) {
+	got, err := DownloadURL("https://example.com/tool/1.0/tool_{{os}}_{{ arch }}.tar.gz", "darwin", "arm64")
+	if err != nil || got != "https://example.com/tool/1.0/tool_darwin_arm64.tar.gz" {
+		t.Errorf("unexpected url %q, %v", got, err)
+	}
+	if _, err := DownloadURL("https://example.com/{{version}}", "darwin", "arm64"); err == nil {
+		t.Error("expected an error for an unknown variable")
+	}
+}
+
+func TestArchiveEntry(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/passwd", "bin/../../evil", `..\evil`} {
+		if _, err := archiveEntry(name, nil); err == nil {
+			t.Errorf("%s: expected an unsafe path error", name)
+		}
+	}
+
+	files := []string{"*/bin/tool", "README.md"}
+	for name, want := range map[string]bool{
+		"./tool-1.0/bin/tool": true,
+		"tool-1.0/bin/other":  false,
+		"README.md":           true,
+		"docs/README.md":      false,
+	} {
+		if got, err := archiveEntry(name, files); err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", name, got, err, want)
+		}
+	}
+}
+
+func TestDownloadsInstallTarGz(t *testing.T) {
+	tarball := releaseTarball(t, map[string]string{"tool-1.0/bin/tool": "tool binary", "tool-1.0/README.md": "docs"})
+	name := "/tool_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
+	server := newArtifactServer(t, map[string][]byte{name: tarball})
+
+	dest := filepath.Join(t.TempDir(), "bin")
+	d := newTestDownloads(t, config.Download{
+		Name:   "tool",
+		URL:    server.URL + "/tool_{{os}}_{{arch}}.tar.gz",
+		SHA256: config.Checksums{runtime.GOARCH: sha256Hex(tarball), "other": "0000"},
+		Files:  []string{"*/bin/tool"},
+		Dest:   dest,
+	})
+	ctx := context.Background()
+
+	if d.IsInstalled(ctx) {
+		t.Error("expected the download to be missing")
+	}
+	if err := d.Install(ctx); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	target := filepath.Join(dest, "tool")
+	if data, _ := os.ReadFile(target); string(data) != "tool binary" {
+		t.Errorf("unexpected tool: %q", data)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("unexpected mode: %v, %v", info, err)
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != 1 {
+		t.Errorf("expected only the selected file, got %v", entries)
+	}
+	if entries, _ := os.ReadDir(d.CacheDir); len(entries) != 0 {
+		t.Errorf("download left in the cache: %v", entries)
+	}
+
+	// The state file skips the download next time
+	if !d.IsInstalled(ctx) {
+		t.Error("expected the download to be recorded")
+	}
+	if err := d.Install(ctx); err != nil {
+		t.Fatalf("second Install failed: %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 1 {
+		t.Errorf("expected a single request, got %v", requests)
+	}
+
+	// A changed file is installed again
+	writeFile(t, target, "edited")
+	if d.IsInstalled(ctx) {
+		t.Error("expected the edited file to be reported")
+	}
+	if err := d.Install(ctx); err != nil {
+		t.Fatalf("third Install failed: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "tool binary" {
+		t.Errorf("tool not restored: %q", data)
+	}
+}
+
+func TestDownloadsResume(t *testing.T) {
+	content := []byte(strings.Repeat("binary content ", 100))
+	server := newArtifactServer(t, map[string][]byte{"/tool": content})
+	sum := sha256Hex(content)
+
+	dest := t.TempDir()
+	d := newTestDownloads(t, config.Download{
+		Name:   "tool",
+		URL:    server.URL + "/tool",
+		SHA256: config.Checksums{"*": sum},
+		Files:  []string{"mytool"},
+		Dest:   dest,
+		Mode:   "0750",
+	})
+
+	// An interrupted download left the first 100 bytes
+	writeFile(t, filepath.Join(d.CacheDir, sum+".part"), string(content[:100]))
+
+	if err := d.Install(context.Background()); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 1 || requests[0] != "/tool bytes=100-" {
+		t.Errorf("expected a range request, got %v", requests)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "mytool")); !bytes.Equal(data, content) {
+		t.Error("resumed download is corrupt")
+	}
+	if info, err := os.Stat(filepath.Join(dest, "mytool")); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("unexpected mode: %v, %v", info, err)
+	}
+}
+
+func TestDownloadsChecksumMismatch(t *testing.T) {
+	server := newArtifactServer(t, map[string][]byte{"/tool": []byte("tampered")})
+	dest := t.TempDir()
+	d := newTestDownloads(t, config.Download{
+		Name:   "tool",
+		URL:    server.URL + "/tool",
+		SHA256: config.Checksums{"*": sha256Hex([]byte("original"))},
+		Dest:   dest,
+	})
+
+	if err := d.Install(context.Background()); err == nil {
+		t.Fatal("expected a checksum error")
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+		t.Errorf("unverified file installed: %v", entries)
+	}
+	if entries, _ := os.ReadDir(d.CacheDir); len(entries) != 0 {
+		t.Errorf("unverified download kept: %v", entries)
+	}
+
+	// A partial file with the full length but the wrong bytes starts over
+	content := []byte("original")
+	sum := sha256Hex(content)
+	server = newArtifactServer(t, map[string][]byte{"/tool": content})
+	d = newTestDownloads(t, config.Download{Name: "tool", URL: server.URL + "/tool", SHA256: config.Checksums{"*": sum}, Dest: dest})
+	writeFile(t, filepath.Join(d.CacheDir, sum+".part"), "corrupt!")
+	if err := d.Install(context.Background()); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "tool")); string(data) != "original" {
+		t.Errorf("unexpected tool: %q", data)
+	}
+}
+
+func TestDownloadsZip(t *testing.T) {
+	good := zipArchive(t, map[string]string{"tool/tool": "tool", "tool/helper": "helper"})
+	evil := zipArchive(t, map[string]string{"../evil": "evil"})
+	server := newArtifactServer(t, map[string][]byte{"/good.zip": good, "/evil.zip": evil})
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "bin")
+	d := newTestDownloads(t,
+		config.Download{Name: "evil", URL: server.URL + "/evil.zip", SHA256: config.Checksums{"*": sha256Hex(evil)}, Dest: dest},
+		config.Download{Name: "good", URL: server.URL + "/good.zip", SHA256: config.Checksums{"*": sha256Hex(good)}, Dest: dest, Mode: "0755"},
+	)
+
+	err := d.Install(context.Background())
+	if err == nil {
+		t.Fatal("expected the unsafe archive to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("archive wrote outside the destination")
+	}
+	for _, name := range []string{"tool", "helper"} {
+		if info, err := os.Stat(filepath.Join(dest, name)); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("%s: unexpected file %v, %v", name, info, err)
+		}
+	}
+
+	state := loadDownloadState(d.StateFile)
+	if _, ok := state.Downloads["evil"]; ok {
+		t.Error("failed download recorded")
+	}
+	if record := state.Downloads["good"]; len(record.Files) != 2 || record.SHA256 != sha256Hex(good) {
+		t.Errorf("unexpected record: %+v", record)
+	}
+}
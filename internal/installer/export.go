@@ -0,0 +1,364 @@
+package installer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/secrets"
+)
+
+// sshKeyTypes maps public key prefixes to config key types
+var sshKeyTypes = map[string]string{
+	"ssh-ed25519":         "ed25519",
+	"ecdsa-sha2-nistp256": "ecdsa",
+	"ecdsa-sha2-nistp384": "ecdsa",
+	"ecdsa-sha2-nistp521": "ecdsa",
+	"ssh-rsa":             "rsa",
+}
+
+// credentialKeyParts mark git config keys that hold credentials, e.g.
+// github.token or http.extraheader, which are left out of the export
+var credentialKeyParts = []string{"token", "password", "passwd", "secret", "apikey", "api-key", "extraheader"}
+
+// Exporter captures the state of the current machine as a configuration
+type Exporter struct {
+	ctx *Context
+}
+
+// NewExporter creates a new exporter
+func NewExporter(ctx *Context) *Exporter {
+	return &Exporter{ctx: ctx}
+}
+
+// Export reads installed packages, shell, macOS, Git and SSH settings.
+// Anything that cannot be read keeps its embedded default value.
+func (e *Exporter) Export(ctx context.Context) (*config.Config, error) {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default config: %w", err)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	e.exportHomebrew(ctx, cfg)
+	e.exportShell(homeDir, cfg)
+	e.exportMacOS(ctx, cfg)
+	e.exportGit(ctx, cfg)
+	e.exportSSH(homeDir, cfg)
+
+	return cfg, nil
+}
+
+func (e *Exporter) exportHomebrew(ctx context.Context, cfg *config.Config) {
+	if !e.ctx.Executor.Exists("brew") {
+		cfg.Homebrew = config.HomebrewConfig{Install: false}
+		return
+	}
+
+	cfg.Homebrew.Install = true
+	// Dependencies are left out, brew installs them again on its own
+	if state, err := LoadBrewState(ctx, e.ctx.Executor); err == nil {
+		cfg.Homebrew.Formulae = config.NewFormulae(state.OnRequest())
+		cfg.Homebrew.Casks = config.NewCasks(state.InstalledCasks())
+	} else {
+		cfg.Homebrew.Formulae = config.NewFormulae(e.listLines(ctx, "brew", "list", "--formula"))
+		cfg.Homebrew.Casks = config.NewCasks(e.listLines(ctx, "brew", "list", "--cask"))
+	}
+	cfg.Homebrew.Taps = config.NewTaps(e.listLines(ctx, "brew", "tap"))
+}
+
+func (e *Exporter) exportShell(homeDir string, cfg *config.Config) {
+	_, err := os.Stat(filepath.Join(homeDir, ".oh-my-zsh"))
+	cfg.Terminal.OhMyZsh.Install = err == nil
+
+	_, err = os.Stat(filepath.Join(homeDir, ".oh-my-zsh", "custom", "themes", "powerlevel10k"))
+	cfg.Terminal.Powerlevel10k.Install = err == nil
+
+	content, err := os.ReadFile(filepath.Join(homeDir, ".zshrc"))
+	if err != nil {
+		return
+	}
+
+	zshrc := parseZshrc(string(content))
+
+	if zshrc.Plugins != nil {
+		cfg.Terminal.OhMyZsh.Plugins = zshrc.Plugins
+	}
+	if zshrc.Theme != "" {
+		cfg.Terminal.OhMyZsh.Theme = zshrc.Theme
+	}
+
+	cfg.Shell.Aliases = zshrc.Aliases
+	cfg.Shell.Environment = zshrc.Environment
+	cfg.Shell.ZshrcExtras = []string{}
+}
+
+func (e *Exporter) exportMacOS(ctx context.Context, cfg *config.Config) {
+	d := &cfg.MacOS.Defaults
+	viewStyles := map[string]string{
+		"icnv": "icon",
+		"Nlsv": "list",
+		"clmv": "column",
+		"glyv": "gallery",
+	}
+
+	reads := []struct {
+		domain string
+		key    string
+		apply  func(string)
+	}{
+		{"com.apple.dock", "autohide", func(v string) { d.Dock.Autohide = parseDefaultsBool(v) }},
+		{"com.apple.dock", "autohide-delay", func(v string) { d.Dock.AutohideDelay = parseDefaultsInt(v) }},
+		{"com.apple.dock", "tilesize", func(v string) { d.Dock.TileSize = parseDefaultsInt(v) }},
+		{"com.apple.dock", "magnification", func(v string) { d.Dock.Magnification = parseDefaultsBool(v) }},
+		{"com.apple.dock", "minimize-to-application", func(v string) { d.Dock.MinimizeToApp = parseDefaultsBool(v) }},
+		{"com.apple.dock", "show-recents", func(v string) { d.Dock.ShowRecents = parseDefaultsBool(v) }},
+		{"com.apple.finder", "AppleShowAllFiles", func(v string) { d.Finder.ShowHiddenFiles = parseDefaultsBool(v) }},
+		{"NSGlobalDomain", "AppleShowAllExtensions", func(v string) { d.Finder.ShowExtensions = parseDefaultsBool(v) }},
+		{"com.apple.finder", "ShowPathbar", func(v string) { d.Finder.ShowPathBar = parseDefaultsBool(v) }},
+		{"com.apple.finder", "ShowStatusBar", func(v string) { d.Finder.ShowStatusBar = parseDefaultsBool(v) }},
+		{"com.apple.finder", "FXPreferredViewStyle", func(v string) {
+			if style, ok := viewStyles[v]; ok {
+				d.Finder.DefaultViewStyle = style
+			}
+		}},
+		{"NSGlobalDomain", "KeyRepeat", func(v string) { d.Keyboard.KeyRepeat = parseDefaultsInt(v) }},
+		{"NSGlobalDomain", "InitialKeyRepeat", func(v string) { d.Keyboard.InitialKeyRepeat = parseDefaultsInt(v) }},
+		{"NSGlobalDomain", "NSAutomaticQuoteSubstitutionEnabled", func(v string) { d.Keyboard.DisableSmartQuotes = !parseDefaultsBool(v) }},
+		{"NSGlobalDomain", "NSAutomaticDashSubstitutionEnabled", func(v string) { d.Keyboard.DisableSmartDashes = !parseDefaultsBool(v) }},
+	}
+
+	for _, r := range reads {
+		result, err := e.ctx.Executor.Run(ctx, "defaults", "read", r.domain, r.key)
+		if err != nil {
+			continue // Not set on this machine, keep the default
+		}
+		r.apply(strings.TrimSpace(result.Stdout))
+	}
+}
+
+func (e *Exporter) exportGit(ctx context.Context, cfg *config.Config) {
+	if !e.ctx.Executor.Exists("git") {
+		return
+	}
+
+	result, err := e.ctx.Executor.Run(ctx, "git", "config", "--global", "--list")
+	if err != nil {
+		return
+	}
+
+	git := parseGitConfigList(result.Stdout, secretRefs(e.ctx.Config.Git.Settings))
+	git.Configure = true
+	cfg.Git = git
+}
+
+func (e *Exporter) exportSSH(homeDir string, cfg *config.Config) {
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pubKey, err := os.ReadFile(filepath.Join(homeDir, ".ssh", name+".pub"))
+		if err != nil {
+			continue
+		}
+
+		fields := strings.Fields(string(pubKey))
+		if len(fields) < 2 {
+			continue
+		}
+
+		keyType, ok := sshKeyTypes[fields[0]]
+		if !ok {
+			continue
+		}
+
+		cfg.SSH.GenerateKey = true
+		cfg.SSH.KeyType = keyType
+		cfg.SSH.KeyFile = "~/.ssh/" + name
+		cfg.SSH.Comment = strings.Join(fields[2:], " ")
+		return
+	}
+
+	cfg.SSH.GenerateKey = false
+}
+
+// listLines runs a command and returns its non-empty output lines
+func (e *Exporter) listLines(ctx context.Context, name string, args ...string) []string {
+	items := []string{}
+
+	result, err := e.ctx.Executor.Run(ctx, name, args...)
+	if err != nil {
+		return items
+	}
+
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			items = append(items, line)
+		}
+	}
+
+	return items
+}
+
+// zshrcSettings holds the values found in a .zshrc file
+type zshrcSettings struct {
+	Plugins     []string
+	Theme       string
+	Aliases     map[string]string
+	Environment map[string]string
+}
+
+// parseZshrc extracts plugins, theme, aliases and exports from .zshrc content
+func parseZshrc(content string) zshrcSettings {
+	settings := zshrcSettings{
+		Aliases:     make(map[string]string),
+		Environment: make(map[string]string),
+	}
+
+	// Variables set by Oh-My-Zsh or the system, not by the user's config
+	skipExports := map[string]bool{"ZSH": true, "PATH": true}
+
+	inPlugins := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inPlugins {
+			list := line
+			if idx := strings.Index(list, ")"); idx != -1 {
+				list = list[:idx]
+				inPlugins = false
+			}
+			settings.Plugins = append(settings.Plugins, strings.Fields(list)...)
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "plugins=("):
+			list := strings.TrimPrefix(line, "plugins=(")
+			settings.Plugins = []string{}
+			if idx := strings.Index(list, ")"); idx != -1 {
+				list = list[:idx]
+			} else {
+				inPlugins = true
+			}
+			settings.Plugins = append(settings.Plugins, strings.Fields(list)...)
+
+		case strings.HasPrefix(line, "ZSH_THEME="):
+			settings.Theme = unquote(strings.TrimPrefix(line, "ZSH_THEME="))
+
+		case strings.HasPrefix(line, "alias "):
+			name, value, ok := strings.Cut(strings.TrimPrefix(line, "alias "), "=")
+			if ok && value != "" {
+				settings.Aliases[strings.TrimSpace(name)] = unquote(value)
+			}
+
+		case strings.HasPrefix(line, "export "):
+			name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+			name = strings.TrimSpace(name)
+			if ok && !skipExports[name] {
+				settings.Environment[name] = unquote(value)
+			}
+		}
+	}
+
+	return settings
+}
+
+// secretRefs returns the settings that are secret references, keyed by
+// lowercase key like `git config --list` prints them
+func secretRefs(settings map[string]string) map[string]string {
+	refs := make(map[string]string)
+	for key, value := range settings {
+		if secrets.IsRef(value) {
+			refs[strings.ToLower(key)] = value
+		}
+	}
+	return refs
+}
+
+// parseGitConfigList splits `git config --list` output into user, aliases and
+// settings. Keys set from a secret reference in refs are exported as the
+// reference; other keys that look like credentials are left out.
+func parseGitConfigList(output string, refs map[string]string) config.GitConfig {
+	git := config.GitConfig{
+		Aliases:  make(map[string]string),
+		Settings: make(map[string]string),
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if ref, isRef := refs[strings.ToLower(key)]; isRef {
+			git.Settings[key] = ref
+			continue
+		}
+		if isCredentialKey(key) {
+			continue
+		}
+
+		switch {
+		case key == "user.name":
+			git.User.Name = value
+		case key == "user.email":
+			git.User.Email = value
+		case strings.HasPrefix(key, "alias."):
+			git.Aliases[strings.TrimPrefix(key, "alias.")] = value
+		default:
+			git.Settings[key] = value
+		}
+	}
+
+	return git
+}
+
+// isCredentialKey reports whether a git config key looks like it holds a credential
+func isCredentialKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range credentialKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDefaultsBool parses a boolean printed by `defaults read`
+func parseDefaultsBool(value string) bool {
+	return value == "1" || strings.EqualFold(value, "true") || strings.EqualFold(value, "yes")
+}
+
+// parseDefaultsInt parses a number printed by `defaults read`, rounding floats
+func parseDefaultsInt(value string) int {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(f))
+}
+
+// unquote strips one level of matching single or double quotes
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseZshrc(t *testing.T) {
+	content := `# Path to your oh-my-zsh installation.
+export ZSH="$HOME/.oh-my-zsh"
+ZSH_THEME="powerlevel10k/powerlevel10k"
+
+plugins=(
+  git
+  docker fzf
+)
+
+source $ZSH/oh-my-zsh.sh
+
+# Custom aliases (managed by setup-mac)
+alias ll='ls -la'
+alias gs="git status"
+# alias old='ignored'
+# End custom aliases
+
+export EDITOR="code --wait"
+export LANG=en_US.UTF-8
+export PATH="$HOME/bin:$PATH"
+`
+
+	settings := parseZshrc(content)
+
+	if settings.Theme != "powerlevel10k/powerlevel10k" {
+		t.Errorf("unexpected theme: %q", settings.Theme)
+	}
+
+	if want := []string{"git", "docker", "fzf"}; !reflect.DeepEqual(settings.Plugins, want) {
+		t.Errorf("expected plugins %v, got %v", want, settings.Plugins)
+	}
+
+	wantAliases := map[string]string{"ll": "ls -la", "gs": "git status"}
+	if !reflect.DeepEqual(settings.Aliases, wantAliases) {
+		t.Errorf("expected aliases %v, got %v", wantAliases, settings.Aliases)
+	}
+
+	wantEnv := map[string]string{"EDITOR": "code --wait", "LANG": "en_US.UTF-8"}
+	if !reflect.DeepEqual(settings.Environment, wantEnv) {
+		t.Errorf("expected environment %v, got %v", wantEnv, settings.Environment)
+	}
+}
+
+func TestParseZshrcSingleLinePlugins(t *testing.T) {
+	settings := parseZshrc("plugins=(git zsh-autosuggestions)\n")
+
+	if want := []string{"git", "zsh-autosuggestions"}; !reflect.DeepEqual(settings.Plugins, want) {
+		t.Errorf("expected plugins %v, got %v", want, settings.Plugins)
+	}
+}
+
+func TestParseGitConfigList(t *testing.T) {
+	output := `user.name=Jane Doe
+user.email=jane@example.com
+alias.st=status
+alias.lg=log --oneline --graph
+init.defaultbranch=main
+pull.rebase=true
+github.token=ghp_abc123
+http.https://example.com/.extraheader=AUTHORIZATION: bearer abc123
+`
+
+	git := parseGitConfigList(output, nil)
+
+	if git.User.Name != "Jane Doe" || git.User.Email != "jane@example.com" {
+		t.Errorf("unexpected user: %+v", git.User)
+	}
+
+	wantAliases := map[string]string{"st": "status", "lg": "log --oneline --graph"}
+	if !reflect.DeepEqual(git.Aliases, wantAliases) {
+		t.Errorf("expected aliases %v, got %v", wantAliases, git.Aliases)
+	}
+
+	wantSettings := map[string]string{"init.defaultbranch": "main", "pull.rebase": "true"}
+	if !reflect.DeepEqual(git.Settings, wantSettings) {
+		t.Errorf("expected settings %v, got %v", wantSettings, git.Settings)
+	}
+}
+
+func TestParseGitConfigListSecretRefs(t *testing.T) {
+	output := `github.token=ghp_abc123
+credential.helper=osxkeychain
+sendemail.smtppass=hunter2
+`
+
+	refs := secretRefs(map[string]string{
+		"github.token":       "secret://keychain:github/jane",
+		"sendemail.smtpPass": "secret://op://Private/SMTP/password",
+		"init.defaultBranch": "main",
+	})
+	git := parseGitConfigList(output, refs)
+
+	// Configured references replace the secrets, other settings are kept
+	want := map[string]string{
+		"github.token":       "secret://keychain:github/jane",
+		"sendemail.smtppass": "secret://op://Private/SMTP/password",
+		"credential.helper":  "osxkeychain",
+	}
+	if !reflect.DeepEqual(git.Settings, want) {
+		t.Errorf("expected settings %v, got %v", want, git.Settings)
+	}
+}
+
+func TestParseDefaultsValues(t *testing.T) {
+	if !parseDefaultsBool("1") || parseDefaultsBool("0") {
+		t.Error("unexpected bool parsing")
+	}
+
+	if got := parseDefaultsInt("0.5"); got != 1 {
+		t.Errorf("expected 0.5 to round to 1, got %d", got)
+	}
+
+	if got := parseDefaultsInt("48"); got != 48 {
+		t.Errorf("expected 48, got %d", got)
+	}
+}
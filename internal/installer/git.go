@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/secrets"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
@@ -154,13 +155,39 @@ func (g *GitInstaller) configureAliases(ctx context.Context) error {
 
 func (g *GitInstaller) configureSettings(ctx context.Context) error {
 	for key, value := range g.ctx.Config.Git.Settings {
-		if err := g.setConfig(ctx, key, value); err != nil {
+		var err error
+		if secrets.IsRef(value) {
+			err = g.setSecretConfig(ctx, key, value)
+		} else {
+			err = g.setConfig(ctx, key, value)
+		}
+		if err != nil {
 			ui.PrintWarning(fmt.Sprintf("Failed to set %s: %v", key, err))
 		}
 	}
 	return nil
 }
 
+// setSecretConfig resolves a secret reference and sets it without printing the value
+func (g *GitInstaller) setSecretConfig(ctx context.Context, key, ref string) error {
+	if g.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf("git config --global %s %q (resolved from %s)", key, secrets.Mask, ref))
+		return nil
+	}
+
+	value, err := g.ctx.Secrets.Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.ctx.Executor.Run(ctx, "git", "config", "--global", key, value); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Set git config: %s = %s", key, secrets.Mask))
+	return nil
+}
+
 func (g *GitInstaller) setConfig(ctx context.Context, key, value string) error {
 	if g.ctx.DryRun {
 		ui.PrintDryRun(fmt.Sprintf("git config --global %s %q", key, value))
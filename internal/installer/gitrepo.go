@@ -0,0 +1,256 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// ErrLocalChanges is returned for a checkout with uncommitted changes when
+// the policy is skip or abort, and for a shallow clone with local commits,
+// which cannot be stashed
+var ErrLocalChanges = errors.New("local changes")
+
+// ErrStashNotRestored means the checkout was updated but the stashed local
+// changes conflict with the update. They are left in git stash.
+var ErrStashNotRestored = errors.New("local changes conflict with the update and were left in git stash")
+
+// GitRepo is a git checkout kept up to date by GitRepoUpdater
+type GitRepo struct {
+	Name string
+	Path string
+	// Ref pins the checkout to a tag, branch or commit. Empty follows the
+	// upstream branch, or the remote's default branch when detached.
+	Ref string
+}
+
+// GitRepoUpdater updates git checkouts such as Oh My Zsh and its plugins
+type GitRepoUpdater struct {
+	exec *executor.Executor
+	// LocalChanges is config.LocalChangesStash, Skip or Abort
+	LocalChanges string
+}
+
+// NewGitRepoUpdater creates a git checkout updater
+func NewGitRepoUpdater(exec *executor.Executor, localChanges string) *GitRepoUpdater {
+	return &GitRepoUpdater{exec: exec, LocalChanges: localChanges}
+}
+
+// Update moves the checkout to its pinned ref or the tip of its upstream
+// branch. It returns the commit range HEAD moved by, or nil if nothing
+// changed. With the stash policy a non-nil change can come with
+// ErrStashNotRestored.
+func (g *GitRepoUpdater) Update(ctx context.Context, repo GitRepo) (*RepoChange, error) {
+	dir := repo.Path
+	before := gitOutput(ctx, g.exec, dir, "rev-parse", "HEAD")
+	if before == "" {
+		return nil, fmt.Errorf("not a git repository: %s", dir)
+	}
+
+	dirty := gitOutput(ctx, g.exec, dir, "status", "--porcelain", "--untracked-files=no") != ""
+	if dirty && g.LocalChanges != config.LocalChangesStash {
+		return nil, ErrLocalChanges
+	}
+	shallow := gitOutput(ctx, g.exec, dir, "rev-parse", "--is-shallow-repository") == "true"
+
+	remote, branch, err := g.upstream(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shallow clones are reset rather than rebased, which would drop local
+	// commits on top of the upstream branch
+	if shallow && repo.Ref == "" {
+		if count := g.localCommits(ctx, dir, remote+"/"+branch, before); count != "" {
+			return nil, fmt.Errorf("%w: %s commit(s) not on %s/%s in a shallow clone", ErrLocalChanges, count, remote, branch)
+		}
+	}
+
+	if g.exec.DryRun {
+		target := remote + "/" + branch
+		if repo.Ref != "" {
+			target = repo.Ref + " (pinned)"
+		}
+		if dirty {
+			target += ", stashing local changes"
+		}
+		ui.PrintDryRun(fmt.Sprintf("Would update %s to %s", dir, target))
+		return nil, nil
+	}
+
+	var target string
+	if repo.Ref != "" {
+		target, err = g.fetchPinned(ctx, dir, remote, repo.Ref, shallow)
+	} else {
+		target, err = g.fetch(ctx, dir, remote, branch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, fmt.Errorf("could not resolve the fetched commit in %s", dir)
+	}
+	if target == before {
+		return nil, nil
+	}
+
+	if dirty {
+		if err := g.git(ctx, dir, "stash", "push", "--quiet", "-m", "setup-mac update"); err != nil {
+			return nil, err
+		}
+	}
+
+	if repo.Ref != "" {
+		err = g.git(ctx, dir, "checkout", "--quiet", "--detach", target)
+	} else {
+		err = g.advance(ctx, dir, remote, branch, target, shallow)
+	}
+
+	var stashErr error
+	if dirty {
+		if popErr := g.git(ctx, dir, "stash", "pop", "--quiet"); popErr != nil {
+			// pop keeps the stash entry when it conflicts
+			_ = g.git(ctx, dir, "reset", "--quiet", "--hard")
+			stashErr = ErrStashNotRestored
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after := gitOutput(ctx, g.exec, dir, "rev-parse", "HEAD")
+	if after == before {
+		return nil, stashErr
+	}
+	change := &RepoChange{Name: repo.Name, Path: dir, From: before, To: after}
+	if count := gitOutput(ctx, g.exec, dir, "rev-list", "--count", before+".."+after); count != "" {
+		change.Commits, _ = strconv.Atoi(count)
+	}
+	return change, stashErr
+}
+
+// upstream returns the remote and branch the checkout follows. A detached
+// checkout follows the remote's default branch.
+func (g *GitRepoUpdater) upstream(ctx context.Context, dir string) (remote, branch string, err error) {
+	remote = "origin"
+	branch = gitOutput(ctx, g.exec, dir, "symbolic-ref", "--short", "-q", "HEAD")
+	if branch == "" {
+		branch, err = g.defaultBranch(ctx, dir, remote)
+		return remote, branch, err
+	}
+
+	if r := gitOutput(ctx, g.exec, dir, "config", "branch."+branch+".remote"); r != "" {
+		remote = r
+	}
+	if merge := gitOutput(ctx, g.exec, dir, "config", "branch."+branch+".merge"); merge != "" {
+		return remote, strings.TrimPrefix(merge, "refs/heads/"), nil
+	}
+	return remote, branch, nil
+}
+
+// defaultBranch reads the remote's HEAD, locally if the clone recorded it
+func (g *GitRepoUpdater) defaultBranch(ctx context.Context, dir, remote string) (string, error) {
+	if ref := gitOutput(ctx, g.exec, dir, "symbolic-ref", "--short", "-q", "refs/remotes/"+remote+"/HEAD"); ref != "" {
+		return strings.TrimPrefix(ref, remote+"/"), nil
+	}
+
+	result, err := g.exec.Query(ctx, "git", "-C", dir, "ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	// ref: refs/heads/main	HEAD
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			return strings.Fields(ref)[0], nil
+		}
+	}
+	return "", fmt.Errorf("could not find the default branch of %s", remote)
+}
+
+// fetch fetches the upstream branch and returns its commit
+func (g *GitRepoUpdater) fetch(ctx context.Context, dir, remote, branch string) (string, error) {
+	if err := g.git(ctx, dir, "fetch", "--quiet", remote, branch); err != nil {
+		return "", err
+	}
+	return gitOutput(ctx, g.exec, dir, "rev-parse", "FETCH_HEAD^{commit}"), nil
+}
+
+// fetchPinned returns the commit of a pinned tag, branch or commit
+func (g *GitRepoUpdater) fetchPinned(ctx context.Context, dir, remote, ref string, shallow bool) (string, error) {
+	// Full clones can resolve tags, branches and short hashes locally.
+	// Shallow ones fetch just the pinned commit instead of all tags.
+	if !shallow {
+		if err := g.git(ctx, dir, "fetch", "--quiet", "--tags", remote); err != nil {
+			return "", err
+		}
+		for _, name := range []string{remote + "/" + ref, ref} {
+			if sha := gitOutput(ctx, g.exec, dir, "rev-parse", "--verify", "-q", name+"^{commit}"); sha != "" {
+				return sha, nil
+			}
+		}
+	}
+
+	args := []string{"fetch", "--quiet"}
+	if shallow {
+		args = append(args, "--depth=1")
+	}
+	if err := g.git(ctx, dir, append(args, remote, ref)...); err != nil {
+		return "", fmt.Errorf("pinned ref %s not found: %w", ref, err)
+	}
+	return gitOutput(ctx, g.exec, dir, "rev-parse", "FETCH_HEAD^{commit}"), nil
+}
+
+// localCommits returns how many commits HEAD has on top of the upstream
+// ref as fetched last time, or "" if none
+func (g *GitRepoUpdater) localCommits(ctx context.Context, dir, upstream, head string) string {
+	base := gitOutput(ctx, g.exec, dir, "rev-parse", "--verify", "-q", upstream+"^{commit}")
+	if base == "" || base == head {
+		return ""
+	}
+	if count := gitOutput(ctx, g.exec, dir, "rev-list", "--count", base+".."+head); count != "0" {
+		return count
+	}
+	return ""
+}
+
+// advance moves the branch to target: a fast-forward when possible,
+// otherwise local commits are rebased on top. Shallow clones lack the
+// history to rebase and are reset to target; Update has already skipped
+// the ones with local commits.
+func (g *GitRepoUpdater) advance(ctx context.Context, dir, remote, branch, target string, shallow bool) error {
+	// Back on the branch after a pin was removed
+	if gitOutput(ctx, g.exec, dir, "symbolic-ref", "-q", "HEAD") == "" {
+		if err := g.git(ctx, dir, "checkout", "--quiet", "-B", branch, target); err != nil {
+			return err
+		}
+		_ = g.git(ctx, dir, "branch", "--quiet", "--set-upstream-to="+remote+"/"+branch)
+		return nil
+	}
+
+	if err := g.git(ctx, dir, "merge", "--quiet", "--ff-only", target); err == nil {
+		return nil
+	}
+	if shallow {
+		return g.git(ctx, dir, "reset", "--quiet", "--hard", target)
+	}
+	if err := g.git(ctx, dir, "rebase", "--quiet", target); err != nil {
+		_ = g.git(ctx, dir, "rebase", "--abort")
+		return err
+	}
+	return nil
+}
+
+// git runs a git command in dir
+func (g *GitRepoUpdater) git(ctx context.Context, dir string, args ...string) error {
+	result, err := g.exec.Run(ctx, "git", append([]string{"-C", dir}, args...)...)
+	if err != nil {
+		return fmt.Errorf("git %s failed: %w\n%s", args[0], err, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// gitIdentity lets the updater's own git commands (stash, rebase) commit
+func gitIdentity(t *testing.T) {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+}
+
+// pushFile commits a file to the upstream's main branch
+func pushFile(t *testing.T, upstream, name, content string) {
+	t.Helper()
+	work := t.TempDir()
+	git(t, work, "clone", "--quiet", upstream, ".")
+	if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, work, "add", name)
+	git(t, work, "commit", "-m", "edit "+name)
+	git(t, work, "push", "--quiet", "origin", "HEAD:main")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitRepoUpdaterFastForward(t *testing.T) {
+	upstream, clone, commit := gitUpstream(t)
+	gitIdentity(t)
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+	repo := GitRepo{Name: "plugin", Path: clone}
+
+	if change, err := updater.Update(context.Background(), repo); err != nil || change != nil {
+		t.Fatalf("expected no change, got %+v, %v", change, err)
+	}
+
+	before := git(t, clone, "rev-parse", "HEAD")
+	commit()
+	commit()
+	change, err := updater.Update(context.Background(), repo)
+	if err != nil || change == nil {
+		t.Fatalf("expected a change, got %+v, %v", change, err)
+	}
+	tip := git(t, upstream, "rev-parse", "main")
+	if change.From != before || change.To != tip || change.Commits != 2 || change.Name != "plugin" {
+		t.Errorf("unexpected change: %+v", change)
+	}
+	if head := git(t, clone, "rev-parse", "HEAD"); head != tip {
+		t.Errorf("HEAD = %s, want %s", head, tip)
+	}
+}
+
+func TestGitRepoUpdaterRebasesLocalCommits(t *testing.T) {
+	upstream, clone, commit := gitUpstream(t)
+	gitIdentity(t)
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+
+	git(t, clone, "commit", "--allow-empty", "-m", "local")
+	commit()
+	change, err := updater.Update(context.Background(), GitRepo{Name: "plugin", Path: clone})
+	if err != nil || change == nil {
+		t.Fatalf("expected a change, got %+v, %v", change, err)
+	}
+	if parent := git(t, clone, "rev-parse", "HEAD~1"); parent != git(t, upstream, "rev-parse", "main") {
+		t.Errorf("local commit not rebased onto the upstream")
+	}
+	if subject := git(t, clone, "log", "-1", "--format=%s"); subject != "local" {
+		t.Errorf("local commit lost, HEAD is %q", subject)
+	}
+}
+
+func TestGitRepoUpdaterDetachedUsesDefaultBranch(t *testing.T) {
+	upstream, clone, commit := gitUpstream(t)
+	gitIdentity(t)
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+
+	git(t, clone, "checkout", "--quiet", "--detach")
+	commit()
+	if _, err := updater.Update(context.Background(), GitRepo{Name: "plugin", Path: clone}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if branch := git(t, clone, "symbolic-ref", "--short", "HEAD"); branch != "main" {
+		t.Errorf("expected to be back on main, got %s", branch)
+	}
+	if git(t, clone, "rev-parse", "HEAD") != git(t, upstream, "rev-parse", "main") {
+		t.Error("HEAD is not at the upstream tip")
+	}
+}
+
+func TestGitRepoUpdaterLocalChanges(t *testing.T) {
+	upstream, clone, _ := gitUpstream(t)
+	gitIdentity(t)
+	pushFile(t, upstream, "plugin.zsh", "upstream\n")
+	git(t, clone, "pull", "--quiet")
+	ctx := context.Background()
+	repo := GitRepo{Name: "plugin", Path: clone}
+
+	pushFile(t, upstream, "README", "new\n")
+	writeFile(t, filepath.Join(clone, "plugin.zsh"), "local\n")
+	before := git(t, clone, "rev-parse", "HEAD")
+
+	for _, policy := range []string{config.LocalChangesSkip, config.LocalChangesAbort} {
+		change, err := NewGitRepoUpdater(executor.New(false, false), policy).Update(ctx, repo)
+		if !errors.Is(err, ErrLocalChanges) || change != nil {
+			t.Errorf("%s: expected ErrLocalChanges, got %+v, %v", policy, change, err)
+		}
+		if git(t, clone, "rev-parse", "HEAD") != before {
+			t.Errorf("%s: HEAD moved", policy)
+		}
+	}
+
+	// Stash restores the edit on top of the update
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+	if change, err := updater.Update(ctx, repo); err != nil || change == nil {
+		t.Fatalf("expected a change, got %+v, %v", change, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(clone, "plugin.zsh")); string(data) != "local\n" {
+		t.Errorf("local change lost: %q", data)
+	}
+
+	// A conflicting edit stays in the stash and the checkout is clean
+	pushFile(t, upstream, "plugin.zsh", "upstream 2\n")
+	change, err := updater.Update(ctx, repo)
+	if !errors.Is(err, ErrStashNotRestored) || change == nil {
+		t.Fatalf("expected ErrStashNotRestored with a change, got %+v, %v", change, err)
+	}
+	if status := git(t, clone, "status", "--porcelain", "--untracked-files=no"); status != "" {
+		t.Errorf("checkout not clean:\n%s", status)
+	}
+	if stash := git(t, clone, "stash", "list"); stash == "" {
+		t.Error("local change not kept in the stash")
+	}
+}
+
+func TestGitRepoUpdaterShallowClone(t *testing.T) {
+	upstream, _, commit := gitUpstream(t)
+	gitIdentity(t)
+	commit()
+	clone := filepath.Join(t.TempDir(), "shallow")
+	git(t, ".", "clone", "--quiet", "--depth=1", "file://"+upstream, clone)
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+	repo := GitRepo{Name: "powerlevel10k", Path: clone}
+
+	commit()
+	commit()
+	change, err := updater.Update(context.Background(), repo)
+	if err != nil || change == nil {
+		t.Fatalf("expected a change, got %+v, %v", change, err)
+	}
+	if change.Commits != 2 || change.To != git(t, upstream, "rev-parse", "main") {
+		t.Errorf("unexpected change: %+v", change)
+	}
+
+	// Shallow clones follow the upstream even when it was rewritten
+	work := t.TempDir()
+	git(t, work, "clone", "--quiet", upstream, ".")
+	git(t, work, "commit", "--quiet", "--amend", "--allow-empty", "-m", "rewritten")
+	git(t, work, "push", "--quiet", "--force", "origin", "HEAD:main")
+	if _, err := updater.Update(context.Background(), repo); err != nil {
+		t.Fatalf("Update after force push failed: %v", err)
+	}
+	if git(t, clone, "rev-parse", "HEAD") != git(t, upstream, "rev-parse", "main") {
+		t.Error("HEAD is not at the rewritten upstream tip")
+	}
+	if shallow := git(t, clone, "rev-parse", "--is-shallow-repository"); shallow != "true" {
+		t.Error("clone is no longer shallow")
+	}
+}
+
+func TestGitRepoUpdaterShallowCloneLocalCommits(t *testing.T) {
+	upstream, _, commit := gitUpstream(t)
+	gitIdentity(t)
+	commit()
+	clone := filepath.Join(t.TempDir(), "shallow")
+	git(t, ".", "clone", "--quiet", "--depth=1", "file://"+upstream, clone)
+	git(t, clone, "commit", "--quiet", "--allow-empty", "-m", "local")
+	local := git(t, clone, "rev-parse", "HEAD")
+	commit()
+
+	for _, policy := range []string{config.LocalChangesStash, config.LocalChangesSkip, config.LocalChangesAbort} {
+		updater := NewGitRepoUpdater(executor.New(false, false), policy)
+		change, err := updater.Update(context.Background(), GitRepo{Name: "powerlevel10k", Path: clone})
+		if !errors.Is(err, ErrLocalChanges) || change != nil {
+			t.Errorf("%s: expected ErrLocalChanges, got %+v, %v", policy, change, err)
+		}
+		if head := git(t, clone, "rev-parse", "HEAD"); head != local {
+			t.Errorf("%s: local commit was dropped, HEAD is %s", policy, head)
+		}
+	}
+}
+
+func TestGitRepoUpdaterPinnedRef(t *testing.T) {
+	upstream, clone, commit := gitUpstream(t)
+	gitIdentity(t)
+	commit()
+	tagged := git(t, upstream, "rev-parse", "main")
+	git(t, upstream, "tag", "v1.0", tagged)
+	commit()
+	ctx := context.Background()
+	updater := NewGitRepoUpdater(executor.New(false, false), config.LocalChangesStash)
+
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	git(t, ".", "clone", "--quiet", "--depth=1", "file://"+upstream, shallow)
+
+	for _, dir := range []string{clone, shallow} {
+		repo := GitRepo{Name: "plugin", Path: dir, Ref: "v1.0"}
+		if _, err := updater.Update(ctx, repo); err != nil {
+			t.Fatalf("%s: Update failed: %v", dir, err)
+		}
+		if head := git(t, dir, "rev-parse", "HEAD"); head != tagged {
+			t.Errorf("%s: HEAD = %s, want the v1.0 commit %s", dir, head, tagged)
+		}
+		if change, err := updater.Update(ctx, repo); err != nil || change != nil {
+			t.Errorf("%s: expected pinned checkout to stay, got %+v, %v", dir, change, err)
+		}
+	}
+
+	// Removing the pin returns to the default branch
+	commit()
+	change, err := updater.Update(ctx, GitRepo{Name: "plugin", Path: clone})
+	if err != nil || change == nil || change.From != tagged {
+		t.Fatalf("expected a change from the pinned commit, got %+v, %v", change, err)
+	}
+	if branch := git(t, clone, "symbolic-ref", "--short", "HEAD"); branch != "main" {
+		t.Errorf("expected to be back on main, got %s", branch)
+	}
+
+	if _, err := updater.Update(ctx, GitRepo{Name: "plugin", Path: clone, Ref: "v9"}); err == nil {
+		t.Error("expected an error for a missing ref")
+	}
+}
+
+func TestGitRepoUpdaterDryRun(t *testing.T) {
+	_, clone, commit := gitUpstream(t)
+	commit()
+	before := git(t, clone, "rev-parse", "HEAD")
+
+	updater := NewGitRepoUpdater(executor.New(true, false), config.LocalChangesStash)
+	if change, err := updater.Update(context.Background(), GitRepo{Name: "plugin", Path: clone}); err != nil || change != nil {
+		t.Errorf("expected nothing in dry-run, got %+v, %v", change, err)
+	}
+	if git(t, clone, "rev-parse", "HEAD") != before {
+		t.Error("dry-run moved HEAD")
+	}
+}
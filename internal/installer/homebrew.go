@@ -4,16 +4,19 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
 const (
-	homebrewInstallScript = "https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh"
-	homebrewPath          = "/opt/homebrew/bin/brew"
-	homebrewPathIntel     = "/usr/local/bin/brew"
+	homebrewPath      = "/opt/homebrew/bin/brew"
+	homebrewPathIntel = "/usr/local/bin/brew"
 )
 
 // HomebrewInstaller handles Homebrew installation
@@ -36,9 +39,35 @@ func (h *HomebrewInstaller) Description() string {
 	return "Homebrew Package Manager"
 }
 
-// IsInstalled checks if Homebrew is installed
+// IsInstalled checks if Homebrew is installed with the configured taps,
+// formulae, casks, pins, services and lock file in place. Prune mode
+// always runs.
 func (h *HomebrewInstaller) IsInstalled(ctx context.Context) bool {
-	return h.ctx.Executor.Exists("brew")
+	cfg := h.ctx.Config.Homebrew
+	if !h.ctx.Executor.Exists("brew") {
+		return false
+	}
+	if !cfg.Install {
+		return true
+	}
+	if cfg.Prune {
+		return false
+	}
+
+	packages, err := BrewPackages(cfg)
+	if err != nil {
+		return false
+	}
+	state, err := LoadBrewState(ctx, h.ctx.Executor)
+	if err != nil {
+		return false
+	}
+
+	return h.tapsInstalled(ctx, packages.Taps) &&
+		h.packagesInstalled(ctx, state, packages) &&
+		formulaePinned(state, cfg.Formulae) &&
+		h.servicesInState(ctx, cfg.Services) &&
+		brewLockCurrent(state, packages, cfg.LockFile)
 }
 
 // Install installs Homebrew and configured packages
@@ -51,7 +80,7 @@ func (h *HomebrewInstaller) Install(ctx context.Context) error {
 	}
 
 	// Install Homebrew if not present
-	if !h.IsInstalled(ctx) {
+	if !h.ctx.Executor.Exists("brew") {
 		ui.PrintStep("Installing Homebrew...")
 		if err := h.installHomebrew(ctx); err != nil {
 			return fmt.Errorf("failed to install Homebrew: %w", err)
@@ -60,50 +89,198 @@ func (h *HomebrewInstaller) Install(ctx context.Context) error {
 		ui.PrintInfo("Homebrew already installed")
 	}
 
+	// Make brew available in new login shells
+	if err := h.persistShellenv(); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to add brew shellenv to %s: %v", cfg.ShellenvFile, err))
+	}
+
+	packages, err := BrewPackages(cfg)
+	if err != nil {
+		return err
+	}
+	for _, warning := range packages.Warnings {
+		ui.PrintWarning(fmt.Sprintf("Brewfile: %s", warning))
+	}
+
+	// Refresh Homebrew once so the commands below can skip their own auto-update
+	if len(packages.Taps)+len(packages.Brews)+len(packages.Casks) > 0 {
+		h.update(ctx)
+	}
+
 	// Add taps
-	if len(cfg.Taps) > 0 {
+	if len(packages.Taps) > 0 {
 		ui.PrintStep("Adding taps...")
-		for _, tap := range cfg.Taps {
+		for _, tap := range packages.Taps {
 			if err := h.addTap(ctx, tap); err != nil {
-				ui.PrintWarning(fmt.Sprintf("Failed to add tap %s: %v", tap, err))
+				ui.PrintWarning(fmt.Sprintf("Failed to add tap %s: %v", tap.Name, err))
 			}
 		}
 	}
 
 	// Install formulae
-	if len(cfg.Formulae) > 0 {
+	if len(packages.Brews) > 0 {
 		ui.PrintStep("Installing formulae...")
-		if err := h.installFormulae(ctx, cfg.Formulae); err != nil {
+		if err := h.installFormulae(ctx, packages.Brews); err != nil {
 			return fmt.Errorf("failed to install formulae: %w", err)
 		}
 	}
 
 	// Install casks
-	if len(cfg.Casks) > 0 {
+	if len(packages.Casks) > 0 {
 		ui.PrintStep("Installing casks...")
-		if err := h.installCasks(ctx, cfg.Casks); err != nil {
+		if err := h.installCasks(ctx, packages.Casks); err != nil {
 			return fmt.Errorf("failed to install casks: %w", err)
 		}
 	}
 
+	// Pin formulae and check version constraints
+	if len(packages.Brews) > 0 {
+		state := h.state(ctx)
+		pinFormulae(ctx, h.ctx.Executor, state, cfg.Formulae, h.ctx.DryRun)
+		checkConstraints(state, cfg.Formulae)
+	}
+
+	// Start and stop services
+	if len(cfg.Services) > 0 {
+		ui.PrintStep("Configuring services...")
+		if err := h.reconcileServices(ctx, cfg.Services); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Could not configure services: %v", err))
+		}
+	}
+
+	// Remove packages that are no longer in the config
+	if cfg.Prune {
+		if err := h.prune(ctx, packages); err != nil {
+			return fmt.Errorf("failed to prune packages: %w", err)
+		}
+	}
+
+	writeBrewLock(ctx, h.ctx.Executor, cfg, h.ctx.DryRun)
+
 	return nil
 }
 
-func (h *HomebrewInstaller) installHomebrew(ctx context.Context) error {
-	cmd := fmt.Sprintf(`/bin/bash -c "$(curl -fsSL %s)"`, homebrewInstallScript)
+// BrewPackages returns the configured taps, formulae, casks and App Store
+// apps followed by the entries of homebrew.brewfile that are not already listed
+func BrewPackages(cfg config.HomebrewConfig) (*brewfile.Brewfile, error) {
+	packages := &brewfile.Brewfile{}
+	for _, tap := range cfg.Taps {
+		packages.Taps = append(packages.Taps, brewfile.Tap{Name: tap.Name, URL: tap.URL})
+	}
+	for _, formula := range cfg.Formulae {
+		packages.Brews = append(packages.Brews, brewfile.Package{Name: formula.Name, Args: expandArgs(formula.Args)})
+	}
+	for _, cask := range cfg.Casks {
+		packages.Casks = append(packages.Casks, brewfile.Package{Name: cask.Name, Args: expandArgs(cask.Args), Greedy: cask.Greedy})
+	}
+	for _, app := range cfg.MasApps {
+		packages.Mas = append(packages.Mas, brewfile.MasApp{Name: app.Name, ID: app.ID})
+	}
 
+	if cfg.Brewfile == "" {
+		return packages, nil
+	}
+
+	bf, err := brewfile.ParseFile(cfg.Brewfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Brewfile: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, tap := range packages.Taps {
+		seen["tap:"+tap.Name] = true
+	}
+	for _, pkg := range packages.Brews {
+		seen["brew:"+pkg.Name] = true
+	}
+	for _, pkg := range packages.Casks {
+		seen["cask:"+pkg.Name] = true
+	}
+	for _, app := range packages.Mas {
+		seen["mas:"+strconv.Itoa(app.ID)] = true
+	}
+
+	for _, tap := range bf.Taps {
+		if !seen["tap:"+tap.Name] {
+			packages.Taps = append(packages.Taps, tap)
+		}
+	}
+	for _, pkg := range bf.Brews {
+		if !seen["brew:"+pkg.Name] {
+			packages.Brews = append(packages.Brews, pkg)
+		}
+	}
+	for _, pkg := range bf.Casks {
+		if !seen["cask:"+pkg.Name] {
+			packages.Casks = append(packages.Casks, pkg)
+		}
+	}
+	for _, app := range bf.Mas {
+		if !seen["mas:"+strconv.Itoa(app.ID)] {
+			packages.Mas = append(packages.Mas, app)
+		}
+	}
+	packages.Warnings = bf.Warnings
+
+	return packages, nil
+}
+
+// expandArgs expands ~/ in flag values such as --appdir=~/Applications,
+// which brew does not do itself
+func expandArgs(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if flag, value, ok := strings.Cut(arg, "="); ok {
+			arg = flag + "=" + expandHome(value)
+		}
+		expanded[i] = arg
+	}
+	return expanded
+}
+
+// update runs a single brew update and disables auto-update for later brew commands
+func (h *HomebrewInstaller) update(ctx context.Context) {
+	spinner := ui.NewSpinner("Updating Homebrew...")
+	spinner.Start()
+
+	result, err := h.ctx.Executor.Run(ctx, "brew", "update")
+	switch {
+	case err != nil:
+		spinner.Warning("brew update failed, continuing with the current package index")
+	case result.DryRun:
+		spinner.Info("[DRY-RUN] Would update Homebrew")
+	default:
+		spinner.Success("Homebrew updated")
+	}
+
+	h.ctx.Executor.SetEnv("HOMEBREW_NO_AUTO_UPDATE", "1")
+}
+
+func (h *HomebrewInstaller) installHomebrew(ctx context.Context) error {
+	script := h.ctx.Config.Homebrew.InstallScript
 	if h.ctx.DryRun {
-		ui.PrintDryRun(cmd)
+		ui.PrintDryRun(fmt.Sprintf("Homebrew install script: %s", describeScript("homebrew-install", script)))
+		ui.PrintDryRun("/bin/bash homebrew-install.sh")
 		return nil
 	}
 
+	path, err := prepareScript(ctx, h.ctx, "homebrew-install", script)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
 	// Run Homebrew installer interactively
-	if err := h.ctx.Executor.RunInteractive(ctx, "bash", "-c", cmd); err != nil {
+	if err := h.ctx.Executor.RunInteractive(ctx, "/bin/bash", path); err != nil {
 		return err
 	}
 
 	// Add brew to PATH for current session
-	brewPath := h.getBrewPath()
+	brewPath := filepath.Dir(brewBinary(runtime.GOARCH))
 	if _, err := os.Stat(brewPath); err == nil {
 		os.Setenv("PATH", fmt.Sprintf("%s:%s", brewPath, os.Getenv("PATH")))
 	}
@@ -111,218 +288,227 @@ func (h *HomebrewInstaller) installHomebrew(ctx context.Context) error {
 	return nil
 }
 
-func (h *HomebrewInstaller) getBrewPath() string {
-	if runtime.GOARCH == "arm64" {
-		return "/opt/homebrew/bin"
-	}
-	return "/usr/local/bin"
-}
-
-func (h *HomebrewInstaller) addTap(ctx context.Context, tap string) error {
-	spinner := ui.NewSpinner(fmt.Sprintf("Adding tap: %s", tap))
+func (h *HomebrewInstaller) addTap(ctx context.Context, tap brewfile.Tap) error {
+	spinner := ui.NewSpinner(fmt.Sprintf("Adding tap: %s", tap.Name))
 	spinner.Start()
 	defer spinner.Stop()
 
-	result, err := h.ctx.Executor.Run(ctx, "brew", "tap", tap)
+	args := []string{"tap", tap.Name}
+	if tap.URL != "" {
+		args = append(args, tap.URL)
+	}
+
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
 	if err != nil {
-		spinner.Fail(fmt.Sprintf("Failed to add tap: %s", tap))
+		spinner.Fail(fmt.Sprintf("Failed to add tap: %s", tap.Name))
 		return err
 	}
 
 	if result.DryRun {
-		spinner.Info(fmt.Sprintf("[DRY-RUN] Would add tap: %s", tap))
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would add tap: %s", tap.Name))
 	} else {
-		spinner.Success(fmt.Sprintf("Added tap: %s", tap))
+		spinner.Success(fmt.Sprintf("Added tap: %s", tap.Name))
 	}
 	return nil
 }
 
-func (h *HomebrewInstaller) installFormulae(ctx context.Context, formulae []string) error {
+func (h *HomebrewInstaller) installFormulae(ctx context.Context, formulae []brewfile.Package) error {
 	// Check which formulae are already installed
-	installed := h.getInstalledFormulae(ctx)
-
-	for _, formula := range formulae {
-		// Check if already installed (exact match or base name match for versioned packages)
-		if h.isFormulaInstalled(formula, installed) {
-			ui.PrintInfo(fmt.Sprintf("Formula already installed: %s", formula))
-			continue
-		}
-
-		spinner := ui.NewSpinner(fmt.Sprintf("Installing: %s", formula))
-		spinner.Start()
+	state := h.state(ctx)
 
-		result, err := h.ctx.Executor.Run(ctx, "brew", "install", formula)
-		if err != nil {
-			// Check if it's actually installed despite the error (e.g., already installed warning)
-			if h.isFormulaInstalled(formula, h.getInstalledFormulae(ctx)) {
-				spinner.Success(fmt.Sprintf("Already installed: %s", formula))
-				continue
-			}
-			spinner.Fail(fmt.Sprintf("Failed to install: %s", formula))
+	var missing []brewfile.Package
+	for _, pkg := range formulae {
+		if _, ok := state.Formula(pkg.Name); ok {
+			ui.PrintInfo(fmt.Sprintf("Formula already installed: %s", pkg.Name))
 			continue
 		}
+		missing = append(missing, pkg)
+	}
 
-		if result.DryRun {
-			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install: %s", formula))
-		} else {
-			spinner.Success(fmt.Sprintf("Installed: %s", formula))
-		}
+	// Install in batches, then one by one for anything that failed
+	for _, pkg := range h.installBatch(ctx, false, missing) {
+		h.installFormula(ctx, pkg)
 	}
 
 	return nil
 }
 
-// isFormulaInstalled checks if a formula is installed, handling versioned packages
-func (h *HomebrewInstaller) isFormulaInstalled(formula string, installed map[string]bool) bool {
-	// Exact match
-	if installed[formula] {
-		return true
-	}
+func (h *HomebrewInstaller) installFormula(ctx context.Context, pkg brewfile.Package) {
+	formula := pkg.Name
 
-	// Check for versioned variants (e.g., "node" matches "node@18", "node@20")
-	baseName := formula
-	if idx := strings.Index(formula, "@"); idx != -1 {
-		baseName = formula[:idx]
-	}
+	spinner := ui.NewSpinner(fmt.Sprintf("Installing: %s", formula))
+	spinner.Start()
 
-	for pkg := range installed {
-		pkgBase := pkg
-		if idx := strings.Index(pkg, "@"); idx != -1 {
-			pkgBase = pkg[:idx]
-		}
-		if pkgBase == baseName {
-			return true
+	args := append([]string{"install", formula}, pkg.Args...)
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+	if err != nil {
+		// Check if it's actually installed despite the error (e.g., already installed warning)
+		if _, ok := h.state(ctx).Formula(formula); ok {
+			spinner.Success(fmt.Sprintf("Already installed: %s", formula))
+			return
 		}
+		spinner.Fail(fmt.Sprintf("Failed to install: %s", formula))
+		return
 	}
 
-	return false
+	if result.DryRun {
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would install: %s", formula))
+	} else {
+		spinner.Success(fmt.Sprintf("Installed: %s", formula))
+	}
 }
 
-func (h *HomebrewInstaller) installCasks(ctx context.Context, casks []string) error {
+func (h *HomebrewInstaller) installCasks(ctx context.Context, casks []brewfile.Package) error {
 	// Check which casks are already installed
-	installed := h.getInstalledCasks(ctx)
+	state := h.state(ctx)
 
-	// Also check Applications folder for already installed apps
-	installedApps := h.getInstalledApplications()
-
-	for _, cask := range casks {
-		if installed[cask] {
-			ui.PrintInfo(fmt.Sprintf("Cask already installed: %s", cask))
+	var notInstalled []brewfile.Package
+	for _, pkg := range casks {
+		if state.CaskInstalled(pkg.Name) {
+			ui.PrintInfo(fmt.Sprintf("Cask already installed: %s", pkg.Name))
 			continue
 		}
+		notInstalled = append(notInstalled, pkg)
+	}
 
-		// Check if app is already in /Applications (manually installed)
-		if h.isCaskAppInstalled(cask, installedApps) {
-			ui.PrintInfo(fmt.Sprintf("Application already installed (not via Homebrew): %s", cask))
-			continue
+	// Look up the apps of the remaining casks to find manually installed ones
+	info := EmptyBrewState()
+	if len(notInstalled) > 0 && !h.ctx.DryRun {
+		names := make([]string, len(notInstalled))
+		for i, pkg := range notInstalled {
+			names[i] = pkg.Name
 		}
+		if loaded, err := LoadCaskInfo(ctx, h.ctx.Executor, names); err == nil {
+			info = loaded
+		}
+	}
 
-		spinner := ui.NewSpinner(fmt.Sprintf("Installing cask: %s", cask))
-		spinner.Start()
-
-		result, err := h.ctx.Executor.Run(ctx, "brew", "install", "--cask", cask)
-		if err != nil {
-			// Check if it failed because already installed
-			if result != nil && strings.Contains(result.Stderr, "already installed") {
-				spinner.Success(fmt.Sprintf("Already installed: %s", cask))
+	var missing []brewfile.Package
+	for _, pkg := range notInstalled {
+		if cask, ok := info.Cask(pkg.Name); ok {
+			if app, found := cask.appInstalled(caskAppDirs(pkg)); found {
+				ui.PrintInfo(fmt.Sprintf("Application already installed (not via Homebrew): %s (%s)", pkg.Name, app))
 				continue
 			}
-			spinner.Fail(fmt.Sprintf("Failed to install cask: %s", cask))
-			continue
 		}
+		missing = append(missing, pkg)
+	}
 
-		if result.DryRun {
-			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install cask: %s", cask))
-		} else {
-			spinner.Success(fmt.Sprintf("Installed cask: %s", cask))
-		}
+	// Install in batches, then one by one for anything that failed
+	for _, pkg := range h.installBatch(ctx, true, missing) {
+		h.installCask(ctx, pkg)
 	}
 
 	return nil
 }
 
-// getInstalledApplications returns a list of apps in /Applications
-func (h *HomebrewInstaller) getInstalledApplications() map[string]bool {
-	apps := make(map[string]bool)
-
-	entries, err := os.ReadDir("/Applications")
-	if err != nil {
-		return apps
-	}
-
-	for _, entry := range entries {
-		name := entry.Name()
-		// Remove .app suffix and lowercase for comparison
-		if strings.HasSuffix(name, ".app") {
-			name = strings.TrimSuffix(name, ".app")
-			apps[strings.ToLower(name)] = true
+// caskAppDirs returns the directories to look for a cask's apps in,
+// including its --appdir
+func caskAppDirs(pkg brewfile.Package) []string {
+	dirs := applicationDirs()
+	for _, arg := range pkg.Args {
+		if dir, ok := strings.CutPrefix(arg, "--appdir="); ok {
+			dirs = append(dirs, dir)
 		}
 	}
-
-	return apps
+	return dirs
 }
 
-// isCaskAppInstalled checks if a cask's app is already installed
-func (h *HomebrewInstaller) isCaskAppInstalled(cask string, installedApps map[string]bool) bool {
-	// Common cask name to app name mappings
-	caskToApp := map[string]string{
-		"visual-studio-code": "visual studio code",
-		"google-chrome":      "google chrome",
-		"sublime-text":       "sublime text",
-		"intellij-idea":      "intellij idea",
-		"intellij-idea-ce":   "intellij idea ce",
-	}
+func (h *HomebrewInstaller) installCask(ctx context.Context, pkg brewfile.Package) {
+	cask := pkg.Name
 
-	// Check mapping first
-	if appName, ok := caskToApp[cask]; ok {
-		return installedApps[appName]
+	spinner := ui.NewSpinner(fmt.Sprintf("Installing cask: %s", cask))
+	spinner.Start()
+
+	args := append([]string{"install", "--cask", cask}, pkg.Args...)
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+	if err != nil {
+		// Check if it failed because already installed
+		if result != nil && strings.Contains(result.Stderr, "already installed") {
+			spinner.Success(fmt.Sprintf("Already installed: %s", cask))
+			return
+		}
+		spinner.Fail(fmt.Sprintf("Failed to install cask: %s", cask))
+		return
 	}
 
-	// Try direct match (replace hyphens with spaces)
-	normalizedCask := strings.ReplaceAll(cask, "-", " ")
-	return installedApps[strings.ToLower(normalizedCask)] || installedApps[cask]
+	if result.DryRun {
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would install cask: %s", cask))
+	} else {
+		spinner.Success(fmt.Sprintf("Installed cask: %s", cask))
+	}
 }
 
-func (h *HomebrewInstaller) getInstalledFormulae(ctx context.Context) map[string]bool {
-	installed := make(map[string]bool)
-
-	if h.ctx.DryRun {
-		return installed
+// tapsInstalled checks if every tap is listed by brew tap
+func (h *HomebrewInstaller) tapsInstalled(ctx context.Context, taps []brewfile.Tap) bool {
+	if len(taps) == 0 {
+		return true
 	}
 
-	result, err := h.ctx.Executor.Run(ctx, "brew", "list", "--formula")
+	result, err := h.ctx.Executor.Query(ctx, "brew", "tap")
 	if err != nil {
-		return installed
+		return false
 	}
 
+	installed := make(map[string]bool)
 	for _, line := range strings.Split(result.Stdout, "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			installed[line] = true
+		installed[strings.ToLower(strings.TrimSpace(line))] = true
+	}
+	for _, tap := range taps {
+		if !installed[strings.ToLower(tap.Name)] {
+			return false
 		}
 	}
-
-	return installed
+	return true
 }
 
-func (h *HomebrewInstaller) getInstalledCasks(ctx context.Context) map[string]bool {
-	installed := make(map[string]bool)
+// packagesInstalled checks if every formula and cask is installed. Casks
+// whose app was installed without Homebrew count as installed, as in Install.
+func (h *HomebrewInstaller) packagesInstalled(ctx context.Context, state *BrewState, packages *brewfile.Brewfile) bool {
+	for _, pkg := range packages.Brews {
+		if _, ok := state.Formula(pkg.Name); !ok {
+			return false
+		}
+	}
 
-	if h.ctx.DryRun {
-		return installed
+	var missing []string
+	for _, pkg := range packages.Casks {
+		if !state.CaskInstalled(pkg.Name) {
+			missing = append(missing, pkg.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return true
 	}
 
-	result, err := h.ctx.Executor.Run(ctx, "brew", "list", "--cask")
+	info, err := LoadCaskInfo(ctx, h.ctx.Executor, missing)
 	if err != nil {
-		return installed
+		return false
 	}
-
-	for _, line := range strings.Split(result.Stdout, "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			installed[line] = true
+	dirs := applicationDirs()
+	for _, name := range missing {
+		cask, ok := info.Cask(name)
+		if !ok {
+			return false
+		}
+		if _, found := cask.appInstalled(dirs); !found {
+			return false
 		}
 	}
+	return true
+}
+
+// state returns the installed packages, or an empty state in dry-run mode
+// or when brew info fails
+func (h *HomebrewInstaller) state(ctx context.Context) *BrewState {
+	if h.ctx.DryRun {
+		return EmptyBrewState()
+	}
 
-	return installed
+	state, err := LoadBrewState(ctx, h.ctx.Executor)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not read installed packages: %v", err))
+		return EmptyBrewState()
+	}
+	return state
 }
@@ -0,0 +1,199 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestBrewPackagesMergesBrewfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Brewfile")
+	content := `tap "homebrew/cask-fonts"
+brew "wget", args: ["HEAD"]
+brew "imagemagick"
+cask "firefox"
+mas "Xcode", id: 497799835
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write Brewfile: %v", err)
+	}
+
+	packages, err := BrewPackages(config.HomebrewConfig{
+		Formulae: config.NewFormulae([]string{"git", "wget"}),
+		Casks:    config.NewCasks([]string{"iterm2"}),
+		Taps:     config.NewTaps([]string{"homebrew/cask-fonts"}),
+		Brewfile: path,
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve packages: %v", err)
+	}
+
+	if len(packages.Taps) != 1 {
+		t.Errorf("expected duplicate tap to be skipped, got %+v", packages.Taps)
+	}
+
+	// Config entries come first and win over Brewfile duplicates
+	var names []string
+	for _, pkg := range packages.Brews {
+		names = append(names, pkg.Name)
+	}
+	if len(names) != 3 || names[0] != "git" || names[1] != "wget" || names[2] != "imagemagick" {
+		t.Errorf("unexpected formulae %v", names)
+	}
+	if len(packages.Brews[1].Args) != 0 {
+		t.Errorf("expected config wget without args, got %v", packages.Brews[1].Args)
+	}
+
+	if len(packages.Casks) != 2 || len(packages.Mas) != 1 {
+		t.Errorf("expected 2 casks and 1 app, got %+v, %+v", packages.Casks, packages.Mas)
+	}
+}
+
+func TestBrewPackagesMissingBrewfile(t *testing.T) {
+	if _, err := BrewPackages(config.HomebrewConfig{Brewfile: "/nonexistent/Brewfile"}); err == nil {
+		t.Error("expected error for missing Brewfile")
+	}
+}
+
+func TestBrewPackagesObjectForms(t *testing.T) {
+	home, _ := os.UserHomeDir()
+
+	packages, err := BrewPackages(config.HomebrewConfig{
+		Taps:     []config.Tap{{Name: "acme/tools", URL: "git@github.com:acme/homebrew-tools.git"}},
+		Formulae: []config.Formula{{Name: "neovim", Args: []string{"--HEAD"}}},
+		Casks:    []config.Cask{{Name: "firefox", Args: []string{"--appdir=~/Applications"}, Greedy: true}},
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve packages: %v", err)
+	}
+
+	if packages.Taps[0].URL != "git@github.com:acme/homebrew-tools.git" {
+		t.Errorf("expected tap URL, got %+v", packages.Taps[0])
+	}
+	if !reflect.DeepEqual(packages.Brews[0].Args, []string{"--HEAD"}) {
+		t.Errorf("expected formula args, got %v", packages.Brews[0].Args)
+	}
+
+	cask := packages.Casks[0]
+	if !cask.Greedy || !reflect.DeepEqual(cask.Args, []string{"--appdir=" + filepath.Join(home, "Applications")}) {
+		t.Errorf("expected greedy cask with expanded appdir, got %+v", cask)
+	}
+}
+
+// fakeBrew puts a brew on PATH that reports the brew-info-installed.json and
+// brew-services.json fixtures and the acme/tools tap, and logs every other
+// command to the returned file
+func fakeBrew(t *testing.T) string {
+	t.Helper()
+
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "brew.log")
+	fakeBinary(t, dir, "brew", fmt.Sprintf(`case "$1 $2" in
+"info "*) cat %[1]q/brew-info-installed.json ;;
+"tap ") echo acme/tools ;;
+"services list") cat %[1]q/brew-services.json ;;
+*) echo "$@" >> %[2]q ;;
+esac
+`, testdata, log))
+	return log
+}
+
+func TestHomebrewIsInstalledExistingInstall(t *testing.T) {
+	fakeBrew(t)
+
+	base := func() *config.Config {
+		cfg := &config.Config{}
+		cfg.Homebrew.Install = true
+		cfg.Homebrew.Taps = config.NewTaps([]string{"acme/tools"})
+		cfg.Homebrew.Formulae = config.NewFormulae([]string{"jq", "node@18"})
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+		want   bool
+	}{
+		{"converged", func(*config.Config) {}, true},
+		{"missing formula", func(c *config.Config) { c.Homebrew.Formulae = config.NewFormulae([]string{"jq", "wget"}) }, false},
+		{"missing tap", func(c *config.Config) { c.Homebrew.Taps = append(c.Homebrew.Taps, config.Tap{Name: "acme/other"}) }, false},
+		{"unpinned formula", func(c *config.Config) { c.Homebrew.Formulae[0].Pin = true }, false},
+		{"missing lock file", func(c *config.Config) { c.Homebrew.LockFile = filepath.Join(t.TempDir(), "brew.lock.json") }, false},
+		{"service in state", func(c *config.Config) { c.Homebrew.Services = []config.Service{{Name: "redis"}} }, true},
+		{"service to stop", func(c *config.Config) {
+			c.Homebrew.Services = []config.Service{{Name: "redis", State: config.ServiceStopped}}
+		}, false},
+		{"prune", func(c *config.Config) { c.Homebrew.Prune = true }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(cfg)
+			h := NewHomebrewInstaller(NewContext(cfg, false, false))
+			if got := h.IsInstalled(context.Background()); got != tt.want {
+				t.Errorf("IsInstalled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunInstallerExistingHomebrew(t *testing.T) {
+	log := fakeBrew(t)
+
+	cfg := &config.Config{}
+	cfg.Homebrew.Install = true
+	cfg.Homebrew.Formulae = config.NewFormulae([]string{"jq"})
+	ictx := NewContext(cfg, false, false)
+
+	if err := RunInstaller(context.Background(), NewHomebrewInstaller(ictx), ictx); err != nil {
+		t.Fatalf("RunInstaller failed: %v", err)
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("expected a converged install to run no brew commands")
+	}
+
+	// A formula added to the config is installed even though brew exists
+	cfg.Homebrew.Formulae = config.NewFormulae([]string{"jq", "wget"})
+	if err := RunInstaller(context.Background(), NewHomebrewInstaller(ictx), ictx); err != nil {
+		t.Fatalf("RunInstaller failed: %v", err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("expected brew commands to run: %v", err)
+	}
+	if !strings.Contains(string(data), "install wget") {
+		t.Errorf("expected wget to be installed, got:\n%s", data)
+	}
+}
+
+func TestRunInstallerExistingHomebrewServices(t *testing.T) {
+	log := fakeBrew(t)
+
+	cfg := &config.Config{}
+	cfg.Homebrew.Install = true
+	cfg.Homebrew.Formulae = config.NewFormulae([]string{"jq"})
+	cfg.Homebrew.Services = []config.Service{{Name: "redis", State: config.ServiceStopped}}
+	ictx := NewContext(cfg, false, false)
+
+	if err := RunInstaller(context.Background(), NewHomebrewInstaller(ictx), ictx); err != nil {
+		t.Fatalf("RunInstaller failed: %v", err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("expected brew commands to run: %v", err)
+	}
+	if !strings.Contains(string(data), "services stop redis") {
+		t.Errorf("expected redis to be stopped, got:\n%s", data)
+	}
+}
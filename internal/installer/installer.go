@@ -3,9 +3,13 @@ package installer
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/secrets"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
@@ -24,26 +28,57 @@ type Installer interface {
 	Install(ctx context.Context) error
 }
 
+// Updater is implemented by components that can be updated. An installer
+// opts in by adding an Update method and registering in DefaultUpdaters.
+type Updater interface {
+	// Name returns the updater name, also its update flag
+	Name() string
+
+	// Description returns a short description
+	Description() string
+
+	// IsInstalled checks if there is anything to update
+	IsInstalled(ctx context.Context) bool
+
+	// Update updates the component and reports what changed
+	Update(ctx context.Context) (*UpdateReport, error)
+}
+
 // Context provides shared context for installers
 type Context struct {
 	Config   *config.Config
 	Executor *executor.Executor
 	Prompt   *ui.Prompt
+	Secrets  *secrets.Manager
 	DryRun   bool
 	Verbose  bool
 }
 
 // NewContext creates a new installer context
 func NewContext(cfg *config.Config, dryRun, verbose bool) *Context {
+	exec := executor.New(dryRun, verbose)
 	return &Context{
 		Config:   cfg,
-		Executor: executor.New(dryRun, verbose),
+		Executor: exec,
 		Prompt:   ui.NewPrompt(cfg.Settings.Interactive),
+		Secrets:  secrets.NewManager(exec),
 		DryRun:   dryRun,
 		Verbose:  verbose,
 	}
 }
 
+// expandHome expands a leading ~/ to the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		return filepath.Join(homeDir, path[2:])
+	}
+	return path
+}
+
 // Registry holds all available installers
 type Registry struct {
 	installers map[string]func(*Context) Installer
@@ -96,6 +131,23 @@ func init() {
 	DefaultRegistry.Register("homebrew", func(ctx *Context) Installer {
 		return NewHomebrewInstaller(ctx)
 	})
+	DefaultRegistry.Register("mas", func(ctx *Context) Installer {
+		return NewMasInstaller(ctx)
+	})
+	DefaultRegistry.Register("runtimes", func(ctx *Context) Installer {
+		return NewRuntimesInstaller(ctx)
+	})
+	for _, name := range PackageEcosystems {
+		DefaultRegistry.Register(name, func(ctx *Context) Installer {
+			return NewPackagesInstaller(ctx, name)
+		})
+	}
+	DefaultRegistry.Register("vscode", func(ctx *Context) Installer {
+		return NewVSCodeInstaller(ctx)
+	})
+	DefaultRegistry.Register("downloads", func(ctx *Context) Installer {
+		return NewDownloadsInstaller(ctx)
+	})
 	DefaultRegistry.Register("ohmyzsh", func(ctx *Context) Installer {
 		return NewOhMyZshInstaller(ctx)
 	})
@@ -116,6 +168,77 @@ func init() {
 	})
 }
 
+// UpdaterRegistry holds the updaters in the order update runs them
+type UpdaterRegistry struct {
+	names    []string
+	updaters map[string]func(*Context) Updater
+}
+
+// NewUpdaterRegistry creates a new updater registry
+func NewUpdaterRegistry() *UpdaterRegistry {
+	return &UpdaterRegistry{
+		updaters: make(map[string]func(*Context) Updater),
+	}
+}
+
+// Register adds an updater factory to the registry
+func (r *UpdaterRegistry) Register(name string, factory func(*Context) Updater) {
+	if _, ok := r.updaters[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.updaters[name] = factory
+}
+
+// Get returns an updater by name
+func (r *UpdaterRegistry) Get(name string, ctx *Context) (Updater, error) {
+	factory, ok := r.updaters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown updater: %s", name)
+	}
+	return factory(ctx), nil
+}
+
+// GetAll returns all registered updaters in registration order
+func (r *UpdaterRegistry) GetAll(ctx *Context) []Updater {
+	var updaters []Updater
+	for _, name := range r.names {
+		updaters = append(updaters, r.updaters[name](ctx))
+	}
+	return updaters
+}
+
+// Names returns all registered updater names in registration order
+func (r *UpdaterRegistry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// DefaultUpdaters is the global updater registry
+var DefaultUpdaters = NewUpdaterRegistry()
+
+func init() {
+	// Homebrew first: brew update refreshes what the others install from
+	DefaultUpdaters.Register("homebrew", func(ctx *Context) Updater {
+		return NewHomebrewUpdater(ctx)
+	})
+	DefaultUpdaters.Register("mas", func(ctx *Context) Updater {
+		return NewMasUpdater(ctx)
+	})
+	for _, name := range PackageEcosystems {
+		DefaultUpdaters.Register(name, func(ctx *Context) Updater {
+			return NewPackagesUpdater(ctx, name)
+		})
+	}
+	DefaultUpdaters.Register("ohmyzsh", func(ctx *Context) Updater {
+		return NewOhMyZshUpdater(ctx)
+	})
+	DefaultUpdaters.Register("plugins", func(ctx *Context) Updater {
+		return NewOhMyZshPluginsUpdater(ctx)
+	})
+	DefaultUpdaters.Register("powerlevel10k", func(ctx *Context) Updater {
+		return NewPowerlevel10kInstaller(ctx)
+	})
+}
+
 // RunInstaller runs a single installer
 func RunInstaller(ctx context.Context, installer Installer, ictx *Context) error {
 	return RunInstallerWithProgress(ctx, installer, ictx, 0, 0)
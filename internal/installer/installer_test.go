@@ -0,0 +1,27 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpdaterRegistry(t *testing.T) {
+	registry := NewUpdaterRegistry()
+	factory := func(ctx *Context) Updater { return NewMasUpdater(ctx) }
+	registry.Register("homebrew", factory)
+	registry.Register("mas", factory)
+	registry.Register("ohmyzsh", factory)
+	registry.Register("mas", factory)
+
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"homebrew", "mas", "ohmyzsh"}) {
+		t.Errorf("expected registration order without duplicates, got %v", names)
+	}
+	if _, err := registry.Get("unknown", nil); err == nil {
+		t.Error("expected error for an unknown updater")
+	}
+
+	want := []string{"homebrew", "mas", "npm", "pipx", "cargo", "go", "ohmyzsh", "plugins", "powerlevel10k"}
+	if names := DefaultUpdaters.Names(); !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected default updaters: %v", names)
+	}
+}
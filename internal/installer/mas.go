@@ -0,0 +1,246 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/brewfile"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// MasInstaller installs Mac App Store apps with mas
+type MasInstaller struct {
+	ctx *Context
+}
+
+// NewMasInstaller creates a new Mac App Store installer
+func NewMasInstaller(ctx *Context) *MasInstaller {
+	return &MasInstaller{ctx: ctx}
+}
+
+// Name returns the installer name
+func (m *MasInstaller) Name() string {
+	return "mas"
+}
+
+// Description returns the installer description
+func (m *MasInstaller) Description() string {
+	return "Mac App Store Apps"
+}
+
+// IsInstalled checks if all configured apps are installed
+func (m *MasInstaller) IsInstalled(ctx context.Context) bool {
+	apps := m.apps()
+	if len(apps) == 0 {
+		return true
+	}
+	if !m.ctx.Executor.Exists("mas") {
+		return false
+	}
+
+	installed := m.installed(ctx)
+	for _, app := range apps {
+		if !installed[app.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// Install installs mas if needed and then the missing apps
+func (m *MasInstaller) Install(ctx context.Context) error {
+	apps := m.apps()
+	if len(apps) == 0 {
+		ui.PrintInfo("No App Store apps configured")
+		return nil
+	}
+
+	if !m.ctx.Executor.Exists("mas") {
+		if !m.ctx.Executor.Exists("brew") && !m.ctx.DryRun {
+			return fmt.Errorf("homebrew is required to install mas")
+		}
+
+		spinner := ui.NewSpinner("Installing mas...")
+		spinner.Start()
+		result, err := m.ctx.Executor.Run(ctx, "brew", "install", "mas")
+		if err != nil {
+			spinner.Fail("Failed to install mas")
+			return fmt.Errorf("brew install mas failed: %w\n%s", err, result.Stderr)
+		}
+		if result.DryRun {
+			spinner.Info("[DRY-RUN] Would install mas")
+		} else {
+			spinner.Success("Installed mas")
+		}
+	}
+
+	installed := m.installed(ctx)
+	for _, app := range apps {
+		if installed[app.ID] {
+			ui.PrintInfo(fmt.Sprintf("App already installed: %s", app.Name))
+			continue
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Installing app: %s", app.Name))
+		spinner.Start()
+
+		result, err := m.ctx.Executor.Run(ctx, "mas", "install", strconv.Itoa(app.ID))
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to install app: %s (signed in to the App Store?)", app.Name))
+			continue
+		}
+
+		if result.DryRun {
+			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install app: %s", app.Name))
+		} else {
+			spinner.Success(fmt.Sprintf("Installed app: %s", app.Name))
+		}
+	}
+
+	return nil
+}
+
+// apps returns homebrew.mas_apps followed by the Brewfile mas entries
+func (m *MasInstaller) apps() []brewfile.MasApp {
+	packages, err := BrewPackages(m.ctx.Config.Homebrew)
+	if err != nil {
+		ui.PrintWarning(err.Error())
+		var apps []brewfile.MasApp
+		for _, app := range m.ctx.Config.Homebrew.MasApps {
+			apps = append(apps, brewfile.MasApp{Name: app.Name, ID: app.ID})
+		}
+		return apps
+	}
+	return packages.Mas
+}
+
+// installed returns the IDs reported by mas list
+func (m *MasInstaller) installed(ctx context.Context) map[int]bool {
+	if !m.ctx.Executor.Exists("mas") {
+		return map[int]bool{}
+	}
+
+	result, err := m.ctx.Executor.Query(ctx, "mas", "list")
+	if err != nil {
+		return map[int]bool{}
+	}
+	return parseMasList(result.Stdout)
+}
+
+// parseMasList reads the app IDs from mas list output:
+//
+//	497799835  Xcode  (15.4)
+func parseMasList(output string) map[int]bool {
+	ids := make(map[int]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if id, err := strconv.Atoi(fields[0]); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// MasUpdater upgrades Mac App Store apps
+type MasUpdater struct {
+	ctx *Context
+}
+
+// NewMasUpdater creates a new Mac App Store updater
+func NewMasUpdater(ctx *Context) *MasUpdater {
+	return &MasUpdater{ctx: ctx}
+}
+
+// Name returns the updater name
+func (m *MasUpdater) Name() string {
+	return "mas"
+}
+
+// Description returns the updater description
+func (m *MasUpdater) Description() string {
+	return "Mac App Store Apps"
+}
+
+// IsInstalled checks if mas is installed
+func (m *MasUpdater) IsInstalled(ctx context.Context) bool {
+	return m.ctx.Executor.Exists("mas")
+}
+
+// Update upgrades all outdated App Store apps
+func (m *MasUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	if !m.ctx.Executor.Exists("mas") {
+		return report, fmt.Errorf("mas is not installed")
+	}
+
+	outdated := m.outdated(ctx)
+
+	ui.PrintStep("Upgrading App Store apps...")
+	if m.ctx.DryRun {
+		ui.PrintDryRun("mas upgrade")
+		report.Upgraded = outdated
+		return report, nil
+	}
+
+	spinner := ui.NewSpinner("Running mas upgrade...")
+	spinner.Start()
+	result, err := m.ctx.Executor.Run(ctx, "mas", "upgrade")
+	if err != nil {
+		spinner.Fail("Failed to upgrade App Store apps")
+		return report, fmt.Errorf("mas upgrade failed: %w\n%s", err, result.Stderr)
+	}
+	spinner.Success("App Store apps upgraded")
+
+	remaining := make(map[string]bool)
+	for _, app := range m.outdated(ctx) {
+		remaining[app.Name] = true
+	}
+	for _, app := range outdated {
+		if remaining[app.Name] {
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: app.Name, Kind: app.Kind, Reason: SkipFailed})
+		} else {
+			report.Upgraded = append(report.Upgraded, app)
+		}
+	}
+
+	return report, nil
+}
+
+// outdated returns the apps reported by mas outdated
+func (m *MasUpdater) outdated(ctx context.Context) []PackageChange {
+	result, err := m.ctx.Executor.Query(ctx, "mas", "outdated")
+	if err != nil {
+		return nil
+	}
+	return parseMasOutdated(result.Stdout)
+}
+
+// parseMasOutdated reads mas outdated output:
+//
+//	497799835  Xcode  (15.3 -> 15.4)
+func parseMasOutdated(output string) []PackageChange {
+	var apps []PackageChange
+	for _, line := range strings.Split(output, "\n") {
+		open, close := strings.LastIndex(line, "("), strings.LastIndex(line, ")")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || open < 0 || close < open {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+
+		from, to, ok := strings.Cut(line[open+1:close], " -> ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(line[:open], fields[0]))
+		apps = append(apps, PackageChange{Name: name, Kind: "app", From: from, To: to})
+	}
+	return apps
+}
@@ -0,0 +1,68 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestParseMasList(t *testing.T) {
+	output := `497799835   Xcode      (15.4)
+803453959   Slack      (4.39.95)
+not-an-id   garbage
+`
+	ids := parseMasList(output)
+	if len(ids) != 2 || !ids[497799835] || !ids[803453959] {
+		t.Errorf("unexpected ids %v", ids)
+	}
+}
+
+func TestMasInstallerInstallsMissingApps(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	fakeBinary(t, dir, "mas", `
+echo "$*" >> "`+calls+`"
+if [ "$1" = "list" ]; then
+  echo "497799835  Xcode  (15.4)"
+fi
+`)
+
+	cfg := &config.Config{}
+	cfg.Homebrew.MasApps = []config.MasApp{
+		{ID: 497799835, Name: "Xcode"},
+		{ID: 441258766, Name: "Magnet"},
+	}
+	m := NewMasInstaller(NewContext(cfg, false, false))
+
+	if m.IsInstalled(context.Background()) {
+		t.Error("expected Magnet to be reported missing")
+	}
+	if err := m.Install(context.Background()); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	if got := strings.TrimSpace(string(log)); got != "list\nlist\ninstall 441258766" {
+		t.Errorf("unexpected mas calls:\n%s", got)
+	}
+}
+
+func TestParseMasOutdated(t *testing.T) {
+	output := `497799835  Xcode                 (15.3 -> 15.4)
+441258766  Magnet Window Manager (2.14.0 -> 2.15.0)
+Warning: not signed in
+`
+	got := parseMasOutdated(output)
+	want := []PackageChange{
+		{Name: "Xcode", Kind: "app", From: "15.3", To: "15.4"},
+		{Name: "Magnet Window Manager", Kind: "app", From: "2.14.0", To: "2.15.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -11,7 +11,6 @@ import (
 )
 
 const (
-	ohMyZshInstallScript      = "https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh"
 	zshAutosuggestionsRepo    = "https://github.com/zsh-users/zsh-autosuggestions"
 	zshSyntaxHighlightingRepo = "https://github.com/zsh-users/zsh-syntax-highlighting"
 )
@@ -89,14 +88,20 @@ func (o *OhMyZshInstaller) Install(ctx context.Context) error {
 }
 
 func (o *OhMyZshInstaller) installOhMyZsh(ctx context.Context) error {
-	cmd := fmt.Sprintf(`sh -c "$(curl -fsSL %s)" "" --unattended`, ohMyZshInstallScript)
-
+	script := o.ctx.Config.Terminal.OhMyZsh.InstallScript
 	if o.ctx.DryRun {
-		ui.PrintDryRun(cmd)
+		ui.PrintDryRun(fmt.Sprintf("Oh My Zsh install script: %s", describeScript("ohmyzsh-install", script)))
+		ui.PrintDryRun("sh ohmyzsh-install.sh --unattended")
 		return nil
 	}
 
-	_, err := o.ctx.Executor.RunShell(ctx, cmd)
+	path, err := prepareScript(ctx, o.ctx, "ohmyzsh-install", script)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	_, err = o.ctx.Executor.Run(ctx, "sh", path, "--unattended")
 	return err
 }
 
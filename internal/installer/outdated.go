@@ -0,0 +1,105 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// OutdatedItem is a component with a newer version available
+type OutdatedItem struct {
+	Name string `json:"name"`
+	// Kind is formula, cask, oh-my-zsh, plugin, theme or setup-mac
+	Kind    string `json:"kind"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	Pinned  bool   `json:"pinned,omitempty"`
+}
+
+// OutdatedBrew lists the outdated formulae and casks, pinned ones included
+func OutdatedBrew(ctx context.Context, exec *executor.Executor) ([]OutdatedItem, error) {
+	outdated, err := LoadBrewOutdated(ctx, exec, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []OutdatedItem
+	for _, p := range append(outdated.Formulae, outdated.Casks...) {
+		items = append(items, OutdatedItem{
+			Name:    p.Name,
+			Kind:    packageKind(p),
+			Current: p.Installed(),
+			Latest:  p.CurrentVersion,
+			Pinned:  p.Pinned,
+		})
+	}
+	return items, nil
+}
+
+// OutdatedOhMyZsh checks Oh My Zsh and the git checkouts in its custom
+// plugins and themes directories against their remotes. Checkouts in pins
+// are marked pinned.
+func OutdatedOhMyZsh(ctx context.Context, exec *executor.Executor, omzDir string, pins map[string]string) ([]OutdatedItem, []error) {
+	var items []OutdatedItem
+	var errs []error
+
+	for _, repo := range ohMyZshRepos(omzDir, pins) {
+		item, err := CheckGitRepo(ctx, exec, repo.Name, repo.Kind, repo.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", repo.Kind, repo.Name, err))
+		} else if item != nil {
+			item.Pinned = repo.Ref != ""
+			items = append(items, *item)
+		}
+	}
+	return items, errs
+}
+
+// CheckGitRepo compares HEAD with the remote branch it tracks, read with
+// git ls-remote. It returns nil if HEAD is up to date or ahead.
+func CheckGitRepo(ctx context.Context, exec *executor.Executor, name, kind, dir string) (*OutdatedItem, error) {
+	local := gitOutput(ctx, exec, dir, "rev-parse", "HEAD")
+	if local == "" {
+		return nil, fmt.Errorf("not a git repository: %s", dir)
+	}
+
+	// A detached checkout is compared with the remote's default branch
+	remote, ref := "origin", "HEAD"
+	if branch := gitOutput(ctx, exec, dir, "symbolic-ref", "--short", "-q", "HEAD"); branch != "" {
+		ref = "refs/heads/" + branch
+		if r := gitOutput(ctx, exec, dir, "config", "branch."+branch+".remote"); r != "" {
+			remote = r
+		}
+	}
+
+	result, err := exec.Query(ctx, "git", "-C", dir, "ls-remote", remote, ref)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s not found on %s", ref, remote)
+	}
+	latest := fields[0]
+
+	if latest == local {
+		return nil, nil
+	}
+	// Local commits on top of the remote one
+	if _, err := exec.Query(ctx, "git", "-C", dir, "merge-base", "--is-ancestor", latest, local); err == nil {
+		return nil, nil
+	}
+
+	return &OutdatedItem{Name: name, Kind: kind, Current: shortSHA(local), Latest: shortSHA(latest)}, nil
+}
+
+// gitOutput runs a read-only git command in dir, returning "" on failure
+func gitOutput(ctx context.Context, exec *executor.Executor, dir string, args ...string) string {
+	result, err := exec.Query(ctx, "git", append([]string{"-C", dir}, args...)...)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(result.Stdout)
+}
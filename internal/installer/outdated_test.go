@@ -0,0 +1,134 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// git runs a git command for test setup
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_SYSTEM=/dev/null",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitUpstream creates a bare repository with one commit and a clone of it.
+// commit adds another commit to the upstream.
+func gitUpstream(t *testing.T) (upstream, clone string, commit func()) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	root := t.TempDir()
+	upstream = filepath.Join(root, "upstream.git")
+	work := filepath.Join(root, "work")
+	clone = filepath.Join(root, "clone")
+
+	git(t, root, "init", "--bare", "-b", "main", upstream)
+	git(t, root, "clone", upstream, work)
+	n := 0
+	commit = func() {
+		n++
+		git(t, work, "commit", "--allow-empty", "-m", "commit "+strings.Repeat("+", n))
+		git(t, work, "push", "origin", "HEAD:main")
+	}
+	commit()
+	git(t, root, "clone", upstream, clone)
+	return upstream, clone, commit
+}
+
+func TestCheckGitRepo(t *testing.T) {
+	_, clone, commit := gitUpstream(t)
+	runner := executor.New(false, false)
+	ctx := context.Background()
+
+	item, err := CheckGitRepo(ctx, runner, "plugin", "plugin", clone)
+	if err != nil || item != nil {
+		t.Fatalf("expected up to date, got %+v, %v", item, err)
+	}
+
+	commit()
+	item, err = CheckGitRepo(ctx, runner, "plugin", "plugin", clone)
+	if err != nil || item == nil {
+		t.Fatalf("expected outdated, got %+v, %v", item, err)
+	}
+	if len(item.Current) != 7 || len(item.Latest) != 7 || item.Current == item.Latest {
+		t.Errorf("unexpected versions: %+v", item)
+	}
+
+	// Local commits ahead of the remote are not outdated
+	git(t, clone, "pull", "--quiet")
+	git(t, clone, "commit", "--allow-empty", "-m", "local")
+	if item, err := CheckGitRepo(ctx, runner, "plugin", "plugin", clone); err != nil || item != nil {
+		t.Errorf("expected ahead to count as up to date, got %+v, %v", item, err)
+	}
+}
+
+func TestOutdatedOhMyZsh(t *testing.T) {
+	_, omz, _ := gitUpstream(t)
+	_, plugin, commit := gitUpstream(t)
+
+	pluginDir := filepath.Join(omz, "custom", "plugins", "zsh-autosuggestions")
+	if err := os.MkdirAll(filepath.Dir(pluginDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(plugin, pluginDir); err != nil {
+		t.Fatal(err)
+	}
+	// Not a git checkout, ignored
+	if err := os.MkdirAll(filepath.Join(omz, "custom", "themes", "local"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+
+	items, errs := OutdatedOhMyZsh(context.Background(), executor.New(false, false), omz, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(items) != 1 || items[0].Name != "zsh-autosuggestions" || items[0].Kind != "plugin" || items[0].Pinned {
+		t.Errorf("expected the plugin to be outdated, got %+v", items)
+	}
+
+	pins := map[string]string{"zsh-autosuggestions": "v0.7.0"}
+	items, _ = OutdatedOhMyZsh(context.Background(), executor.New(false, false), omz, pins)
+	if len(items) != 1 || !items[0].Pinned {
+		t.Errorf("expected the plugin to be marked pinned, got %+v", items)
+	}
+}
+
+func TestOutdatedBrew(t *testing.T) {
+	dir := t.TempDir()
+	outdated, _ := filepath.Abs(filepath.Join("testdata", "brew-outdated.json"))
+	fakeBinary(t, dir, "brew", `cat "`+outdated+`"`)
+
+	items, err := OutdatedBrew(context.Background(), executor.New(false, false))
+	if err != nil {
+		t.Fatalf("OutdatedBrew failed: %v", err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("expected 5 items, got %+v", items)
+	}
+	if node := items[2]; node.Name != "node" || !node.Pinned || node.Current != "21.7.1" || node.Latest != "22.2.0" {
+		t.Errorf("unexpected node entry: %+v", node)
+	}
+	if docker := items[4]; docker.Kind != "cask" {
+		t.Errorf("unexpected docker entry: %+v", docker)
+	}
+}
@@ -0,0 +1,519 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// PackageEcosystems lists the supported ecosystems in the order they run
+var PackageEcosystems = []string{config.PackagesNpm, config.PackagesPipx, config.PackagesCargo, config.PackagesGo}
+
+// ecosystem describes how one package manager lists and installs global
+// packages
+type ecosystem struct {
+	description string
+	binary      string
+	// list returns the installed version of each package by name
+	list func(ctx context.Context, exec *executor.Executor, specs []PackageSpec) (map[string]string, error)
+	// install returns the arguments that install the spec, replacing an
+	// installed version when reinstall is set
+	install func(spec PackageSpec, reinstall bool) []string
+	// upgrade returns the arguments that upgrade an unpinned package
+	upgrade func(spec PackageSpec) []string
+}
+
+var ecosystems = map[string]ecosystem{
+	config.PackagesNpm: {
+		description: "npm Global Packages",
+		binary:      "npm",
+		list:        listNpm,
+		install: func(spec PackageSpec, _ bool) []string {
+			return []string{"install", "-g", spec.String()}
+		},
+		upgrade: func(spec PackageSpec) []string {
+			return []string{"install", "-g", spec.Name + "@latest"}
+		},
+	},
+	config.PackagesPipx: {
+		description: "pipx Packages",
+		binary:      "pipx",
+		list:        listPipx,
+		install: func(spec PackageSpec, reinstall bool) []string {
+			args := []string{"install"}
+			if reinstall {
+				args = append(args, "--force")
+			}
+			if spec.Pinned() {
+				return append(args, spec.Name+"=="+spec.Version)
+			}
+			return append(args, spec.Name)
+		},
+		upgrade: func(spec PackageSpec) []string {
+			return []string{"upgrade", spec.Name}
+		},
+	},
+	config.PackagesCargo: {
+		description: "Cargo Crates",
+		binary:      "cargo",
+		list:        listCargo,
+		install: func(spec PackageSpec, _ bool) []string {
+			if spec.Pinned() {
+				return []string{"install", spec.Name, "--version", spec.Version}
+			}
+			return []string{"install", spec.Name}
+		},
+		// cargo install only rebuilds when a newer version exists
+		upgrade: func(spec PackageSpec) []string {
+			return []string{"install", spec.Name}
+		},
+	},
+	config.PackagesGo: {
+		description: "Go Tools",
+		binary:      "go",
+		list:        listGo,
+		install: func(spec PackageSpec, _ bool) []string {
+			if spec.Pinned() {
+				return []string{"install", spec.String()}
+			}
+			return []string{"install", spec.Name + "@latest"}
+		},
+		upgrade: func(spec PackageSpec) []string {
+			return []string{"install", spec.Name + "@latest"}
+		},
+	},
+}
+
+// PackageSpec is a declared global package with an optional pinned version
+type PackageSpec struct {
+	Name    string
+	Version string
+}
+
+// ParsePackageSpec splits name@version. The @ of a scoped npm package such
+// as @angular/cli is part of the name.
+func ParsePackageSpec(s string) PackageSpec {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "@"); i > 0 {
+		return PackageSpec{Name: s[:i], Version: s[i+1:]}
+	}
+	return PackageSpec{Name: s}
+}
+
+// Pinned reports whether the spec asks for a specific version
+func (s PackageSpec) Pinned() bool {
+	return s.Version != "" && s.Version != "latest"
+}
+
+func (s PackageSpec) String() string {
+	if s.Version == "" {
+		return s.Name
+	}
+	return s.Name + "@" + s.Version
+}
+
+// PackageState compares a declared package with the installed one
+type PackageState struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	// Wanted is the pinned version, empty when any version will do
+	Wanted    string `json:"wanted,omitempty"`
+	Installed string `json:"installed,omitempty"`
+}
+
+// Missing reports a package that is not installed
+func (s PackageState) Missing() bool {
+	return s.Installed == ""
+}
+
+// Mismatched reports a package installed at another version than its pin
+func (s PackageState) Mismatched() bool {
+	return !s.Missing() && s.Wanted != "" && !packageVersionMatches(s.Wanted, s.Installed)
+}
+
+// packageVersionMatches compares versions with or without a leading v, so
+// ripgrep@14 matches cargo's v14.1.0
+func packageVersionMatches(wanted, installed string) bool {
+	return versionInstalled(strings.TrimPrefix(wanted, "v"), []string{strings.TrimPrefix(installed, "v")})
+}
+
+// PackageSpecs returns the parsed packages of an ecosystem
+func PackageSpecs(cfg config.PackagesConfig, name string) []PackageSpec {
+	var specs []PackageSpec
+	for _, entry := range cfg[name] {
+		spec := ParsePackageSpec(entry)
+		if spec.Name == "" {
+			continue
+		}
+		if name == config.PackagesGo {
+			spec.Version = goModuleVersion(spec.Version)
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// goModuleVersion adds the v go expects to a pinned version such as 0.15.2.
+// Branches, commits and latest are kept as they are.
+func goModuleVersion(version string) string {
+	if version != "" && version[0] >= '0' && version[0] <= '9' {
+		return "v" + version
+	}
+	return version
+}
+
+// PackageStates reports each declared package of an ecosystem. Without the
+// package manager nothing counts as installed.
+func PackageStates(ctx context.Context, exec *executor.Executor, cfg config.PackagesConfig, name string) ([]PackageState, error) {
+	eco, ok := ecosystems[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown package ecosystem: %s", name)
+	}
+
+	specs := PackageSpecs(cfg, name)
+	installed := map[string]string{}
+	if len(specs) > 0 && exec.Exists(eco.binary) {
+		var err error
+		if installed, err = eco.list(ctx, exec, specs); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]PackageState, 0, len(specs))
+	for _, spec := range specs {
+		state := PackageState{Ecosystem: name, Name: spec.Name, Installed: installed[spec.Name]}
+		if spec.Pinned() {
+			state.Wanted = spec.Version
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// PackagesInstaller installs the global packages of one ecosystem
+type PackagesInstaller struct {
+	ctx       *Context
+	ecosystem string
+}
+
+// NewPackagesInstaller creates an installer for the npm, pipx, cargo or go
+// packages
+func NewPackagesInstaller(ctx *Context, ecosystem string) *PackagesInstaller {
+	return &PackagesInstaller{ctx: ctx, ecosystem: ecosystem}
+}
+
+// Name returns the installer name
+func (p *PackagesInstaller) Name() string {
+	return p.ecosystem
+}
+
+// Description returns the installer description
+func (p *PackagesInstaller) Description() string {
+	return ecosystems[p.ecosystem].description
+}
+
+// IsInstalled checks if every declared package is installed at its pin
+func (p *PackagesInstaller) IsInstalled(ctx context.Context) bool {
+	states, err := PackageStates(ctx, p.ctx.Executor, p.ctx.Config.Packages, p.ecosystem)
+	if err != nil {
+		return false
+	}
+	for _, state := range states {
+		if state.Missing() || state.Mismatched() {
+			return false
+		}
+	}
+	return true
+}
+
+// Install installs the missing packages and reinstalls the ones that do
+// not match their pin
+func (p *PackagesInstaller) Install(ctx context.Context) error {
+	eco := ecosystems[p.ecosystem]
+	specs := PackageSpecs(p.ctx.Config.Packages, p.ecosystem)
+	if len(specs) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No %s packages configured", p.ecosystem))
+		return nil
+	}
+	if !p.ctx.Executor.Exists(eco.binary) && !p.ctx.DryRun {
+		return fmt.Errorf("%s is not installed, add it to homebrew.formulae or runtimes.tools", eco.binary)
+	}
+
+	states, err := PackageStates(ctx, p.ctx.Executor, p.ctx.Config.Packages, p.ecosystem)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, state := range states {
+		spec := specs[i]
+		if !state.Missing() && !state.Mismatched() {
+			ui.PrintInfo(fmt.Sprintf("Package already installed: %s %s", state.Name, state.Installed))
+			continue
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Installing %s...", spec))
+		spinner.Start()
+		args := eco.install(spec, state.Mismatched())
+		result, err := p.ctx.Executor.Run(ctx, eco.binary, args...)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to install %s", spec))
+			if p.ctx.Verbose {
+				ui.PrintError(strings.TrimSpace(result.Stderr))
+			}
+			failed++
+			continue
+		}
+		if result.DryRun {
+			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install %s", spec))
+		} else {
+			spinner.Success(fmt.Sprintf("Installed %s", spec))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d %s package(s) failed to install", failed, p.ecosystem)
+	}
+	return nil
+}
+
+// PackagesUpdater upgrades the unpinned global packages of one ecosystem
+type PackagesUpdater struct {
+	ctx       *Context
+	ecosystem string
+}
+
+// NewPackagesUpdater creates an updater for the npm, pipx, cargo or go
+// packages
+func NewPackagesUpdater(ctx *Context, ecosystem string) *PackagesUpdater {
+	return &PackagesUpdater{ctx: ctx, ecosystem: ecosystem}
+}
+
+// Name returns the updater name
+func (p *PackagesUpdater) Name() string {
+	return p.ecosystem
+}
+
+// Description returns the updater description
+func (p *PackagesUpdater) Description() string {
+	return ecosystems[p.ecosystem].description
+}
+
+// IsInstalled checks if the package manager is installed and packages are
+// declared for it
+func (p *PackagesUpdater) IsInstalled(ctx context.Context) bool {
+	return len(PackageSpecs(p.ctx.Config.Packages, p.ecosystem)) > 0 && p.ctx.Executor.Exists(ecosystems[p.ecosystem].binary)
+}
+
+// Update upgrades the installed, unpinned packages. Pinned packages are
+// skipped and missing ones are left to install.
+func (p *PackagesUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	eco := ecosystems[p.ecosystem]
+	if !p.ctx.Executor.Exists(eco.binary) {
+		return report, fmt.Errorf("%s is not installed", eco.binary)
+	}
+
+	specs := PackageSpecs(p.ctx.Config.Packages, p.ecosystem)
+	before, err := eco.list(ctx, p.ctx.Executor, specs)
+	if err != nil {
+		return report, err
+	}
+
+	ui.PrintStep(fmt.Sprintf("Upgrading %s packages...", p.ecosystem))
+	var upgraded []PackageSpec
+	for _, spec := range specs {
+		if before[spec.Name] == "" {
+			continue
+		}
+		if spec.Pinned() {
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: spec.Name, Kind: p.ecosystem, Reason: SkipPinned})
+			continue
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Upgrading %s...", spec.Name))
+		spinner.Start()
+		result, err := p.ctx.Executor.Run(ctx, eco.binary, eco.upgrade(spec)...)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to upgrade %s", spec.Name))
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: spec.Name, Kind: p.ecosystem, Reason: SkipFailed})
+			continue
+		}
+		if result.DryRun {
+			spinner.Info(fmt.Sprintf("[DRY-RUN] Would upgrade %s", spec.Name))
+			continue
+		}
+		spinner.Stop()
+		upgraded = append(upgraded, spec)
+	}
+	if len(upgraded) == 0 {
+		return report, nil
+	}
+
+	after, err := eco.list(ctx, p.ctx.Executor, upgraded)
+	if err != nil {
+		return report, err
+	}
+	for _, spec := range upgraded {
+		from, to := before[spec.Name], after[spec.Name]
+		if to == "" || to == from {
+			ui.PrintInfo(fmt.Sprintf("%s %s is up to date", spec.Name, from))
+			continue
+		}
+		ui.PrintSuccess(fmt.Sprintf("Upgraded %s %s -> %s", spec.Name, from, to))
+		report.Upgraded = append(report.Upgraded, PackageChange{Name: spec.Name, Kind: p.ecosystem, From: from, To: to})
+	}
+	return report, nil
+}
+
+// listNpm reads npm ls -g --json. npm exits non-zero for extraneous or
+// invalid packages but still prints the tree.
+func listNpm(ctx context.Context, exec *executor.Executor, _ []PackageSpec) (map[string]string, error) {
+	result, err := exec.Query(ctx, "npm", "ls", "-g", "--depth=0", "--json")
+	if err != nil && strings.TrimSpace(result.Stdout) == "" {
+		return nil, fmt.Errorf("npm ls failed: %w", err)
+	}
+	return parseNpmList([]byte(result.Stdout))
+}
+
+func parseNpmList(data []byte) (map[string]string, error) {
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse npm ls output: %w", err)
+	}
+	installed := make(map[string]string)
+	for name, dep := range tree.Dependencies {
+		installed[name] = dep.Version
+	}
+	return installed, nil
+}
+
+func listPipx(ctx context.Context, exec *executor.Executor, _ []PackageSpec) (map[string]string, error) {
+	result, err := exec.Query(ctx, "pipx", "list", "--json")
+	if err != nil {
+		return nil, fmt.Errorf("pipx list failed: %w", err)
+	}
+	return parsePipxList([]byte(result.Stdout))
+}
+
+// parsePipxList reads pipx list --json, keyed by the main package of each
+// virtual environment
+func parsePipxList(data []byte) (map[string]string, error) {
+	var list struct {
+		Venvs map[string]struct {
+			Metadata struct {
+				MainPackage struct {
+					Package        string `json:"package"`
+					PackageVersion string `json:"package_version"`
+				} `json:"main_package"`
+			} `json:"metadata"`
+		} `json:"venvs"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pipx list output: %w", err)
+	}
+	installed := make(map[string]string)
+	for venv, entry := range list.Venvs {
+		name := entry.Metadata.MainPackage.Package
+		if name == "" {
+			name = venv
+		}
+		installed[name] = entry.Metadata.MainPackage.PackageVersion
+	}
+	return installed, nil
+}
+
+func listCargo(ctx context.Context, exec *executor.Executor, _ []PackageSpec) (map[string]string, error) {
+	result, err := exec.Query(ctx, "cargo", "install", "--list")
+	if err != nil {
+		return nil, fmt.Errorf("cargo install --list failed: %w", err)
+	}
+	return parseCargoList(result.Stdout), nil
+}
+
+var cargoListLine = regexp.MustCompile(`^(\S+) v(\S+?)(?: \(.*\))?:$`)
+
+// parseCargoList reads cargo install --list output, where each crate is
+// followed by its indented binaries:
+//
+//	ripgrep v14.1.0:
+//	    rg
+func parseCargoList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if m := cargoListLine.FindStringSubmatch(line); m != nil {
+			installed[m[1]] = m[2]
+		}
+	}
+	return installed
+}
+
+// listGo reads the module version of each declared tool's binary with go
+// version -m, as go keeps no list of installed tools
+func listGo(ctx context.Context, exec *executor.Executor, specs []PackageSpec) (map[string]string, error) {
+	result, err := exec.Query(ctx, "go", "env", "GOBIN", "GOPATH")
+	if err != nil {
+		return nil, fmt.Errorf("go env failed: %w", err)
+	}
+	lines := strings.Split(result.Stdout, "\n")
+	dir := strings.TrimSpace(lines[0])
+	if dir == "" && len(lines) > 1 {
+		gopath, _, _ := strings.Cut(strings.TrimSpace(lines[1]), ":")
+		dir = filepath.Join(gopath, "bin")
+	}
+
+	installed := make(map[string]string)
+	for _, spec := range specs {
+		result, err := exec.Query(ctx, "go", "version", "-m", filepath.Join(dir, goBinaryName(spec.Name)))
+		if err != nil {
+			continue
+		}
+		if pkg, version := parseGoVersion(result.Stdout); pkg == spec.Name {
+			installed[spec.Name] = version
+		}
+	}
+	return installed, nil
+}
+
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// goBinaryName returns the binary go install builds for a package path,
+// ignoring a major version suffix such as /v2
+func goBinaryName(pkg string) string {
+	name := path.Base(pkg)
+	if majorVersionSuffix.MatchString(name) {
+		name = path.Base(path.Dir(pkg))
+	}
+	return name
+}
+
+// parseGoVersion reads the package path and module version from go
+// version -m output:
+//
+//	/Users/dev/go/bin/gopls: go1.22.1
+//		path	golang.org/x/tools/gopls
+//		mod	golang.org/x/tools/gopls	v0.15.2	h1:...
+func parseGoVersion(output string) (pkg, version string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "path":
+			pkg = fields[1]
+		case len(fields) >= 3 && fields[0] == "mod":
+			version = fields[2]
+		}
+	}
+	return pkg, version
+}
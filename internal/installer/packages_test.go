@@ -0,0 +1,235 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+func TestParsePackageSpec(t *testing.T) {
+	for input, want := range map[string]PackageSpec{
+		"typescript":                       {Name: "typescript"},
+		"typescript@5.4.2":                 {Name: "typescript", Version: "5.4.2"},
+		"@angular/cli":                     {Name: "@angular/cli"},
+		"@angular/cli@17.3.0":              {Name: "@angular/cli", Version: "17.3.0"},
+		"golang.org/x/tools/gopls@v0.15.2": {Name: "golang.org/x/tools/gopls", Version: "v0.15.2"},
+		" ripgrep ":                        {Name: "ripgrep"},
+	} {
+		if got := ParsePackageSpec(input); got != want {
+			t.Errorf("ParsePackageSpec(%q) = %+v, want %+v", input, got, want)
+		}
+	}
+	if (PackageSpec{Name: "gopls", Version: "latest"}).Pinned() {
+		t.Error("latest is not a pin")
+	}
+}
+
+func TestParsePackageLists(t *testing.T) {
+	npm, err := parseNpmList([]byte(`{"name": "lib", "dependencies": {"typescript": {"version": "5.4.2", "overridden": false}, "@angular/cli": {"version": "17.3.0"}}}`))
+	if err != nil || !reflect.DeepEqual(npm, map[string]string{"typescript": "5.4.2", "@angular/cli": "17.3.0"}) {
+		t.Errorf("unexpected npm packages: %v, %v", npm, err)
+	}
+
+	pipx, err := parsePipxList([]byte(`{"pipx_spec_version": "0.1", "venvs": {"black": {"metadata": {"main_package": {"package": "black", "package_version": "24.2.0"}}}}}`))
+	if err != nil || !reflect.DeepEqual(pipx, map[string]string{"black": "24.2.0"}) {
+		t.Errorf("unexpected pipx packages: %v, %v", pipx, err)
+	}
+
+	cargo := parseCargoList("ripgrep v14.1.0:\n    rg\nmytool v0.1.0 (/Users/dev/src/mytool):\n    mytool\n")
+	if !reflect.DeepEqual(cargo, map[string]string{"ripgrep": "14.1.0", "mytool": "0.1.0"}) {
+		t.Errorf("unexpected cargo crates: %v", cargo)
+	}
+
+	pkg, version := parseGoVersion("/Users/dev/go/bin/gopls: go1.22.1\n\tpath\tgolang.org/x/tools/gopls\n\tmod\tgolang.org/x/tools/gopls\tv0.15.2\th1:abc=\n")
+	if pkg != "golang.org/x/tools/gopls" || version != "v0.15.2" {
+		t.Errorf("unexpected go version: %s %s", pkg, version)
+	}
+
+	if _, err := parseNpmList([]byte("not json")); err == nil {
+		t.Error("expected error for invalid npm output")
+	}
+}
+
+func TestGoBinaryName(t *testing.T) {
+	for pkg, want := range map[string]string{
+		"golang.org/x/tools/gopls":             "gopls",
+		"golang.org/x/tools/cmd/goimports":     "goimports",
+		"github.com/golangci/golangci-lint/v2": "golangci-lint",
+	} {
+		if got := goBinaryName(pkg); got != want {
+			t.Errorf("goBinaryName(%q) = %q, want %q", pkg, got, want)
+		}
+	}
+}
+
+func TestPackageSpecsGoVersion(t *testing.T) {
+	cfg := config.PackagesConfig{
+		config.PackagesGo:  {"a/gopls@0.15.2", "a/vet@v1.0.0", "a/lint@master", "a/fmt@latest", "a/doc"},
+		config.PackagesNpm: {"typescript@5.4.2"},
+	}
+
+	var got []string
+	for _, spec := range PackageSpecs(cfg, config.PackagesGo) {
+		got = append(got, spec.String())
+	}
+	if want := []string{"a/gopls@v0.15.2", "a/vet@v1.0.0", "a/lint@master", "a/fmt@latest", "a/doc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("go specs = %v, want %v", got, want)
+	}
+	if got := PackageSpecs(cfg, config.PackagesNpm)[0].Version; got != "5.4.2" {
+		t.Errorf("npm version = %q, want 5.4.2", got)
+	}
+}
+
+func TestPackageStates(t *testing.T) {
+	bin := t.TempDir()
+	fakeBinary(t, bin, "cargo", `echo "ripgrep v14.1.0:"; echo "    rg"; echo "bat v0.23.0:"; echo "    bat"`)
+
+	cfg := config.PackagesConfig{config.PackagesCargo: {"ripgrep@14", "bat@0.24.0", "fd-find"}}
+	states, err := PackageStates(context.Background(), executor.New(false, false), cfg, config.PackagesCargo)
+	if err != nil {
+		t.Fatalf("PackageStates failed: %v", err)
+	}
+	if len(states) != 3 {
+		t.Fatalf("expected 3 states, got %+v", states)
+	}
+	if states[0].Missing() || states[0].Mismatched() {
+		t.Errorf("ripgrep 14.1.0 satisfies the 14 pin: %+v", states[0])
+	}
+	if !states[1].Mismatched() || states[1].Installed != "0.23.0" {
+		t.Errorf("expected bat to be mismatched: %+v", states[1])
+	}
+	if !states[2].Missing() || states[2].Mismatched() {
+		t.Errorf("expected fd-find to be missing: %+v", states[2])
+	}
+}
+
+func TestPackagesInstallWithNpm(t *testing.T) {
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	fakeBinary(t, bin, "npm", `
+echo "$*" >> "`+calls+`"
+if [ "$1" = "ls" ]; then
+  echo '{"dependencies": {"typescript": {"version": "5.3.3"}, "prettier": {"version": "3.2.5"}}}'
+  exit 1
+fi
+`)
+
+	cfg := &config.Config{Packages: config.PackagesConfig{
+		config.PackagesNpm: {"typescript@5.4.2", "prettier", "@angular/cli"},
+	}}
+	p := NewPackagesInstaller(NewContext(cfg, false, false), config.PackagesNpm)
+	ctx := context.Background()
+
+	if p.IsInstalled(ctx) {
+		t.Error("expected missing packages to be reported")
+	}
+	if err := p.Install(ctx); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{"install -g typescript@5.4.2", "install -g @angular/cli"} {
+		if !strings.Contains(string(log), want+"\n") {
+			t.Errorf("expected npm %s, got:\n%s", want, log)
+		}
+	}
+	if strings.Contains(string(log), "prettier\n") {
+		t.Errorf("installed prettier again:\n%s", log)
+	}
+}
+
+func TestPackagesInstallWithoutBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cfg := &config.Config{Packages: config.PackagesConfig{config.PackagesPipx: {"black"}}}
+
+	if err := NewPackagesInstaller(NewContext(cfg, false, false), config.PackagesPipx).Install(context.Background()); err == nil {
+		t.Error("expected an error without pipx")
+	}
+	if err := NewPackagesInstaller(NewContext(cfg, true, false), config.PackagesPipx).Install(context.Background()); err != nil {
+		t.Errorf("dry-run should not need pipx: %v", err)
+	}
+}
+
+func TestPackagesUpdateWithPipx(t *testing.T) {
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	upgraded := filepath.Join(bin, "upgraded")
+	fakeBinary(t, bin, "pipx", `
+echo "$*" >> "`+calls+`"
+case "$1" in
+list)
+  black=24.1.0
+  [ -f "`+upgraded+`" ] && black=24.2.0
+  echo '{"venvs": {"black": {"metadata": {"main_package": {"package": "black", "package_version": "'$black'"}}},
+    "poetry": {"metadata": {"main_package": {"package": "poetry", "package_version": "1.7.0"}}},
+    "ruff": {"metadata": {"main_package": {"package": "ruff", "package_version": "0.3.0"}}}}}'
+  ;;
+upgrade)
+  if [ "$2" = black ]; then touch "`+upgraded+`"; fi
+  ;;
+esac
+`)
+
+	cfg := &config.Config{Packages: config.PackagesConfig{
+		config.PackagesPipx: {"black", "poetry@1.7.0", "ruff", "httpie"},
+	}}
+	u := NewPackagesUpdater(NewContext(cfg, false, false), config.PackagesPipx)
+	ctx := context.Background()
+
+	if !u.IsInstalled(ctx) {
+		t.Fatal("expected pipx packages to be updatable")
+	}
+	report, err := u.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	want := []PackageChange{{Name: "black", Kind: "pipx", From: "24.1.0", To: "24.2.0"}}
+	if !reflect.DeepEqual(report.Upgraded, want) {
+		t.Errorf("unexpected upgrades: %+v", report.Upgraded)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Name != "poetry" || report.Skipped[0].Reason != SkipPinned {
+		t.Errorf("expected pinned poetry to be skipped: %+v", report.Skipped)
+	}
+
+	log, _ := os.ReadFile(calls)
+	if strings.Contains(string(log), "httpie") || strings.Contains(string(log), "upgrade poetry") {
+		t.Errorf("upgraded a missing or pinned package:\n%s", log)
+	}
+}
+
+func TestPackagesGoInstall(t *testing.T) {
+	bin := t.TempDir()
+	gobin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	fakeBinary(t, bin, "go", `
+echo "$*" >> "`+calls+`"
+case "$1" in
+env) echo "`+gobin+`"; echo "/Users/dev/go" ;;
+version)
+  [ "$3" = "`+filepath.Join(gobin, "gopls")+`" ] || exit 1
+  printf '%s: go1.22.1\n\tpath\tgolang.org/x/tools/gopls\n\tmod\tgolang.org/x/tools/gopls\tv0.15.1\th1:abc=\n' "$3"
+  ;;
+esac
+`)
+
+	cfg := &config.Config{Packages: config.PackagesConfig{
+		config.PackagesGo: {"golang.org/x/tools/gopls@0.15.2", "golang.org/x/tools/cmd/goimports"},
+	}}
+	if err := NewPackagesInstaller(NewContext(cfg, false, false), config.PackagesGo).Install(context.Background()); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{"install golang.org/x/tools/gopls@v0.15.2", "install golang.org/x/tools/cmd/goimports@latest"} {
+		if !strings.Contains(string(log), want+"\n") {
+			t.Errorf("expected go %s, got:\n%s", want, log)
+		}
+	}
+}
@@ -28,6 +28,13 @@ var p10kStyles = []P10kStyle{
 	{Name: "pure", Description: "Pure - minimalist, inspired by sindresorhus/pure", ConfigFile: ""},
 }
 
+// P10kStyles returns the available Powerlevel10k styles
+func P10kStyles() []P10kStyle {
+	styles := make([]P10kStyle, len(p10kStyles))
+	copy(styles, p10kStyles)
+	return styles
+}
+
 // Powerlevel10kInstaller handles Powerlevel10k theme installation
 type Powerlevel10kInstaller struct {
 	ctx *Context
@@ -108,6 +115,22 @@ func (p *Powerlevel10kInstaller) Install(ctx context.Context) error {
 	return nil
 }
 
+// Update updates the Powerlevel10k checkout, a shallow clone
+func (p *Powerlevel10kInstaller) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	if !p.IsInstalled(ctx) {
+		return report, fmt.Errorf("powerlevel10k is not installed")
+	}
+
+	ui.PrintStep("Updating Powerlevel10k...")
+	dir := filepath.Join(ohMyZshDir(), "custom", "themes", "powerlevel10k")
+	repo := ohMyZshRepo{
+		GitRepo: GitRepo{Name: "powerlevel10k", Path: dir, Ref: p.ctx.Config.Terminal.OhMyZsh.Update.Pins["powerlevel10k"]},
+		Kind:    "theme",
+	}
+	return report, updateCheckouts(ctx, p.ctx, []ohMyZshRepo{repo}, report)
+}
+
 func (p *Powerlevel10kInstaller) installPowerlevel10k(ctx context.Context, homeDir string) error {
 	themesDir := filepath.Join(homeDir, ".oh-my-zsh", "custom", "themes")
 	p10kDir := filepath.Join(themesDir, "powerlevel10k")
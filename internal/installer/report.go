@@ -0,0 +1,133 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reasons a package was not upgraded
+const (
+	SkipPinned       = "pinned"
+	SkipHeld         = "version_constraint"
+	SkipExcluded     = "excluded"
+	SkipNotSelected  = "not selected"
+	SkipAutoUpdates  = "updates itself (not greedy)"
+	SkipFailed       = "upgrade failed"
+	SkipLocalChanges = "local changes"
+)
+
+// UpdateReport records what an updater changed
+type UpdateReport struct {
+	Component string           `json:"component"`
+	DryRun    bool             `json:"dry_run,omitempty"`
+	Upgraded  []PackageChange  `json:"upgraded,omitempty"`
+	Skipped   []SkippedPackage `json:"skipped,omitempty"`
+	Repos     []RepoChange     `json:"repos,omitempty"`
+	Duration  time.Duration    `json:"-"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// PackageChange is a package upgraded from one version to another
+type PackageChange struct {
+	Name string `json:"name"`
+	// Kind is formula, cask or app
+	Kind string `json:"kind"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SkippedPackage is an outdated package that was left alone
+type SkippedPackage struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+}
+
+// RepoChange is a git checkout moved from one commit to another
+type RepoChange struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Commits int    `json:"commits"`
+}
+
+// Range returns the short commit range, e.g. "1a2b3c4..5d6e7f8"
+func (r RepoChange) Range() string {
+	return shortSHA(r.From) + ".." + shortSHA(r.To)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// MarshalJSON writes the duration in seconds
+func (r UpdateReport) MarshalJSON() ([]byte, error) {
+	type report UpdateReport
+	return json.Marshal(struct {
+		report
+		Seconds float64 `json:"duration_seconds"`
+	}{report(r), r.Duration.Round(time.Millisecond).Seconds()})
+}
+
+// Changes returns the number of upgraded packages and moved repositories
+func (r *UpdateReport) Changes() int {
+	return len(r.Upgraded) + len(r.Repos)
+}
+
+// packageKind names an outdated package's kind for reports
+func packageKind(p OutdatedPackage) string {
+	if p.Cask {
+		return "cask"
+	}
+	return "formula"
+}
+
+// RenderMarkdown renders update reports for change tickets
+func RenderMarkdown(reports []*UpdateReport) string {
+	var b strings.Builder
+	b.WriteString("## setup-mac update\n\n")
+
+	for _, r := range reports {
+		fmt.Fprintf(&b, "### %s (%s)\n\n", r.Component, r.Duration.Round(time.Second))
+		if r.DryRun {
+			b.WriteString("_Dry run, nothing was changed._\n\n")
+		}
+		if r.Error != "" {
+			fmt.Fprintf(&b, "**Failed:** %s\n\n", r.Error)
+		}
+		if r.Changes() == 0 && len(r.Skipped) == 0 {
+			b.WriteString("No changes.\n\n")
+			continue
+		}
+
+		if len(r.Upgraded) > 0 {
+			b.WriteString("| Package | Kind | From | To |\n|---|---|---|---|\n")
+			for _, p := range r.Upgraded {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", p.Name, p.Kind, p.From, p.To)
+			}
+			b.WriteString("\n")
+		}
+		if len(r.Repos) > 0 {
+			b.WriteString("| Repository | Commits | Range |\n|---|---|---|\n")
+			for _, repo := range r.Repos {
+				fmt.Fprintf(&b, "| %s | %d | `%s` |\n", repo.Name, repo.Commits, repo.Range())
+			}
+			b.WriteString("\n")
+		}
+		if len(r.Skipped) > 0 {
+			b.WriteString("Skipped:\n\n")
+			for _, s := range r.Skipped {
+				fmt.Fprintf(&b, "- %s (%s): %s\n", s.Name, s.Kind, s.Reason)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
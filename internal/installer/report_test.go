@@ -0,0 +1,62 @@
+package installer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReports() []*UpdateReport {
+	return []*UpdateReport{
+		{
+			Component: "homebrew",
+			Upgraded:  []PackageChange{{Name: "git", Kind: "formula", From: "2.44.0", To: "2.45.1"}},
+			Skipped:   []SkippedPackage{{Name: "slack", Kind: "cask", Reason: SkipAutoUpdates}},
+			Duration:  95 * time.Second,
+		},
+		{
+			Component: "ohmyzsh",
+			Repos: []RepoChange{{
+				Name:    "oh-my-zsh",
+				From:    "1111111111111111111111111111111111111111",
+				To:      "2222222222222222222222222222222222222222",
+				Commits: 12,
+			}},
+			Duration: 3 * time.Second,
+		},
+		{Component: "mas", Error: "mas is not installed"},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	want := "## setup-mac update\n" +
+		"\n### homebrew (1m35s)\n\n" +
+		"| Package | Kind | From | To |\n|---|---|---|---|\n" +
+		"| git | formula | 2.44.0 | 2.45.1 |\n\n" +
+		"Skipped:\n\n" +
+		"- slack (cask): updates itself (not greedy)\n" +
+		"\n### ohmyzsh (3s)\n\n" +
+		"| Repository | Commits | Range |\n|---|---|---|\n" +
+		"| oh-my-zsh | 12 | `1111111..2222222` |\n" +
+		"\n### mas (0s)\n\n" +
+		"**Failed:** mas is not installed\n\n" +
+		"No changes.\n"
+
+	if got := RenderMarkdown(testReports()); got != want {
+		t.Errorf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUpdateReportJSON(t *testing.T) {
+	data, err := json.Marshal(testReports()[0])
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	for _, want := range []string{`"component":"homebrew"`, `"duration_seconds":95`, `"from":"2.44.0"`, `"reason":"updates itself (not greedy)"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+}
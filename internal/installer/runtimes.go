@@ -0,0 +1,378 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+const (
+	miseConfigFile   = "~/.config/mise/config.toml"
+	toolVersionsFile = "~/.tool-versions"
+
+	runtimesBlockStart   = "# Runtime versions (managed by setup-mac)"
+	runtimesBlockEnd     = "# End runtime versions"
+	activationBlockStart = "# Runtime manager (managed by setup-mac)"
+	activationBlockEnd   = "# End runtime manager"
+)
+
+// asdfPlugins maps tool names to asdf plugins where they differ. mise
+// accepts both.
+var asdfPlugins = map[string]string{
+	"node": "nodejs",
+	"go":   "golang",
+}
+
+// RuntimesInstaller installs language runtimes with mise or asdf
+type RuntimesInstaller struct {
+	ctx *Context
+}
+
+// NewRuntimesInstaller creates a new runtimes installer
+func NewRuntimesInstaller(ctx *Context) *RuntimesInstaller {
+	return &RuntimesInstaller{ctx: ctx}
+}
+
+// Name returns the installer name
+func (r *RuntimesInstaller) Name() string {
+	return "runtimes"
+}
+
+// Description returns the installer description
+func (r *RuntimesInstaller) Description() string {
+	if manager := r.ctx.Config.Runtimes.Manager; manager != "" {
+		return fmt.Sprintf("Language Runtimes (%s)", manager)
+	}
+	return "Language Runtimes"
+}
+
+// IsInstalled checks if every declared version is installed
+func (r *RuntimesInstaller) IsInstalled(ctx context.Context) bool {
+	cfg := r.ctx.Config.Runtimes
+	if cfg.Manager == "" || len(cfg.Tools) == 0 {
+		return true
+	}
+	for _, status := range RuntimeStatuses(ctx, r.ctx.Executor, cfg) {
+		if len(status.Missing) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Install installs the manager, writes the global tool versions, installs
+// them and activates the manager in .zshrc
+func (r *RuntimesInstaller) Install(ctx context.Context) error {
+	cfg := r.ctx.Config.Runtimes
+	if cfg.Manager == "" || len(cfg.Tools) == 0 {
+		ui.PrintInfo("No runtimes configured")
+		return nil
+	}
+
+	if err := r.installManager(ctx, cfg.Manager); err != nil {
+		return err
+	}
+
+	ui.PrintStep("Writing global tool versions...")
+	if err := r.writeVersions(cfg); err != nil {
+		return err
+	}
+
+	ui.PrintStep("Installing runtimes...")
+	failed := 0
+	for _, tool := range sortedTools(cfg.Tools) {
+		for _, version := range cfg.Tools[tool] {
+			if err := r.installVersion(ctx, cfg.Manager, tool, version); err != nil {
+				failed++
+			}
+		}
+	}
+
+	ui.PrintStep("Activating in .zshrc...")
+	if err := r.activate(cfg.Manager); err != nil {
+		return fmt.Errorf("failed to update .zshrc: %w", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d runtime version(s) failed to install", failed)
+	}
+	return nil
+}
+
+// installManager installs mise or asdf with Homebrew if it is missing
+func (r *RuntimesInstaller) installManager(ctx context.Context, manager string) error {
+	if r.ctx.Executor.Exists(manager) {
+		return nil
+	}
+	if !r.ctx.Executor.Exists("brew") && !r.ctx.DryRun {
+		return fmt.Errorf("homebrew is required to install %s", manager)
+	}
+
+	spinner := ui.NewSpinner(fmt.Sprintf("Installing %s...", manager))
+	spinner.Start()
+	result, err := r.ctx.Executor.Run(ctx, "brew", "install", manager)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to install %s", manager))
+		return fmt.Errorf("brew install %s failed: %w\n%s", manager, err, result.Stderr)
+	}
+	if result.DryRun {
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would install %s", manager))
+	} else {
+		spinner.Success(fmt.Sprintf("Installed %s", manager))
+	}
+	return nil
+}
+
+// writeVersions writes the managed block of ~/.config/mise/config.toml or
+// ~/.tool-versions
+func (r *RuntimesInstaller) writeVersions(cfg config.RuntimesConfig) error {
+	file, block := miseConfigFile, miseToolsBlock(cfg.Tools)
+	if cfg.Manager == config.RuntimeManagerAsdf {
+		file, block = toolVersionsFile, toolVersionsBlock(cfg.Tools)
+	}
+	path := expandHome(file)
+
+	if r.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf("Would write %d tool(s) to %s", len(cfg.Tools), file))
+		return nil
+	}
+
+	// A second [tools] table would make the file invalid TOML
+	if cfg.Manager == config.RuntimeManagerMise {
+		if content, err := os.ReadFile(path); err == nil && hasUnmanagedToolsTable(string(content)) {
+			return fmt.Errorf("%s already has a [tools] table, move its versions to runtimes.tools", file)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := updateManagedBlock(path, runtimesBlockStart, runtimesBlockEnd, block); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Tool versions written to %s", file))
+	return nil
+}
+
+func (r *RuntimesInstaller) installVersion(ctx context.Context, manager, tool, version string) error {
+	var args []string
+	if manager == config.RuntimeManagerAsdf {
+		plugin := asdfPlugin(tool)
+		// asdf needs the plugin before the version; adding it twice fails
+		if _, err := r.ctx.Executor.Query(ctx, "asdf", "list", plugin); err != nil {
+			if _, err := r.ctx.Executor.Run(ctx, "asdf", "plugin", "add", plugin); err != nil {
+				ui.PrintWarning(fmt.Sprintf("Failed to add asdf plugin %s", plugin))
+				return err
+			}
+		}
+		args = []string{"install", plugin, version}
+	} else {
+		args = []string{"install", tool + "@" + version}
+	}
+
+	spinner := ui.NewSpinner(fmt.Sprintf("Installing %s %s...", tool, version))
+	spinner.Start()
+	result, err := r.ctx.Executor.Run(ctx, manager, args...)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to install %s %s", tool, version))
+		return fmt.Errorf("%s %s failed: %w\n%s", manager, strings.Join(args, " "), err, result.Stderr)
+	}
+	if result.DryRun {
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would install %s %s", tool, version))
+	} else {
+		spinner.Success(fmt.Sprintf("Installed %s %s", tool, version))
+	}
+	return nil
+}
+
+// activate adds the manager's shell activation to the managed .zshrc block
+func (r *RuntimesInstaller) activate(manager string) error {
+	zshrcPath := expandHome("~/.zshrc")
+	block := activationBlock(manager)
+	if r.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf("Would add %s activation to %s", manager, zshrcPath))
+		return nil
+	}
+	return updateManagedBlock(zshrcPath, activationBlockStart, activationBlockEnd, block)
+}
+
+// activationBlock returns the .zshrc lines that put the manager's versions
+// on PATH
+func activationBlock(manager string) string {
+	line := `eval "$(mise activate zsh)"`
+	if manager == config.RuntimeManagerAsdf {
+		line = `export PATH="${ASDF_DATA_DIR:-$HOME/.asdf}/shims:$PATH"`
+	}
+	return activationBlockStart + "\n" + line + "\n" + activationBlockEnd
+}
+
+// miseToolsBlock renders the [tools] table of mise's config.toml
+func miseToolsBlock(tools map[string][]string) string {
+	lines := []string{runtimesBlockStart, "[tools]"}
+	for _, tool := range sortedTools(tools) {
+		versions := tools[tool]
+		if len(versions) == 1 {
+			lines = append(lines, fmt.Sprintf("%s = %s", tool, strconv.Quote(versions[0])))
+			continue
+		}
+		quoted := make([]string, len(versions))
+		for i, version := range versions {
+			quoted[i] = strconv.Quote(version)
+		}
+		lines = append(lines, fmt.Sprintf("%s = [%s]", tool, strings.Join(quoted, ", ")))
+	}
+	return strings.Join(append(lines, runtimesBlockEnd), "\n")
+}
+
+// toolVersionsBlock renders asdf's .tool-versions lines
+func toolVersionsBlock(tools map[string][]string) string {
+	lines := []string{runtimesBlockStart}
+	for _, tool := range sortedTools(tools) {
+		lines = append(lines, asdfPlugin(tool)+" "+strings.Join(tools[tool], " "))
+	}
+	return strings.Join(append(lines, runtimesBlockEnd), "\n")
+}
+
+// hasUnmanagedToolsTable reports a [tools] table outside the managed block
+func hasUnmanagedToolsTable(content string) bool {
+	if start := strings.Index(content, runtimesBlockStart); start != -1 {
+		if end := strings.Index(content, runtimesBlockEnd); end > start {
+			content = content[:start] + content[end+len(runtimesBlockEnd):]
+		}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "[tools]" {
+			return true
+		}
+	}
+	return false
+}
+
+func asdfPlugin(tool string) string {
+	if plugin, ok := asdfPlugins[tool]; ok {
+		return plugin
+	}
+	return tool
+}
+
+func sortedTools(tools map[string][]string) []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RuntimeStatus compares the declared versions of a tool with the installed
+// ones
+type RuntimeStatus struct {
+	Tool      string   `json:"tool"`
+	Declared  []string `json:"declared"`
+	Installed []string `json:"installed"`
+	Missing   []string `json:"missing,omitempty"`
+}
+
+// RuntimeStatuses reports each declared tool. Without the manager nothing
+// counts as installed.
+func RuntimeStatuses(ctx context.Context, exec *executor.Executor, cfg config.RuntimesConfig) []RuntimeStatus {
+	installed := make(map[string][]string)
+	if exec.Exists(cfg.Manager) {
+		installed = installedRuntimes(ctx, exec, cfg)
+	}
+
+	var statuses []RuntimeStatus
+	for _, tool := range sortedTools(cfg.Tools) {
+		status := RuntimeStatus{Tool: tool, Declared: cfg.Tools[tool], Installed: append([]string{}, installed[tool]...)}
+		for _, version := range status.Declared {
+			if !versionInstalled(version, status.Installed) {
+				status.Missing = append(status.Missing, version)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// installedRuntimes lists the installed versions of each declared tool
+func installedRuntimes(ctx context.Context, exec *executor.Executor, cfg config.RuntimesConfig) map[string][]string {
+	installed := make(map[string][]string)
+	if cfg.Manager == config.RuntimeManagerAsdf {
+		for tool := range cfg.Tools {
+			if result, err := exec.Query(ctx, "asdf", "list", asdfPlugin(tool)); err == nil {
+				installed[tool] = parseAsdfList(result.Stdout)
+			}
+		}
+		return installed
+	}
+
+	result, err := exec.Query(ctx, "mise", "ls", "--installed", "--json")
+	if err != nil {
+		return installed
+	}
+	versions, err := parseMiseList([]byte(result.Stdout))
+	if err != nil {
+		return installed
+	}
+	for tool := range cfg.Tools {
+		installed[tool] = versions[tool]
+		if alias, ok := asdfPlugins[tool]; ok && len(installed[tool]) == 0 {
+			installed[tool] = versions[alias]
+		}
+	}
+	return installed
+}
+
+// parseMiseList reads mise ls --installed --json output
+func parseMiseList(data []byte) (map[string][]string, error) {
+	var tools map[string][]struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("failed to parse mise ls output: %w", err)
+	}
+
+	versions := make(map[string][]string)
+	for tool, entries := range tools {
+		for _, entry := range entries {
+			versions[tool] = append(versions[tool], entry.Version)
+		}
+	}
+	return versions, nil
+}
+
+// parseAsdfList reads asdf list <plugin> output, where the current version
+// is marked with *
+func parseAsdfList(output string) []string {
+	var versions []string
+	for _, line := range strings.Split(output, "\n") {
+		version := strings.TrimPrefix(strings.TrimSpace(line), "*")
+		if version != "" && !strings.HasPrefix(version, "No versions") {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// versionInstalled reports whether a declared version is satisfied. A
+// prefix such as 20 matches 20.11.1; latest and lts match any version.
+func versionInstalled(declared string, installed []string) bool {
+	for _, version := range installed {
+		switch {
+		case declared == version,
+			strings.HasPrefix(version, declared+"."),
+			declared == "latest", declared == "lts":
+			return true
+		}
+	}
+	return false
+}
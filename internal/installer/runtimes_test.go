@@ -0,0 +1,163 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+var testTools = map[string][]string{
+	"python":    {"3.12.2"},
+	"node":      {"20.11.1", "18.19.0"},
+	"terraform": {"1.7"},
+}
+
+func TestToolVersionFiles(t *testing.T) {
+	wantMise := `# Runtime versions (managed by setup-mac)
+[tools]
+node = ["20.11.1", "18.19.0"]
+python = "3.12.2"
+terraform = "1.7"
+# End runtime versions`
+	if got := miseToolsBlock(testTools); got != wantMise {
+		t.Errorf("unexpected config.toml block:\n%s", got)
+	}
+
+	wantAsdf := `# Runtime versions (managed by setup-mac)
+nodejs 20.11.1 18.19.0
+python 3.12.2
+terraform 1.7
+# End runtime versions`
+	if got := toolVersionsBlock(testTools); got != wantAsdf {
+		t.Errorf("unexpected .tool-versions block:\n%s", got)
+	}
+}
+
+func TestHasUnmanagedToolsTable(t *testing.T) {
+	managed := "[settings]\nexperimental = true\n\n" + miseToolsBlock(testTools) + "\n"
+	if hasUnmanagedToolsTable(managed) {
+		t.Error("managed [tools] table reported as unmanaged")
+	}
+	if !hasUnmanagedToolsTable("[tools]\nnode = \"20\"\n") {
+		t.Error("expected the user's [tools] table to be found")
+	}
+}
+
+func TestParseMiseList(t *testing.T) {
+	output := `{
+  "node": [
+    {"version": "18.19.0", "install_path": "/Users/dev/.local/share/mise/installs/node/18.19.0", "installed": true},
+    {"version": "20.11.1", "install_path": "/Users/dev/.local/share/mise/installs/node/20.11.1", "installed": true, "active": true}
+  ],
+  "python": [
+    {"version": "3.12.2", "installed": true}
+  ]
+}`
+	got, err := parseMiseList([]byte(output))
+	if err != nil {
+		t.Fatalf("parseMiseList failed: %v", err)
+	}
+	want := map[string][]string{"node": {"18.19.0", "20.11.1"}, "python": {"3.12.2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := parseMiseList([]byte("not json")); err == nil {
+		t.Error("expected error for invalid output")
+	}
+}
+
+func TestParseAsdfList(t *testing.T) {
+	got := parseAsdfList("  18.19.0\n *20.11.1\n")
+	if !reflect.DeepEqual(got, []string{"18.19.0", "20.11.1"}) {
+		t.Errorf("unexpected versions: %v", got)
+	}
+	if got := parseAsdfList("  No versions installed\n"); len(got) != 0 {
+		t.Errorf("expected no versions, got %v", got)
+	}
+}
+
+func TestVersionInstalled(t *testing.T) {
+	installed := []string{"1.7.5", "20.11.1"}
+	for declared, want := range map[string]bool{
+		"20.11.1": true,
+		"20":      true,
+		"1.7":     true,
+		"1.75":    false,
+		"2":       false,
+		"latest":  true,
+	} {
+		if got := versionInstalled(declared, installed); got != want {
+			t.Errorf("versionInstalled(%q) = %v, want %v", declared, got, want)
+		}
+	}
+	if versionInstalled("latest", nil) {
+		t.Error("latest needs some version installed")
+	}
+}
+
+func TestRuntimesInstallWithMise(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	fakeBinary(t, bin, "mise", `
+echo "$*" >> "`+calls+`"
+if [ "$1" = "ls" ]; then
+  echo '{"node": [{"version": "20.11.1"}], "python": [{"version": "3.12.2"}]}'
+fi
+`)
+
+	cfg := &config.Config{}
+	cfg.Runtimes = config.RuntimesConfig{Manager: config.RuntimeManagerMise, Tools: testTools}
+	r := NewRuntimesInstaller(NewContext(cfg, false, false))
+	ctx := context.Background()
+
+	statuses := RuntimeStatuses(ctx, executor.New(false, false), cfg.Runtimes)
+	if len(statuses) != 3 || !reflect.DeepEqual(statuses[0].Missing, []string{"18.19.0"}) || !reflect.DeepEqual(statuses[2].Missing, []string{"1.7"}) {
+		t.Errorf("unexpected statuses: %+v", statuses)
+	}
+	if len(statuses[1].Missing) != 0 || statuses[1].Installed[0] != "3.12.2" {
+		t.Errorf("expected python to be installed: %+v", statuses[1])
+	}
+	if r.IsInstalled(ctx) {
+		t.Error("expected missing versions to be reported")
+	}
+
+	if err := r.Install(ctx); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{"install node@20.11.1", "install node@18.19.0", "install python@3.12.2", "install terraform@1.7"} {
+		if !strings.Contains(string(log), want+"\n") {
+			t.Errorf("expected mise %s, got:\n%s", want, log)
+		}
+	}
+
+	toml, err := os.ReadFile(filepath.Join(home, ".config", "mise", "config.toml"))
+	if err != nil || !strings.Contains(string(toml), `node = ["20.11.1", "18.19.0"]`) {
+		t.Errorf("unexpected config.toml (%v):\n%s", err, toml)
+	}
+	zshrc, _ := os.ReadFile(filepath.Join(home, ".zshrc"))
+	if !strings.Contains(string(zshrc), `eval "$(mise activate zsh)"`) {
+		t.Errorf("activation missing from .zshrc:\n%s", zshrc)
+	}
+
+	// Running again replaces the blocks instead of adding more
+	if err := r.Install(ctx); err != nil {
+		t.Fatalf("second Install failed: %v", err)
+	}
+	toml, _ = os.ReadFile(filepath.Join(home, ".config", "mise", "config.toml"))
+	zshrc, _ = os.ReadFile(filepath.Join(home, ".zshrc"))
+	if strings.Count(string(toml), "[tools]") != 1 || strings.Count(string(zshrc), "mise activate") != 1 {
+		t.Errorf("blocks duplicated:\n%s\n%s", toml, zshrc)
+	}
+}
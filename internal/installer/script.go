@@ -0,0 +1,165 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// vendoredScripts holds the install scripts copied in by make vendor-scripts
+//
+//go:embed scripts
+var vendoredScripts embed.FS
+
+// scriptClient downloads install scripts
+var scriptClient = &http.Client{Timeout: 2 * time.Minute}
+
+// commitSHA matches a full git commit, the only immutable kind of ref
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// pinnedCommit reports whether ref is a full commit SHA
+func pinnedCommit(ref string) bool {
+	return commitSHA.MatchString(ref)
+}
+
+// scriptURL returns the download URL with {commit} filled in
+func scriptURL(cfg config.InstallScript) string {
+	return strings.ReplaceAll(cfg.URL, "{commit}", cfg.Commit)
+}
+
+// describeScript says where a script comes from and how it is checked
+func describeScript(name string, cfg config.InstallScript) string {
+	source := "download " + scriptURL(cfg)
+	if cfg.Source == config.ScriptVendored {
+		source = fmt.Sprintf("use vendored %s.sh", name)
+	}
+
+	switch {
+	case cfg.SHA256 != "":
+		return fmt.Sprintf("%s, verify sha256 %s", source, cfg.SHA256)
+	case cfg.Source == config.ScriptVendored:
+		return source
+	case pinnedCommit(cfg.Commit):
+		return fmt.Sprintf("%s, pinned to commit %s", source, cfg.Commit)
+	}
+	return source + ", unverified"
+}
+
+// ScriptPinned reports whether the script cannot change without the config
+// changing: it has a checksum, is vendored or is fetched at a full commit
+func ScriptPinned(cfg config.InstallScript) bool {
+	return cfg.SHA256 != "" || cfg.Source == config.ScriptVendored || pinnedCommit(cfg.Commit)
+}
+
+// fetchScript writes an install script to a temp file and checks its
+// checksum. The caller removes the file.
+func fetchScript(ctx context.Context, name string, cfg config.InstallScript) (string, error) {
+	var data []byte
+	switch cfg.Source {
+	case config.ScriptVendored:
+		content, err := fs.ReadFile(vendoredScripts, "scripts/"+name+".sh")
+		if err != nil {
+			return "", fmt.Errorf("no vendored %s.sh in this build (run make vendor-scripts)", name)
+		}
+		data = content
+	case config.ScriptDownload, "":
+		content, err := downloadScript(ctx, scriptURL(cfg))
+		if err != nil {
+			return "", err
+		}
+		data = content
+	default:
+		return "", fmt.Errorf("unknown install script source: %s (valid: download, vendored)", cfg.Source)
+	}
+
+	if err := verifyScript(data, cfg.SHA256); err != nil {
+		return "", fmt.Errorf("%s.sh: %w", name, err)
+	}
+
+	file, err := os.CreateTemp("", "setup-mac-"+name+"-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write %s.sh: %w", name, err)
+	}
+	return file.Name(), nil
+}
+
+func downloadScript(ctx context.Context, url string) ([]byte, error) {
+	if url == "" {
+		return nil, fmt.Errorf("install script url is not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := scriptClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyScript compares the script's sha256 with want, if set
+func verifyScript(data []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch: expected sha256 %s, got %s", want, got)
+	}
+	return nil
+}
+
+// prepareScript fetches an install script, warns if nothing pins it and, with
+// show set, prints it and asks before it runs. The caller removes the file.
+func prepareScript(ctx context.Context, ictx *Context, name string, cfg config.InstallScript) (string, error) {
+	path, err := fetchScript(ctx, name, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if !ScriptPinned(cfg) {
+		ui.PrintWarning(fmt.Sprintf("%s.sh is not verified (set install_script.sha256 or pin commit)", name))
+	}
+
+	if cfg.Show {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		fmt.Println(string(content))
+
+		confirm, err := ictx.Prompt.Confirm(fmt.Sprintf("Run %s.sh?", name), false)
+		if err != nil || !confirm {
+			os.Remove(path)
+			return "", fmt.Errorf("%s.sh was not approved", name)
+		}
+	}
+
+	return path, nil
+}
@@ -0,0 +1,115 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+const testScript = "#!/bin/sh\necho installed\n"
+
+func scriptServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/install/abc123/install.sh" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testScript))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchScriptVerifiesChecksum(t *testing.T) {
+	server := scriptServer(t)
+	sum := sha256.Sum256([]byte(testScript))
+
+	cfg := config.InstallScript{
+		URL:    server.URL + "/install/{commit}/install.sh",
+		Commit: "abc123",
+		SHA256: strings.ToUpper(hex.EncodeToString(sum[:])),
+	}
+
+	path, err := fetchScript(context.Background(), "test-install", cfg)
+	if err != nil {
+		t.Fatalf("fetchScript failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read script: %v", err)
+	}
+	if string(content) != testScript {
+		t.Errorf("unexpected script content: %q", content)
+	}
+}
+
+func TestFetchScriptRejectsMismatch(t *testing.T) {
+	server := scriptServer(t)
+
+	cfg := config.InstallScript{
+		URL:    server.URL + "/install/{commit}/install.sh",
+		Commit: "abc123",
+		SHA256: strings.Repeat("0", 64),
+	}
+
+	path, err := fetchScript(context.Background(), "test-install", cfg)
+	if err == nil {
+		os.Remove(path)
+		t.Fatal("expected checksum mismatch")
+	}
+	if !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFetchScriptDownloadErrors(t *testing.T) {
+	server := scriptServer(t)
+
+	cfg := config.InstallScript{URL: server.URL + "/install/{commit}/install.sh", Commit: "main"}
+	if _, err := fetchScript(context.Background(), "test-install", cfg); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error, got %v", err)
+	}
+
+	cfg = config.InstallScript{Source: "curl"}
+	if _, err := fetchScript(context.Background(), "test-install", cfg); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
+
+func TestFetchScriptVendoredMissing(t *testing.T) {
+	cfg := config.InstallScript{Source: config.ScriptVendored}
+	_, err := fetchScript(context.Background(), "no-such-install", cfg)
+	if err == nil || !strings.Contains(err.Error(), "make vendor-scripts") {
+		t.Errorf("expected missing vendored script error, got %v", err)
+	}
+}
+
+func TestScriptPinned(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.InstallScript
+		want bool
+	}{
+		{"branch", config.InstallScript{Commit: "HEAD"}, false},
+		{"short sha", config.InstallScript{Commit: "abc123"}, false},
+		{"full sha", config.InstallScript{Commit: strings.Repeat("a", 40)}, true},
+		{"checksum", config.InstallScript{Commit: "HEAD", SHA256: strings.Repeat("0", 64)}, true},
+		{"vendored", config.InstallScript{Source: config.ScriptVendored}, true},
+	}
+
+	for _, tt := range tests {
+		if got := ScriptPinned(tt.cfg); got != tt.want {
+			t.Errorf("%s: ScriptPinned() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
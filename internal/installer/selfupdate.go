@@ -0,0 +1,217 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// ErrChecksumMismatch is returned when a download does not match its sha256
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// SelfUpdater replaces the running setup-mac binary with the latest release
+type SelfUpdater struct {
+	ctx     *Context
+	version string
+}
+
+// NewSelfUpdater creates an updater for the setup-mac binary at version
+func NewSelfUpdater(ctx *Context, version string) *SelfUpdater {
+	return &SelfUpdater{ctx: ctx, version: version}
+}
+
+// Name returns the updater name
+func (s *SelfUpdater) Name() string {
+	return "self"
+}
+
+// Description returns the updater description
+func (s *SelfUpdater) Description() string {
+	return "setup-mac"
+}
+
+// IsInstalled reports false for development builds, which have no release
+// to update to
+func (s *SelfUpdater) IsInstalled(ctx context.Context) bool {
+	return s.version != "" && s.version != "dev"
+}
+
+// Update downloads the latest release for this architecture and replaces
+// the binary. Homebrew installs are left to update --homebrew.
+func (s *SelfUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	if !s.IsInstalled(ctx) {
+		ui.PrintInfo("Development build, nothing to update")
+		return report, nil
+	}
+
+	ui.PrintStep("Checking for a new setup-mac release...")
+	checker := NewVersionChecker(s.version)
+	release, isNewer, err := checker.CheckForUpdate(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	if !isNewer {
+		ui.PrintInfo(fmt.Sprintf("setup-mac %s is up to date", s.version))
+		return report, nil
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return report, fmt.Errorf("failed to find the setup-mac binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	if strings.Contains(binary, "/Cellar/") {
+		ui.PrintInfo("setup-mac is installed with Homebrew, update --homebrew upgrades it")
+		return report, nil
+	}
+
+	url := checker.GetDownloadURL(release)
+	if url == release.HTMLURL {
+		return report, fmt.Errorf("release %s has no build for this Mac, see %s", release.TagName, url)
+	}
+	checksums := checker.GetChecksumsURL(release)
+	if checksums == "" {
+		return report, fmt.Errorf("release %s publishes no %s to verify the download, see %s", release.TagName, releaseChecksums, release.HTMLURL)
+	}
+
+	change := PackageChange{Name: "setup-mac", Kind: "setup-mac", From: s.version, To: release.TagName}
+	if s.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf("Would replace %s with %s, verified against %s", binary, url, releaseChecksums))
+		report.Upgraded = append(report.Upgraded, change)
+		return report, nil
+	}
+
+	spinner := ui.NewSpinner(fmt.Sprintf("Downloading setup-mac %s...", release.TagName))
+	spinner.Start()
+	if err := replaceBinary(ctx, url, checksums, binary); err != nil {
+		spinner.Fail("Failed to update setup-mac")
+		return report, err
+	}
+	spinner.Success(fmt.Sprintf("setup-mac updated to %s", release.TagName))
+	report.Upgraded = append(report.Upgraded, change)
+	return report, nil
+}
+
+// maxReleaseSize bounds the release downloads held in memory
+const maxReleaseSize = 256 << 20
+
+// replaceBinary downloads the release tarball at url, checks it against
+// the sha256 listed in the checksums file, and moves the setup-mac binary
+// from it over binary. Nothing is replaced unless the checksum matches.
+func replaceBinary(ctx context.Context, url, checksumsURL, binary string) error {
+	checksums, err := downloadRelease(ctx, checksumsURL)
+	if err != nil {
+		return err
+	}
+	asset := path.Base(url)
+	want, ok := parseChecksums(string(checksums))[asset]
+	if !ok {
+		return fmt.Errorf("%s has no checksum for %s", releaseChecksums, asset)
+	}
+
+	tarball, err := downloadRelease(ctx, url)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(tarball)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s: %w: got %s, want %s", asset, ErrChecksumMismatch, got, want)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return fmt.Errorf("invalid release archive: %w", err)
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return fmt.Errorf("release archive has no setup-mac binary")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid release archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == "setup-mac" {
+			break
+		}
+	}
+
+	// Write next to the binary so the rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(binary), ".setup-mac-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", filepath.Dir(binary), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, archive); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to extract setup-mac: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), binary); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", binary, err)
+	}
+	return nil
+}
+
+// downloadRelease fetches a release asset
+func downloadRelease(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Timeout: 2 * time.Minute}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if len(data) > maxReleaseSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxReleaseSize)
+	}
+	return data, nil
+}
+
+// parseChecksums reads sha256sum output, keyed by file name:
+//
+//	3b1f...  setup-mac-darwin-arm64.tar.gz
+func parseChecksums(content string) map[string]string {
+	sums := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		sums[path.Base(strings.TrimPrefix(fields[1], "*"))] = fields[0]
+	}
+	return sums
+}
@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// releaseTarball builds a release archive with the given files
+func releaseTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplaceBinary(t *testing.T) {
+	good := releaseTarball(t, map[string]string{"setup-mac-darwin-arm64/setup-mac": "new binary", "README.md": "docs"})
+	empty := releaseTarball(t, map[string]string{"README.md": "docs"})
+	tampered := releaseTarball(t, map[string]string{"setup-mac-darwin-arm64/setup-mac": "evil binary"})
+	checksums := sha256Hex(good) + "  good.tar.gz\n" +
+		sha256Hex(empty) + "  empty.tar.gz\n" +
+		sha256Hex(good) + " *tampered.tar.gz\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good.tar.gz":
+			w.Write(good)
+		case "/empty.tar.gz":
+			w.Write(empty)
+		case "/tampered.tar.gz", "/unlisted.tar.gz":
+			w.Write(tampered)
+		case "/checksums.txt":
+			w.Write([]byte(checksums))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	binary := filepath.Join(t.TempDir(), "setup-mac")
+	if err := os.WriteFile(binary, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sums := server.URL + "/checksums.txt"
+
+	for _, path := range []string{"/empty.tar.gz", "/missing.tar.gz", "/tampered.tar.gz", "/unlisted.tar.gz"} {
+		if err := replaceBinary(ctx, server.URL+path, sums, binary); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+		if data, _ := os.ReadFile(binary); string(data) != "old binary" {
+			t.Errorf("%s: binary changed to %q", path, data)
+		}
+	}
+	if err := replaceBinary(ctx, server.URL+"/tampered.tar.gz", sums, binary); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if err := replaceBinary(ctx, server.URL+"/good.tar.gz", server.URL+"/missing.txt", binary); err == nil {
+		t.Error("expected an error without a checksums file")
+	}
+
+	if err := replaceBinary(ctx, server.URL+"/good.tar.gz", sums, binary); err != nil {
+		t.Fatalf("replaceBinary failed: %v", err)
+	}
+	info, err := os.Stat(binary)
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("unexpected binary mode: %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(binary); string(data) != "new binary" {
+		t.Errorf("binary not replaced: %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(binary)); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/secrets"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
@@ -128,20 +130,48 @@ func (s *ShellInstaller) configureAliases(ctx context.Context, zshrcPath string,
 }
 
 func (s *ShellInstaller) configureEnvironment(ctx context.Context, zshrcPath string, env map[string]string) error {
+	// Secret references are resolved into a separate file instead of .zshrc
+	plain := make(map[string]string)
+	secretRefs := make(map[string]string)
+	for name, value := range env {
+		if secrets.IsRef(value) {
+			secretRefs[name] = value
+		} else {
+			plain[name] = value
+		}
+	}
+
+	secretsFile := expandHome(s.ctx.Config.Shell.SecretsFile)
+	if len(secretRefs) > 0 && secretsFile == "" {
+		return fmt.Errorf("shell.secrets_file must be set to use secret references")
+	}
+
 	if s.ctx.DryRun {
 		ui.PrintDryRun(fmt.Sprintf("Would configure %d environment variables", len(env)))
-		for name, value := range env {
+		for name, value := range plain {
 			ui.PrintDryRun(fmt.Sprintf("  export %s=\"%s\"", name, value))
 		}
+		for name := range secretRefs {
+			ui.PrintDryRun(fmt.Sprintf("  export %s=\"%s\" (secret, written to %s)", name, secrets.Mask, secretsFile))
+		}
 		return nil
 	}
 
+	if len(secretRefs) > 0 {
+		if err := s.writeSecretsFile(ctx, secretsFile, secretRefs); err != nil {
+			return fmt.Errorf("failed to write secrets file: %w", err)
+		}
+	}
+
 	// Build environment block
 	var envLines []string
 	envLines = append(envLines, "# Environment variables (managed by setup-mac)")
-	for name, value := range env {
+	for name, value := range plain {
 		envLines = append(envLines, fmt.Sprintf("export %s=\"%s\"", name, value))
 	}
+	if len(secretRefs) > 0 {
+		envLines = append(envLines, fmt.Sprintf("[ -f %q ] && source %q", secretsFile, secretsFile))
+	}
 	envLines = append(envLines, "# End environment variables")
 
 	envBlock := strings.Join(envLines, "\n")
@@ -149,6 +179,40 @@ func (s *ShellInstaller) configureEnvironment(ctx context.Context, zshrcPath str
 	return s.updateZshrcBlock(zshrcPath, "# Environment variables (managed by setup-mac)", "# End environment variables", envBlock)
 }
 
+// writeSecretsFile resolves secret references and writes them as exports
+// to a file only readable by the user
+func (s *ShellInstaller) writeSecretsFile(ctx context.Context, path string, refs map[string]string) error {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# Secrets (managed by setup-mac) - do not commit this file\n")
+	for _, name := range names {
+		value, err := s.ctx.Secrets.Resolve(ctx, refs[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(value))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote %d secret(s) to %s", len(names), path))
+	return nil
+}
+
 func (s *ShellInstaller) addExtras(ctx context.Context, zshrcPath string, extras []string) error {
 	if s.ctx.DryRun {
 		ui.PrintDryRun(fmt.Sprintf("Would add %d extra lines to .zshrc", len(extras)))
@@ -167,12 +231,18 @@ func (s *ShellInstaller) addExtras(ctx context.Context, zshrcPath string, extras
 }
 
 func (s *ShellInstaller) updateZshrcBlock(zshrcPath, startMarker, endMarker, newBlock string) error {
+	return updateManagedBlock(zshrcPath, startMarker, endMarker, newBlock)
+}
+
+// updateManagedBlock replaces the block between the markers in a file, or
+// appends it if the file has none
+func updateManagedBlock(path, startMarker, endMarker, newBlock string) error {
 	// Read current content
-	content, err := os.ReadFile(zshrcPath)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Create new file with block
-			return os.WriteFile(zshrcPath, []byte(newBlock+"\n"), 0644)
+			return os.WriteFile(path, []byte(newBlock+"\n"), 0644)
 		}
 		return err
 	}
@@ -186,7 +256,7 @@ func (s *ShellInstaller) updateZshrcBlock(zshrcPath, startMarker, endMarker, new
 	if startIdx != -1 && endIdx != -1 && endIdx > startIdx {
 		// Replace existing block
 		newContent := contentStr[:startIdx] + newBlock + contentStr[endIdx+len(endMarker):]
-		return os.WriteFile(zshrcPath, []byte(newContent), 0644)
+		return os.WriteFile(path, []byte(newContent), 0644)
 	}
 
 	// Append new block
@@ -195,5 +265,10 @@ func (s *ShellInstaller) updateZshrcBlock(zshrcPath, startMarker, endMarker, new
 	}
 	contentStr += "\n" + newBlock + "\n"
 
-	return os.WriteFile(zshrcPath, []byte(contentStr), 0644)
+	return os.WriteFile(path, []byte(contentStr), 0644)
+}
+
+// shellQuote quotes a value for POSIX shells using single quotes
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
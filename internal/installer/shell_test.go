@@ -0,0 +1,64 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestConfigureEnvironmentWritesSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	zshrcPath := filepath.Join(dir, ".zshrc")
+	secretsPath := filepath.Join(dir, "config", "secrets.zsh")
+
+	t.Setenv("SETUP_MAC_TEST_TOKEN", "it's-secret")
+
+	cfg := &config.Config{}
+	cfg.Shell.SecretsFile = secretsPath
+	s := NewShellInstaller(NewContext(cfg, false, false))
+
+	env := map[string]string{
+		"EDITOR":       "vim",
+		"GITHUB_TOKEN": "secret://env:SETUP_MAC_TEST_TOKEN",
+	}
+	if err := s.configureEnvironment(context.Background(), zshrcPath, env); err != nil {
+		t.Fatalf("failed to configure environment: %v", err)
+	}
+
+	info, err := os.Stat(secretsPath)
+	if err != nil {
+		t.Fatalf("secrets file not written: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected secrets file mode 0600, got %o", mode)
+	}
+
+	content, _ := os.ReadFile(secretsPath)
+	if !strings.Contains(string(content), `export GITHUB_TOKEN='it'\''s-secret'`) {
+		t.Errorf("expected quoted export in secrets file, got:\n%s", content)
+	}
+
+	zshrc, _ := os.ReadFile(zshrcPath)
+	if strings.Contains(string(zshrc), "it's-secret") {
+		t.Errorf("secret leaked into .zshrc:\n%s", zshrc)
+	}
+	if !strings.Contains(string(zshrc), "source \""+secretsPath+"\"") {
+		t.Errorf("expected .zshrc to source the secrets file, got:\n%s", zshrc)
+	}
+	if !strings.Contains(string(zshrc), `export EDITOR="vim"`) {
+		t.Errorf("expected plain variable in .zshrc, got:\n%s", zshrc)
+	}
+}
+
+func TestConfigureEnvironmentRequiresSecretsFile(t *testing.T) {
+	s := NewShellInstaller(NewContext(&config.Config{}, false, false))
+
+	env := map[string]string{"TOKEN": "secret://env:SETUP_MAC_TEST_TOKEN"}
+	if err := s.configureEnvironment(context.Background(), filepath.Join(t.TempDir(), ".zshrc"), env); err == nil {
+		t.Error("expected error without shell.secrets_file")
+	}
+}
@@ -119,14 +119,7 @@ func (s *SSHInstaller) Install(ctx context.Context) error {
 }
 
 func (s *SSHInstaller) expandKeyPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return path
-		}
-		return filepath.Join(homeDir, path[2:])
-	}
-	return path
+	return expandHome(path)
 }
 
 func (s *SSHInstaller) addToAgent(ctx context.Context, keyFile string) error {
@@ -2,16 +2,22 @@ package installer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
 	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
 )
 
 // HomebrewUpdater handles Homebrew updates
 type HomebrewUpdater struct {
 	ctx *Context
+	// Only limits the upgrade to formulae and casks matching these globs
+	Only []string
+	// Pick lets the user choose from the outdated packages
+	Pick bool
 }
 
 // NewHomebrewUpdater creates a new Homebrew updater
@@ -29,11 +35,18 @@ func (h *HomebrewUpdater) Description() string {
 	return "Homebrew Package Manager"
 }
 
-// Update updates Homebrew and all packages
-func (h *HomebrewUpdater) Update(ctx context.Context) error {
+// IsInstalled checks if Homebrew is installed
+func (h *HomebrewUpdater) IsInstalled(ctx context.Context) bool {
+	return h.ctx.Executor.Exists("brew")
+}
+
+// Update updates Homebrew and upgrades the selected outdated packages
+func (h *HomebrewUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+
 	// Check if Homebrew is installed
 	if !h.ctx.Executor.Exists("brew") {
-		return fmt.Errorf("homebrew is not installed")
+		return report, fmt.Errorf("homebrew is not installed")
 	}
 
 	// Update Homebrew itself
@@ -46,39 +59,41 @@ func (h *HomebrewUpdater) Update(ctx context.Context) error {
 		result, err := h.ctx.Executor.Run(ctx, "brew", "update")
 		if err != nil {
 			spinner.Fail("Failed to update Homebrew")
-			return fmt.Errorf("brew update failed: %w\n%s", err, result.Stderr)
+			return report, fmt.Errorf("brew update failed: %w\n%s", err, result.Stderr)
 		}
 		spinner.Success("Homebrew updated")
 	}
-
-	// Upgrade all packages
-	ui.PrintStep("Upgrading packages...")
-	if h.ctx.DryRun {
-		ui.PrintDryRun("brew upgrade")
+	// The index is fresh, don't let each upgrade update it again
+	h.ctx.Executor.SetEnv("HOMEBREW_NO_AUTO_UPDATE", "1")
+
+	// Pin formulae and hold back upgrades that break a version constraint
+	cfg := h.ctx.Config.Homebrew
+	var held []string
+	if state, err := LoadBrewState(ctx, h.ctx.Executor); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not read installed packages: %v", err))
 	} else {
-		spinner := ui.NewSpinner("Running brew upgrade...")
-		spinner.Start()
-		result, err := h.ctx.Executor.Run(ctx, "brew", "upgrade")
-		if err != nil {
-			spinner.Fail("Failed to upgrade packages")
-			return fmt.Errorf("brew upgrade failed: %w\n%s", err, result.Stderr)
-		}
-		spinner.Success("Packages upgraded")
+		pinFormulae(ctx, h.ctx.Executor, state, cfg.Formulae, h.ctx.DryRun)
+		_, held = splitUpgrades(state, cfg.Formulae)
 	}
 
-	// Upgrade casks
-	ui.PrintStep("Upgrading casks...")
-	if h.ctx.DryRun {
-		ui.PrintDryRun("brew upgrade --cask")
+	formulae, casks, err := h.outdated(ctx, held, report)
+	if err != nil {
+		return report, err
+	}
+
+	if len(formulae)+len(casks) == 0 {
+		ui.PrintInfo("No packages to upgrade")
 	} else {
-		spinner := ui.NewSpinner("Running brew upgrade --cask...")
-		spinner.Start()
-		result, err := h.ctx.Executor.Run(ctx, "brew", "upgrade", "--cask")
+		// Casks are skipped when the formulae fail, so only record what ran
+		attempted := formulae
+		err := h.upgradeFormulae(ctx, formulae)
+		if err == nil {
+			h.upgradeCasks(ctx, casks)
+			attempted = append(attempted, casks...)
+		}
+		h.record(ctx, report, attempted)
 		if err != nil {
-			// Cask upgrade failures are often non-critical (app already running, etc.)
-			spinner.Warning(fmt.Sprintf("Some casks may not have been upgraded: %s", result.Stderr))
-		} else {
-			spinner.Success("Casks upgraded")
+			return report, err
 		}
 	}
 
@@ -97,9 +112,202 @@ func (h *HomebrewUpdater) Update(ctx context.Context) error {
 		}
 	}
 
+	writeBrewLock(ctx, h.ctx.Executor, cfg, h.ctx.DryRun)
+
+	return report, nil
+}
+
+// outdated returns the outdated formulae and casks left after pins, held
+// formulae, --only, the exclude lists and the picker. The others are added
+// to the report as skipped.
+func (h *HomebrewUpdater) outdated(ctx context.Context, held []string, report *UpdateReport) (formulae, casks []OutdatedPackage, err error) {
+	cfg := h.ctx.Config.Homebrew
+
+	ui.PrintStep("Checking for outdated packages...")
+	outdated, err := LoadBrewOutdated(ctx, h.ctx.Executor, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Casks that update themselves are only upgraded when greedy
+	greedy := greedyCasks(cfg)
+	withGreedy, err := LoadBrewOutdated(ctx, h.ctx.Executor, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	outdated.Casks, report.Skipped = addGreedyCasks(outdated.Casks, withGreedy.Casks, cfg.Update.Greedy, greedy)
+
+	packages, skipped := selectUpgrades(append(outdated.Formulae, outdated.Casks...), h.Only, cfg.Update.Exclude, held)
+	for _, s := range skipped {
+		if s.Reason != SkipNotSelected {
+			ui.PrintInfo(fmt.Sprintf("Skipping %s (%s)", s.Name, s.Reason))
+		}
+	}
+	report.Skipped = append(skipped, report.Skipped...)
+
+	if h.Pick && len(packages) > 0 {
+		picked, err := h.pick(packages)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range packages {
+			if !containsPackage(picked, p.Name) {
+				report.Skipped = append(report.Skipped, SkippedPackage{Name: p.Name, Kind: packageKind(p), Reason: SkipNotSelected})
+			}
+		}
+		packages = picked
+	} else {
+		for _, p := range packages {
+			fmt.Printf("  %s\n", p)
+		}
+	}
+
+	for _, p := range packages {
+		if p.Cask {
+			casks = append(casks, p)
+		} else {
+			formulae = append(formulae, p)
+		}
+	}
+	return formulae, casks, nil
+}
+
+// pick lets the user deselect outdated packages
+func (h *HomebrewUpdater) pick(packages []OutdatedPackage) ([]OutdatedPackage, error) {
+	labels := make([]string, len(packages))
+	for i, p := range packages {
+		labels[i] = p.String()
+	}
+
+	chosen, err := h.ctx.Prompt.MultiSelect("Packages to upgrade", labels, labels)
+	if err != nil {
+		return nil, fmt.Errorf("package selection cancelled: %w", err)
+	}
+
+	keep := make(map[string]bool)
+	for _, label := range chosen {
+		keep[label] = true
+	}
+
+	var picked []OutdatedPackage
+	for _, p := range packages {
+		if keep[p.String()] {
+			picked = append(picked, p)
+		}
+	}
+	return picked, nil
+}
+
+// record adds the attempted upgrades to the report. Packages brew still
+// lists as outdated afterwards are reported as failed.
+func (h *HomebrewUpdater) record(ctx context.Context, report *UpdateReport, attempted []OutdatedPackage) {
+	var remaining []OutdatedPackage
+	if !h.ctx.DryRun {
+		if after, err := LoadBrewOutdated(ctx, h.ctx.Executor, true); err == nil {
+			remaining = append(after.Formulae, after.Casks...)
+		}
+	}
+
+	for _, p := range attempted {
+		if containsPackage(remaining, p.Name) {
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: p.Name, Kind: packageKind(p), Reason: SkipFailed})
+			continue
+		}
+		report.Upgraded = append(report.Upgraded, PackageChange{
+			Name: p.Name,
+			Kind: packageKind(p),
+			From: p.Installed(),
+			To:   p.CurrentVersion,
+		})
+	}
+}
+
+func containsPackage(packages []OutdatedPackage, name string) bool {
+	for _, p := range packages {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *HomebrewUpdater) upgradeFormulae(ctx context.Context, formulae []OutdatedPackage) error {
+	if len(formulae) == 0 {
+		return nil
+	}
+
+	ui.PrintStep("Upgrading formulae...")
+	spinner := ui.NewSpinner(fmt.Sprintf("Upgrading %d formulae...", len(formulae)))
+	spinner.Start()
+
+	args := append([]string{"upgrade", "--formula"}, outdatedNames(formulae)...)
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+	switch {
+	case err != nil:
+		spinner.Fail("Failed to upgrade formulae")
+		return fmt.Errorf("brew upgrade failed: %w\n%s", err, result.Stderr)
+	case result.DryRun:
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would upgrade %d formulae", len(formulae)))
+	default:
+		spinner.Success("Formulae upgraded")
+	}
 	return nil
 }
 
+func (h *HomebrewUpdater) upgradeCasks(ctx context.Context, casks []OutdatedPackage) {
+	if len(casks) == 0 {
+		return
+	}
+
+	ui.PrintStep("Upgrading casks...")
+	spinner := ui.NewSpinner(fmt.Sprintf("Upgrading %d casks...", len(casks)))
+	spinner.Start()
+
+	args := []string{"upgrade", "--cask"}
+	for _, c := range casks {
+		if c.Greedy {
+			args = append(args, "--greedy")
+			break
+		}
+	}
+	args = append(args, outdatedNames(casks)...)
+
+	result, err := h.ctx.Executor.Run(ctx, "brew", args...)
+	switch {
+	case err != nil:
+		// Cask upgrade failures are often non-critical (app already running, etc.)
+		spinner.Warning(fmt.Sprintf("Some casks may not have been upgraded: %s", result.Stderr))
+	case result.DryRun:
+		spinner.Info(fmt.Sprintf("[DRY-RUN] Would upgrade %d casks", len(casks)))
+	default:
+		spinner.Success("Casks upgraded")
+	}
+}
+
+func outdatedNames(packages []OutdatedPackage) []string {
+	names := make([]string, len(packages))
+	for i, p := range packages {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// greedyCasks returns the casks marked greedy in the config or Brewfile
+func greedyCasks(cfg config.HomebrewConfig) []string {
+	packages, err := BrewPackages(cfg)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, pkg := range packages.Casks {
+		if pkg.Greedy {
+			names = append(names, pkg.Name)
+		}
+	}
+	return names
+}
+
 // OhMyZshUpdater handles Oh My Zsh updates
 type OhMyZshUpdater struct {
 	ctx *Context
@@ -120,95 +328,152 @@ func (o *OhMyZshUpdater) Description() string {
 	return "Oh My Zsh Framework"
 }
 
-// Update updates Oh My Zsh
-func (o *OhMyZshUpdater) Update(ctx context.Context) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	omzDir := filepath.Join(homeDir, ".oh-my-zsh")
+// IsInstalled checks if Oh My Zsh is installed
+func (o *OhMyZshUpdater) IsInstalled(ctx context.Context) bool {
+	_, err := os.Stat(ohMyZshDir())
+	return err == nil
+}
 
-	// Check if Oh My Zsh is installed
-	if _, err := os.Stat(omzDir); os.IsNotExist(err) {
-		return fmt.Errorf("oh My Zsh is not installed")
+// Update updates the Oh My Zsh checkout
+func (o *OhMyZshUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	if !o.IsInstalled(ctx) {
+		return report, fmt.Errorf("oh My Zsh is not installed")
 	}
 
 	ui.PrintStep("Updating Oh My Zsh...")
+	repos := ohMyZshRepos(ohMyZshDir(), o.ctx.Config.Terminal.OhMyZsh.Update.Pins)
+	return report, updateCheckouts(ctx, o.ctx, repos[:1], report)
+}
 
-	if o.ctx.DryRun {
-		ui.PrintDryRun(fmt.Sprintf("cd %s && git pull", omzDir))
-		return nil
-	}
-
-	// Update using git pull
-	spinner := ui.NewSpinner("Pulling latest changes...")
-	spinner.Start()
+// OhMyZshPluginsUpdater updates the git checkouts in the Oh My Zsh custom
+// plugins and themes directories, except Powerlevel10k
+type OhMyZshPluginsUpdater struct {
+	ctx *Context
+}
 
-	// Save current directory and change to omz dir
-	result, err := o.ctx.Executor.Run(ctx, "git", "-C", omzDir, "pull", "--rebase", "--stat", "origin", "master")
-	if err != nil {
-		spinner.Fail("Failed to update Oh My Zsh")
-		return fmt.Errorf("git pull failed: %w\n%s", err, result.Stderr)
-	}
+// NewOhMyZshPluginsUpdater creates a new custom plugin and theme updater
+func NewOhMyZshPluginsUpdater(ctx *Context) *OhMyZshPluginsUpdater {
+	return &OhMyZshPluginsUpdater{ctx: ctx}
+}
 
-	spinner.Success("Oh My Zsh updated")
+// Name returns the updater name
+func (o *OhMyZshPluginsUpdater) Name() string {
+	return "plugins"
+}
 
-	// Update custom plugins if any
-	customPluginsDir := filepath.Join(omzDir, "custom", "plugins")
-	if entries, err := os.ReadDir(customPluginsDir); err == nil {
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
+// Description returns the updater description
+func (o *OhMyZshPluginsUpdater) Description() string {
+	return "Oh My Zsh Custom Plugins and Themes"
+}
 
-			pluginDir := filepath.Join(customPluginsDir, entry.Name())
-			gitDir := filepath.Join(pluginDir, ".git")
+// IsInstalled checks for git-cloned plugins or themes
+func (o *OhMyZshPluginsUpdater) IsInstalled(ctx context.Context) bool {
+	return len(o.repos()) > 0
+}
 
-			if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-				continue // Not a git repo
-			}
+// Update updates the custom plugin and theme checkouts
+func (o *OhMyZshPluginsUpdater) Update(ctx context.Context) (*UpdateReport, error) {
+	report := &UpdateReport{}
+	repos := o.repos()
+	if len(repos) == 0 {
+		ui.PrintInfo("No git-cloned plugins or themes")
+		return report, nil
+	}
 
-			spinner := ui.NewSpinner(fmt.Sprintf("Updating plugin: %s...", entry.Name()))
-			spinner.Start()
+	ui.PrintStep("Updating custom plugins and themes...")
+	return report, updateCheckouts(ctx, o.ctx, repos, report)
+}
 
-			result, err := o.ctx.Executor.Run(ctx, "git", "-C", pluginDir, "pull", "--rebase")
-			if err != nil {
-				spinner.Warning(fmt.Sprintf("Failed to update plugin %s", entry.Name()))
-			} else {
-				_ = result
-				spinner.Success(fmt.Sprintf("Updated plugin: %s", entry.Name()))
-			}
+func (o *OhMyZshPluginsUpdater) repos() []ohMyZshRepo {
+	var repos []ohMyZshRepo
+	for _, repo := range ohMyZshRepos(ohMyZshDir(), o.ctx.Config.Terminal.OhMyZsh.Update.Pins)[1:] {
+		// Updated by the powerlevel10k updater
+		if repo.Kind == "theme" && repo.Name == "powerlevel10k" {
+			continue
 		}
+		repos = append(repos, repo)
 	}
+	return repos
+}
 
-	// Update custom themes (like powerlevel10k)
-	customThemesDir := filepath.Join(omzDir, "custom", "themes")
-	if entries, err := os.ReadDir(customThemesDir); err == nil {
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
+// updateCheckouts updates each checkout with the configured local changes
+// policy. Oh My Zsh failing and the abort policy stop the update; other
+// failures are reported as skipped.
+func updateCheckouts(ctx context.Context, ictx *Context, repos []ohMyZshRepo, report *UpdateReport) error {
+	cfg := ictx.Config.Terminal.OhMyZsh.Update
+	updater := NewGitRepoUpdater(ictx.Executor, cfg.LocalChanges)
+
+	for _, repo := range repos {
+		if ictx.DryRun {
+			if _, err := updater.Update(ctx, repo.GitRepo); err != nil {
+				ui.PrintWarning(fmt.Sprintf("%s: %v", repo.Name, err))
 			}
+			continue
+		}
 
-			themeDir := filepath.Join(customThemesDir, entry.Name())
-			gitDir := filepath.Join(themeDir, ".git")
+		spinner := ui.NewSpinner(fmt.Sprintf("Updating %s: %s...", repo.Kind, repo.Name))
+		spinner.Start()
 
-			if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-				continue // Not a git repo
-			}
+		change, err := updater.Update(ctx, repo.GitRepo)
+		if change != nil {
+			report.Repos = append(report.Repos, *change)
+		}
+		switch {
+		case errors.Is(err, ErrLocalChanges) && cfg.LocalChanges == config.LocalChangesAbort:
+			spinner.Fail(fmt.Sprintf("%s has local changes", repo.Path))
+			return fmt.Errorf("%s has local changes (terminal.oh_my_zsh.update.local_changes: abort)", repo.Path)
+		case errors.Is(err, ErrLocalChanges):
+			spinner.Warning(fmt.Sprintf("Skipped %s %s: local changes", repo.Kind, repo.Name))
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: repo.Name, Kind: repo.Kind, Reason: SkipLocalChanges})
+		case errors.Is(err, ErrStashNotRestored):
+			spinner.Warning(fmt.Sprintf("Updated %s %s, but %v", repo.Kind, repo.Name, err))
+		case err != nil && repo.Kind == "oh-my-zsh":
+			spinner.Fail("Failed to update Oh My Zsh")
+			return err
+		case err != nil:
+			spinner.Warning(fmt.Sprintf("Failed to update %s %s", repo.Kind, repo.Name))
+			report.Skipped = append(report.Skipped, SkippedPackage{Name: repo.Name, Kind: repo.Kind, Reason: SkipFailed})
+		case change != nil:
+			spinner.Success(fmt.Sprintf("Updated %s: %s (%d commits)", repo.Kind, repo.Name, change.Commits))
+		case repo.Ref != "":
+			spinner.Success(fmt.Sprintf("Up to date: %s (pinned to %s)", repo.Name, repo.Ref))
+		default:
+			spinner.Success(fmt.Sprintf("Up to date: %s", repo.Name))
+		}
+	}
+	return nil
+}
 
-			spinner := ui.NewSpinner(fmt.Sprintf("Updating theme: %s...", entry.Name()))
-			spinner.Start()
+// ohMyZshDir returns ~/.oh-my-zsh
+func ohMyZshDir() string {
+	return expandHome("~/.oh-my-zsh")
+}
 
-			result, err := o.ctx.Executor.Run(ctx, "git", "-C", themeDir, "pull", "--rebase")
-			if err != nil {
-				spinner.Warning(fmt.Sprintf("Failed to update theme %s", entry.Name()))
-			} else {
-				_ = result
-				spinner.Success(fmt.Sprintf("Updated theme: %s", entry.Name()))
+// ohMyZshRepo is Oh My Zsh or one of its custom plugin or theme checkouts
+type ohMyZshRepo struct {
+	GitRepo
+	// Kind is oh-my-zsh, plugin or theme
+	Kind string
+}
+
+// ohMyZshRepos lists Oh My Zsh and the git checkouts in its custom plugins
+// and themes directories, with their pins
+func ohMyZshRepos(omzDir string, pins map[string]string) []ohMyZshRepo {
+	repos := []ohMyZshRepo{{GitRepo: GitRepo{Name: "oh-my-zsh", Path: omzDir, Ref: pins["oh-my-zsh"]}, Kind: "oh-my-zsh"}}
+	for _, kind := range []string{"plugin", "theme"} {
+		customDir := filepath.Join(omzDir, "custom", kind+"s")
+		entries, err := os.ReadDir(customDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			dir := filepath.Join(customDir, entry.Name())
+			if _, err := os.Stat(filepath.Join(dir, ".git")); !entry.IsDir() || err != nil {
+				continue // Not a git repo
 			}
+			repos = append(repos, ohMyZshRepo{GitRepo: GitRepo{Name: entry.Name(), Path: dir, Ref: pins[entry.Name()]}, Kind: kind})
 		}
 	}
-
-	return nil
+	return repos
 }
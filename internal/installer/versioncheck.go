@@ -16,6 +16,9 @@ const (
 	githubRepoOwner = "tldr-it-stepankutaj"
 	githubRepoName  = "setup-mac"
 	githubAPIURL    = "https://api.github.com/repos/%s/%s/releases/latest"
+
+	// releaseChecksums is the release asset with the sha256 of each tarball
+	releaseChecksums = "checksums.txt"
 )
 
 // GitHubRelease represents a GitHub release
@@ -123,6 +126,17 @@ func (v *VersionChecker) GetDownloadURL(release *GitHubRelease) string {
 	return release.HTMLURL
 }
 
+// GetChecksumsURL returns the download URL of the release's checksums
+// file, or "" when the release has none
+func (v *VersionChecker) GetChecksumsURL(release *GitHubRelease) string {
+	for _, asset := range release.Assets {
+		if asset.Name == releaseChecksums {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return ""
+}
+
 // CheckAndPrompt checks for updates and prompts user if available
 func (v *VersionChecker) CheckAndPrompt(ctx context.Context, prompt *ui.Prompt) error {
 	release, isNewer, err := v.CheckForUpdate(ctx)
@@ -0,0 +1,250 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/jsonc"
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/ui"
+)
+
+// editor describes a VS Code compatible editor
+type editor struct {
+	name string
+	cask string
+	// support is the directory in ~/Library/Application Support
+	support string
+}
+
+var editors = map[string]editor{
+	config.EditorCode:   {name: "VS Code", cask: "visual-studio-code", support: "Code"},
+	config.EditorCursor: {name: "Cursor", cask: "cursor", support: "Cursor"},
+	config.EditorCodium: {name: "VSCodium", cask: "vscodium", support: "VSCodium"},
+}
+
+// VSCodeSettingsPath returns the user settings.json of an editor
+func VSCodeSettingsPath(name string) string {
+	return expandHome(filepath.Join("~/Library/Application Support", editors[name].support, "User", "settings.json"))
+}
+
+// VSCodeInstaller installs editor extensions and merges user settings for
+// VS Code, Cursor or VSCodium
+type VSCodeInstaller struct {
+	ctx *Context
+}
+
+// NewVSCodeInstaller creates a new VS Code installer
+func NewVSCodeInstaller(ctx *Context) *VSCodeInstaller {
+	return &VSCodeInstaller{ctx: ctx}
+}
+
+// Name returns the installer name
+func (v *VSCodeInstaller) Name() string {
+	return "vscode"
+}
+
+// Description returns the installer description
+func (v *VSCodeInstaller) Description() string {
+	if e, ok := editors[v.ctx.Config.VSCode.Editor]; ok {
+		return e.name + " Extensions and Settings"
+	}
+	return "VS Code Extensions and Settings"
+}
+
+// IsInstalled checks if every extension is installed and the settings are
+// merged
+func (v *VSCodeInstaller) IsInstalled(ctx context.Context) bool {
+	cfg := v.ctx.Config.VSCode
+	if cfg.Editor == "" || (len(cfg.Extensions) == 0 && len(cfg.Settings) == 0) {
+		return true
+	}
+	if !v.ctx.Executor.Exists(cfg.Editor) {
+		return false
+	}
+
+	states, err := ExtensionStates(ctx, v.ctx.Executor, cfg)
+	if err != nil {
+		return false
+	}
+	for _, state := range states {
+		if state.Missing() || state.Mismatched() {
+			return false
+		}
+	}
+	changes, err := SettingsChanges(cfg)
+	return err == nil && len(changes) == 0
+}
+
+// Install installs the missing extensions and merges the settings
+func (v *VSCodeInstaller) Install(ctx context.Context) error {
+	cfg := v.ctx.Config.VSCode
+	e, ok := editors[cfg.Editor]
+	if !ok {
+		ui.PrintInfo("No editor configured")
+		return nil
+	}
+	if !v.ctx.Executor.Exists(cfg.Editor) && !v.ctx.DryRun {
+		return fmt.Errorf("%s is not on PATH, install the %s cask", cfg.Editor, e.cask)
+	}
+
+	failed := 0
+	if len(cfg.Extensions) > 0 {
+		ui.PrintStep("Installing extensions...")
+		failed = v.installExtensions(ctx, cfg)
+	}
+
+	if len(cfg.Settings) > 0 {
+		ui.PrintStep("Merging settings...")
+		if err := v.mergeSettings(cfg); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d extension(s) failed to install", failed)
+	}
+	return nil
+}
+
+// installExtensions installs the extensions that are missing or not at
+// their pinned version and returns how many failed
+func (v *VSCodeInstaller) installExtensions(ctx context.Context, cfg config.VSCodeConfig) int {
+	states, err := ExtensionStates(ctx, v.ctx.Executor, cfg)
+	if err != nil {
+		ui.PrintWarning(err.Error())
+		return len(cfg.Extensions)
+	}
+
+	failed := 0
+	for _, state := range states {
+		if !state.Missing() && !state.Mismatched() {
+			ui.PrintInfo(fmt.Sprintf("Extension already installed: %s %s", state.Name, state.Installed))
+			continue
+		}
+
+		spec := PackageSpec{Name: state.Name, Version: state.Wanted}
+		args := []string{"--install-extension", spec.String()}
+		if state.Mismatched() {
+			args = append(args, "--force")
+		}
+
+		spinner := ui.NewSpinner(fmt.Sprintf("Installing extension: %s", spec))
+		spinner.Start()
+		result, err := v.ctx.Executor.Run(ctx, cfg.Editor, args...)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to install extension: %s", spec))
+			failed++
+			continue
+		}
+		if result.DryRun {
+			spinner.Info(fmt.Sprintf("[DRY-RUN] Would install extension: %s", spec))
+		} else {
+			spinner.Success(fmt.Sprintf("Installed extension: %s", spec))
+		}
+	}
+	return failed
+}
+
+// mergeSettings sets the configured keys in settings.json, keeping the
+// user's other keys and comments
+func (v *VSCodeInstaller) mergeSettings(cfg config.VSCodeConfig) error {
+	path := VSCodeSettingsPath(cfg.Editor)
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	merged, changed, err := jsonc.Merge(content, cfg.Settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(changed) == 0 {
+		ui.PrintInfo("Settings already up to date")
+		return nil
+	}
+
+	if v.ctx.DryRun {
+		ui.PrintDryRun(fmt.Sprintf("Would set %s in %s", strings.Join(changed, ", "), path))
+		return nil
+	}
+
+	if v.ctx.Config.Settings.BackupDotfiles && len(content) > 0 {
+		backupPath := fmt.Sprintf("%s.backup.%s", path, time.Now().Format("20060102_150405"))
+		if err := os.WriteFile(backupPath, content, 0644); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to backup settings.json: %v", err))
+		} else {
+			ui.PrintInfo(fmt.Sprintf("Backed up %s to %s", path, backupPath))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, merged, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Set %d setting(s) in %s", len(changed), path))
+	return nil
+}
+
+// ExtensionStates reports each configured extension. Without the editor
+// nothing counts as installed.
+func ExtensionStates(ctx context.Context, exec *executor.Executor, cfg config.VSCodeConfig) ([]PackageState, error) {
+	installed := map[string]string{}
+	if len(cfg.Extensions) > 0 && exec.Exists(cfg.Editor) {
+		result, err := exec.Query(ctx, cfg.Editor, "--list-extensions", "--show-versions")
+		if err != nil {
+			return nil, fmt.Errorf("%s --list-extensions failed: %w", cfg.Editor, err)
+		}
+		installed = parseExtensionList(result.Stdout)
+	}
+
+	var states []PackageState
+	for _, entry := range cfg.Extensions {
+		spec := ParsePackageSpec(entry)
+		if spec.Name == "" {
+			continue
+		}
+		state := PackageState{Ecosystem: cfg.Editor, Name: spec.Name, Installed: installed[strings.ToLower(spec.Name)]}
+		if spec.Pinned() {
+			state.Wanted = spec.Version
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// parseExtensionList reads --list-extensions --show-versions output, keyed
+// by lower-case ID as extension IDs are case-insensitive:
+//
+//	golang.go@0.41.2
+func parseExtensionList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		id, version, _ := strings.Cut(strings.TrimSpace(line), "@")
+		if strings.Contains(id, ".") {
+			installed[strings.ToLower(id)] = version
+		}
+	}
+	return installed
+}
+
+// SettingsChanges returns the configured settings whose value differs
+// from settings.json, sorted
+func SettingsChanges(cfg config.VSCodeConfig) ([]string, error) {
+	if len(cfg.Settings) == 0 {
+		return nil, nil
+	}
+	content, err := os.ReadFile(VSCodeSettingsPath(cfg.Editor))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	_, changed, err := jsonc.Merge(content, cfg.Settings)
+	return changed, err
+}
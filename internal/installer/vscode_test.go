@@ -0,0 +1,111 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/config"
+)
+
+func TestParseExtensionList(t *testing.T) {
+	got := parseExtensionList("golang.Go@0.41.2\nms-python.python@2024.2.1\n\nWarning: something\n")
+	want := map[string]string{"golang.go": "0.41.2", "ms-python.python": "2024.2.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestVSCodeSettingsPath(t *testing.T) {
+	t.Setenv("HOME", "/Users/dev")
+	for editor, want := range map[string]string{
+		config.EditorCode:   "/Users/dev/Library/Application Support/Code/User/settings.json",
+		config.EditorCursor: "/Users/dev/Library/Application Support/Cursor/User/settings.json",
+		config.EditorCodium: "/Users/dev/Library/Application Support/VSCodium/User/settings.json",
+	} {
+		if got := VSCodeSettingsPath(editor); got != want {
+			t.Errorf("%s: got %s, want %s", editor, got, want)
+		}
+	}
+}
+
+func TestVSCodeInstall(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	fakeBinary(t, bin, "cursor", `
+echo "$*" >> "`+calls+`"
+if [ "$1" = "--list-extensions" ]; then
+  echo "golang.go@0.40.0"
+  echo "EditorConfig.EditorConfig@0.16.4"
+fi
+`)
+
+	settingsPath := VSCodeSettingsPath(config.EditorCursor)
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, settingsPath, "{\n    // mine\n    \"editor.fontSize\": 13,\n    \"window.zoomLevel\": 1\n}\n")
+
+	cfg := &config.Config{}
+	cfg.VSCode = config.VSCodeConfig{
+		Editor:     config.EditorCursor,
+		Extensions: []string{"golang.go@0.41.2", "editorconfig.editorconfig", "ms-python.python"},
+		Settings:   map[string]any{"editor.fontSize": 14, "editor.formatOnSave": true},
+	}
+	v := NewVSCodeInstaller(NewContext(cfg, false, false))
+	ctx := context.Background()
+
+	if v.Description() != "Cursor Extensions and Settings" {
+		t.Errorf("unexpected description: %s", v.Description())
+	}
+	if v.IsInstalled(ctx) {
+		t.Error("expected missing extensions to be reported")
+	}
+	if err := v.Install(ctx); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{"--install-extension golang.go@0.41.2 --force", "--install-extension ms-python.python"} {
+		if !strings.Contains(string(log), want+"\n") {
+			t.Errorf("expected cursor %s, got:\n%s", want, log)
+		}
+	}
+	if strings.Contains(string(log), "--install-extension editorconfig") {
+		t.Errorf("installed an extension that is already there:\n%s", log)
+	}
+
+	settings, _ := os.ReadFile(settingsPath)
+	want := "{\n    // mine\n    \"editor.fontSize\": 14,\n    \"window.zoomLevel\": 1,\n    \"editor.formatOnSave\": true\n}\n"
+	if string(settings) != want {
+		t.Errorf("unexpected settings.json:\n%s", settings)
+	}
+	if changes, err := SettingsChanges(cfg.VSCode); err != nil || len(changes) != 0 {
+		t.Errorf("expected settings to be merged, got %v, %v", changes, err)
+	}
+}
+
+func TestVSCodeInstallWithoutEditor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+
+	cfg := &config.Config{}
+	cfg.VSCode = config.VSCodeConfig{Editor: config.EditorCode, Settings: map[string]any{"editor.fontSize": 14}}
+	if err := NewVSCodeInstaller(NewContext(cfg, false, false)).Install(context.Background()); err == nil {
+		t.Error("expected an error without code on PATH")
+	}
+
+	// Dry-run reports the settings but writes nothing
+	if err := NewVSCodeInstaller(NewContext(cfg, true, false)).Install(context.Background()); err != nil {
+		t.Fatalf("dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(VSCodeSettingsPath(config.EditorCode)); !os.IsNotExist(err) {
+		t.Errorf("dry-run wrote settings.json: %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+// Package jsonc edits JSON with comments, as used by VS Code settings files,
+// without reformatting the parts it does not change.
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// member is a top-level key and the byte range of its value
+type member struct {
+	key string
+	// offset is where the key starts
+	offset     int
+	start, end int
+	// comma is set when the value is followed by a comma
+	comma bool
+}
+
+// object is the parsed top level of a document
+type object struct {
+	members []member
+	// close is the offset of the closing brace
+	close int
+}
+
+// Merge sets the top-level keys of the JSONC document in content to
+// settings. Other keys, comments and formatting are kept; values that
+// already match are left untouched. It returns the new document and the
+// keys that changed, sorted.
+func Merge(content []byte, settings map[string]any) ([]byte, []string, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		content = []byte("{}\n")
+	}
+	obj, err := parse(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make(map[string]member)
+	for _, m := range obj.members {
+		existing[m.key] = m
+	}
+
+	var replaced, added []string
+	for key, value := range settings {
+		m, ok := existing[key]
+		if !ok {
+			added = append(added, key)
+			continue
+		}
+		if !equal(content[m.start:m.end], value) {
+			replaced = append(replaced, key)
+		}
+	}
+	if len(replaced) == 0 && len(added) == 0 {
+		return content, nil, nil
+	}
+	sort.Strings(added)
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, key := range replaced {
+		m := existing[key]
+		text, err := marshal(settings[key], lineIndent(content, m.start))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		edits = append(edits, edit{m.start, m.end, text})
+	}
+
+	if len(added) > 0 {
+		// Insert after whatever precedes the closing brace, comments included
+		at := obj.close
+		for at > 0 && isSpace(content[at-1]) {
+			at--
+		}
+
+		indent, prefix := "    ", ""
+		if len(obj.members) > 0 {
+			indent = lineIndent(content, obj.members[0].offset)
+			if last := obj.members[len(obj.members)-1]; !last.comma {
+				if last.end == at {
+					prefix = ","
+				} else {
+					edits = append(edits, edit{last.end, last.end, ","})
+				}
+			}
+		}
+
+		lines := make([]string, len(added))
+		for i, key := range added {
+			name, _ := json.Marshal(key)
+			text, err := marshal(settings[key], indent)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+			lines[i] = "\n" + indent + string(name) + ": " + text
+		}
+		edits = append(edits, edit{at, obj.close, prefix + strings.Join(lines, ",") + "\n"})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), content...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+
+	changed := append(replaced, added...)
+	sort.Strings(changed)
+	return out, changed, nil
+}
+
+// Get returns the top-level values of a JSONC document. Values that
+// contain comments are skipped.
+func Get(content []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	if len(bytes.TrimSpace(content)) == 0 {
+		return values, nil
+	}
+	obj, err := parse(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range obj.members {
+		var value any
+		if err := json.Unmarshal(content[m.start:m.end], &value); err == nil {
+			values[m.key] = value
+		}
+	}
+	return values, nil
+}
+
+// equal reports whether a raw JSON value matches a config value. Both are
+// compared in their JSON form, so 14 and 14.0 are equal.
+func equal(raw []byte, value any) bool {
+	var current any
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return false
+	}
+	a, errA := json.Marshal(current)
+	b, errB := json.Marshal(value)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// marshal renders a value indented to continue a line at indent
+func marshal(value any, indent string) (string, error) {
+	data, err := json.MarshalIndent(value, indent, "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// lineIndent returns the whitespace at the start of the line containing
+// offset
+func lineIndent(content []byte, offset int) string {
+	start := bytes.LastIndexByte(content[:offset], '\n') + 1
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[start:end])
+}
+
+// parse finds the top-level members of a JSONC object
+func parse(content []byte) (*object, error) {
+	p := &parser{data: content}
+	p.skip()
+	if !p.consume('{') {
+		return nil, p.errorf("expected {")
+	}
+
+	obj := &object{}
+	for {
+		p.skip()
+		if p.consume('}') {
+			obj.close = p.pos - 1
+			break
+		}
+
+		if p.peek() != '"' {
+			return nil, p.errorf("expected a key")
+		}
+		keyStart := p.pos
+		if err := p.skipString(); err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal(content[keyStart:p.pos], &key); err != nil {
+			return nil, p.errorf("invalid key")
+		}
+
+		p.skip()
+		if !p.consume(':') {
+			return nil, p.errorf("expected :")
+		}
+		p.skip()
+
+		m := member{key: key, offset: keyStart, start: p.pos}
+		if err := p.skipValue(); err != nil {
+			return nil, err
+		}
+		m.end = p.pos
+
+		p.skip()
+		m.comma = p.consume(',')
+		if !m.comma && p.peek() != '}' {
+			return nil, p.errorf("expected , or }")
+		}
+		obj.members = append(obj.members, m)
+	}
+
+	p.skip()
+	if p.pos != len(content) {
+		return nil, p.errorf("unexpected content after the object")
+	}
+	return obj, nil
+}
+
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) errorf(msg string) error {
+	line := bytes.Count(p.data[:p.pos], []byte("\n")) + 1
+	return fmt.Errorf("invalid JSON on line %d: %s", line, msg)
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skip moves past whitespace and // and /* */ comments
+func (p *parser) skip() {
+	for p.pos < len(p.data) {
+		switch {
+		case isSpace(p.data[p.pos]):
+			p.pos++
+		case bytes.HasPrefix(p.data[p.pos:], []byte("//")):
+			if end := bytes.IndexByte(p.data[p.pos:], '\n'); end >= 0 {
+				p.pos += end
+			} else {
+				p.pos = len(p.data)
+			}
+		case bytes.HasPrefix(p.data[p.pos:], []byte("/*")):
+			if end := bytes.Index(p.data[p.pos+2:], []byte("*/")); end >= 0 {
+				p.pos += end + 4
+			} else {
+				p.pos = len(p.data)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) skipString() error {
+	for p.pos++; p.pos < len(p.data); p.pos++ {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			return nil
+		case '\n':
+			return p.errorf("unterminated string")
+		}
+	}
+	return p.errorf("unterminated string")
+}
+
+// skipValue moves past a string, number, literal, array or object,
+// including comments and trailing commas inside it
+func (p *parser) skipValue() error {
+	switch c := p.peek(); c {
+	case '"':
+		return p.skipString()
+	case '{', '[':
+		depth := 0
+		for p.pos < len(p.data) {
+			p.skip()
+			switch p.peek() {
+			case '"':
+				if err := p.skipString(); err != nil {
+					return err
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			p.pos++
+			if depth == 0 {
+				return nil
+			}
+		}
+		return p.errorf("unterminated " + string(c))
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !bytes.ContainsAny(p.data[p.pos:p.pos+1], ",}]/") {
+			p.pos++
+		}
+		if p.pos == start {
+			return p.errorf("expected a value")
+		}
+		return nil
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,99 @@
+package jsonc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const settingsJSON = `// User settings
+{
+    // Font
+    "editor.fontSize": 13,
+    "editor.fontFamily": "Menlo", /* keep */
+    "[python]": {
+        "editor.tabSize": 4, // trailing comment
+    },
+    "files.exclude": {"**/.git": true},
+    "workbench.colorTheme": "Default Dark+" // last
+}
+`
+
+func TestMergeReplacesAndAdds(t *testing.T) {
+	got, changed, err := Merge([]byte(settingsJSON), map[string]any{
+		"editor.fontSize":     14,
+		"editor.fontFamily":   "Menlo",
+		"editor.formatOnSave": true,
+		"files.exclude":       map[string]any{"**/.git": true, "**/node_modules": true},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	want := `// User settings
+{
+    // Font
+    "editor.fontSize": 14,
+    "editor.fontFamily": "Menlo", /* keep */
+    "[python]": {
+        "editor.tabSize": 4, // trailing comment
+    },
+    "files.exclude": {
+        "**/.git": true,
+        "**/node_modules": true
+    },
+    "workbench.colorTheme": "Default Dark+", // last
+    "editor.formatOnSave": true
+}
+`
+	if string(got) != want {
+		t.Errorf("unexpected document:\n%s", got)
+	}
+	if !reflect.DeepEqual(changed, []string{"editor.fontSize", "editor.formatOnSave", "files.exclude"}) {
+		t.Errorf("unexpected changed keys: %v", changed)
+	}
+
+	// Merging again changes nothing
+	again, changed, err := Merge(got, map[string]any{"editor.fontSize": 14.0, "editor.formatOnSave": true})
+	if err != nil || len(changed) != 0 || string(again) != string(got) {
+		t.Errorf("expected no change, got %v, %v:\n%s", changed, err, again)
+	}
+}
+
+func TestMergeEmptyDocuments(t *testing.T) {
+	for _, input := range []string{"", "{}", "{\n}\n", `{"a":1}`, "{\n    \"a\": 1,\n}\n"} {
+		got, changed, err := Merge([]byte(input), map[string]any{"b": "x"})
+		if err != nil || len(changed) != 1 {
+			t.Errorf("%q: unexpected result %v, %v", input, changed, err)
+			continue
+		}
+		values, err := Get(got)
+		if err != nil || values["b"] != "x" {
+			t.Errorf("%q: merged document does not parse: %v\n%s", input, err, got)
+		}
+		if input != "" && input != "{}" && input != "{\n}\n" && values["a"] != 1.0 {
+			t.Errorf("%q: lost existing key:\n%s", input, got)
+		}
+	}
+}
+
+func TestMergeInvalid(t *testing.T) {
+	for _, input := range []string{"[]", `{"a": }`, `{"a": 1`, `{"a": 1 "b": 2}`, `{"a": "x}`, `{} {}`} {
+		if _, _, err := Merge([]byte(input), map[string]any{"b": 1}); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	values, err := Get([]byte(settingsJSON))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if values["editor.fontSize"] != 13.0 || values["workbench.colorTheme"] != "Default Dark+" {
+		t.Errorf("unexpected values: %v", values)
+	}
+	// Values with comments inside can't be read
+	if _, ok := values["[python]"]; ok {
+		t.Errorf("expected [python] to be skipped: %v", values)
+	}
+}
@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// PlistPath returns the LaunchAgent file for a label
+func PlistPath(label string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, "Library", "LaunchAgents", label+".plist"), nil
+}
+
+// domain is the launchd domain of the logged-in user
+func domain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+// Enable writes the job's plist and loads it, replacing a loaded version
+func Enable(ctx context.Context, exec *executor.Executor, job Job) (string, error) {
+	data, err := Plist(job)
+	if err != nil {
+		return "", err
+	}
+	path, err := PlistPath(job.Label)
+	if err != nil {
+		return "", err
+	}
+
+	if !exec.DryRun {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if job.LogFile != "" {
+			if err := os.MkdirAll(filepath.Dir(job.LogFile), 0755); err != nil {
+				return "", fmt.Errorf("failed to create log directory: %w", err)
+			}
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	// bootstrap fails if an older version is still loaded
+	if loaded(ctx, exec, job.Label) {
+		if _, err := exec.Run(ctx, "launchctl", "bootout", domain()+"/"+job.Label); err != nil {
+			return path, fmt.Errorf("failed to unload the previous agent: %w", err)
+		}
+	}
+	if result, err := exec.Run(ctx, "launchctl", "bootstrap", domain(), path); err != nil {
+		return path, fmt.Errorf("launchctl bootstrap failed: %w\n%s", err, result.Stderr)
+	}
+	return path, nil
+}
+
+// Disable unloads the agent and removes its plist
+func Disable(ctx context.Context, exec *executor.Executor, label string) error {
+	path, err := PlistPath(label)
+	if err != nil {
+		return err
+	}
+
+	if loaded(ctx, exec, label) {
+		if result, err := exec.Run(ctx, "launchctl", "bootout", domain()+"/"+label); err != nil {
+			return fmt.Errorf("launchctl bootout failed: %w\n%s", err, result.Stderr)
+		}
+	}
+
+	if exec.DryRun {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// Status describes the scheduled agent
+type Status struct {
+	Label     string `json:"label"`
+	Plist     string `json:"plist"`
+	Installed bool   `json:"installed"`
+	Loaded    bool   `json:"loaded"`
+	// State and LastExitCode are reported by launchctl print
+	State        string `json:"state,omitempty"`
+	LastExitCode string `json:"last_exit_code,omitempty"`
+	LogFile      string `json:"log_file,omitempty"`
+}
+
+// GetStatus reports whether the agent is installed and loaded
+func GetStatus(ctx context.Context, exec *executor.Executor, label string) Status {
+	status := Status{Label: label}
+	if path, err := PlistPath(label); err == nil {
+		status.Plist = path
+		_, err := os.Stat(path)
+		status.Installed = err == nil
+	}
+
+	result, err := exec.Query(ctx, "launchctl", "print", domain()+"/"+label)
+	if err != nil {
+		return status
+	}
+	status.Loaded = true
+	info := parseLaunchctlPrint(result.Stdout)
+	status.State = info["state"]
+	status.LastExitCode = info["last exit code"]
+	status.LogFile = info["stdout path"]
+	return status
+}
+
+func loaded(ctx context.Context, exec *executor.Executor, label string) bool {
+	_, err := exec.Query(ctx, "launchctl", "print", domain()+"/"+label)
+	return err == nil
+}
+
+// parseLaunchctlPrint reads the top-level "key = value" lines of
+// launchctl print output
+func parseLaunchctlPrint(output string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		// Top-level properties are indented by one tab
+		if !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if ok {
+			info[key] = value
+		}
+	}
+	return info
+}
@@ -0,0 +1,178 @@
+// Package schedule generates and manages the launchd agent that runs
+// setup-mac update in the background
+package schedule
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Label identifies the update agent in launchd
+const Label = "com.github.tldr-it-stepankutaj.setup-mac.update"
+
+// Update intervals
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Job describes a scheduled setup-mac run
+type Job struct {
+	Label string
+	// Program is the executable followed by its arguments
+	Program  []string
+	Interval string
+	// Weekday is used for weekly jobs
+	Weekday time.Weekday
+	Hour    int
+	Minute  int
+	// LogFile receives stdout and stderr
+	LogFile string
+	// Path is the job's PATH; launchd's default lacks Homebrew
+	Path string
+}
+
+// Validate checks the interval and time
+func (j Job) Validate() error {
+	if j.Label == "" || len(j.Program) == 0 {
+		return fmt.Errorf("job needs a label and a program")
+	}
+	if j.Interval != Daily && j.Interval != Weekly {
+		return fmt.Errorf("invalid interval: %s (valid: daily, weekly)", j.Interval)
+	}
+	if j.Hour < 0 || j.Hour > 23 || j.Minute < 0 || j.Minute > 59 {
+		return fmt.Errorf("invalid time: %02d:%02d", j.Hour, j.Minute)
+	}
+	if j.Weekday < time.Sunday || j.Weekday > time.Saturday {
+		return fmt.Errorf("invalid weekday: %d", j.Weekday)
+	}
+	return nil
+}
+
+var plistTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Program}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+{{- if eq .Interval "weekly"}}
+		<key>Weekday</key>
+		<integer>{{printf "%d" .Weekday}}</integer>
+{{- end}}
+		<key>Hour</key>
+		<integer>{{.Hour}}</integer>
+		<key>Minute</key>
+		<integer>{{.Minute}}</integer>
+	</dict>
+{{- if .Path}}
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>{{xml .Path}}</string>
+	</dict>
+{{- end}}
+{{- if .LogFile}}
+	<key>StandardOutPath</key>
+	<string>{{xml .LogFile}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogFile}}</string>
+{{- end}}
+	<key>ProcessType</key>
+	<string>Background</string>
+	<key>RunAtLoad</key>
+	<false/>
+</dict>
+</plist>
+`))
+
+// Plist renders the LaunchAgent property list for the job
+func Plist(job Job) ([]byte, error) {
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := plistTemplate.Execute(&buf, job); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// ParseClock parses a HH:MM time of day
+func ParseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// ParseWeekday parses a weekday name such as mon or Monday
+func ParseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday: %s", s)
+}
+
+// QuietHours is a daily window in which scheduled updates do not run. The
+// window may wrap past midnight, e.g. 22:00-07:00.
+type QuietHours struct {
+	// Start and End are minutes since midnight
+	Start int
+	End   int
+}
+
+// ParseQuietHours parses a HH:MM-HH:MM window
+func ParseQuietHours(s string) (QuietHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q (expected HH:MM-HH:MM)", s)
+	}
+
+	startHour, startMinute, err := ParseClock(from)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	endHour, endMinute, err := ParseClock(to)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	return QuietHours{Start: startHour*60 + startMinute, End: endHour*60 + endMinute}, nil
+}
+
+// Contains reports whether t falls in the window
+func (q QuietHours) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if q.Start <= q.End {
+		return minute >= q.Start && minute < q.End
+	}
+	return minute >= q.Start || minute < q.End
+}
+
+// String formats the window as HH:MM-HH:MM
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.Start/60, q.Start%60, q.End/60, q.End%60)
+}
@@ -0,0 +1,194 @@
+package schedule
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func testJob() Job {
+	return Job{
+		Label: Label,
+		Program: []string{
+			"/usr/local/bin/setup-mac", "update", "--all", "--exclude-component", "self", "--non-interactive",
+			"--skip-on-battery", "--quiet-hours", "22:00-07:00",
+			"--config", "/Users/dev/setup & more/config.yaml",
+		},
+		Interval: Weekly,
+		Weekday:  time.Monday,
+		Hour:     10,
+		Minute:   30,
+		LogFile:  "/Users/dev/Library/Logs/setup-mac/update.log",
+		Path:     "/opt/homebrew/bin:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin",
+	}
+}
+
+func TestPlistGolden(t *testing.T) {
+	daily := testJob()
+	daily.Interval = Daily
+	daily.LogFile = ""
+	daily.Path = ""
+
+	tests := map[string]Job{
+		"weekly.plist": testJob(),
+		"daily.plist":  daily,
+	}
+
+	for name, job := range tests {
+		got, err := Plist(job)
+		if err != nil {
+			t.Fatalf("%s: Plist failed: %v", name, err)
+		}
+
+		golden := filepath.Join("testdata", name)
+		if *update {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update %s: %v", golden, err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", golden, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s differs from the golden file:\n%s", name, got)
+		}
+	}
+}
+
+func TestPlistInvalidJob(t *testing.T) {
+	job := testJob()
+	job.Interval = "hourly"
+	if _, err := Plist(job); err == nil {
+		t.Error("expected invalid interval error")
+	}
+
+	job = testJob()
+	job.Hour = 24
+	if _, err := Plist(job); err == nil {
+		t.Error("expected invalid time error")
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+
+	overnight, err := ParseQuietHours("22:00-07:00")
+	if err != nil {
+		t.Fatalf("ParseQuietHours failed: %v", err)
+	}
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "03:00": true, "06:59": true, "07:00": false, "12:00": false} {
+		if got := overnight.Contains(at(clock)); got != want {
+			t.Errorf("22:00-07:00 contains %s = %v, want %v", clock, got, want)
+		}
+	}
+
+	lunch, _ := ParseQuietHours("12:00-13:30")
+	if !lunch.Contains(at("13:00")) || lunch.Contains(at("13:30")) || lunch.String() != "12:00-13:30" {
+		t.Errorf("unexpected window %s", lunch)
+	}
+
+	for _, invalid := range []string{"22:00", "25:00-07:00", "22:00-7pm"} {
+		if _, err := ParseQuietHours(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for input, want := range map[string]time.Weekday{"mon": time.Monday, "Sunday": time.Sunday, "SAT": time.Saturday} {
+		if got, err := ParseWeekday(input); err != nil || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v", input, got, err)
+		}
+	}
+	if _, err := ParseWeekday("m"); err == nil {
+		t.Error("expected error for ambiguous weekday")
+	}
+}
+
+func TestParseLaunchctlPrint(t *testing.T) {
+	output := `gui/501/com.github.tldr-it-stepankutaj.setup-mac.update = {
+	active count = 0
+	path = /Users/dev/Library/LaunchAgents/com.github.tldr-it-stepankutaj.setup-mac.update.plist
+	state = not running
+	stdout path = /Users/dev/Library/Logs/setup-mac/update.log
+	last exit code = 0
+	event triggers = {
+		state = active
+	}
+}
+`
+	info := parseLaunchctlPrint(output)
+	if info["state"] != "not running" || info["last exit code"] != "0" || info["stdout path"] != "/Users/dev/Library/Logs/setup-mac/update.log" {
+		t.Errorf("unexpected info: %v", info)
+	}
+}
+
+func TestEnableAndDisable(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	// Nothing is loaded until bootstrap has run
+	script := `#!/bin/sh
+echo "$*" >> "` + calls + `"
+if [ "$1" = "print" ]; then
+  grep -q bootstrap "` + calls + `" && ! grep -q bootout "` + calls + `"
+fi
+`
+	if err := os.WriteFile(filepath.Join(bin, "launchctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	job := testJob()
+	job.LogFile = filepath.Join(home, "Library", "Logs", "setup-mac", "update.log")
+	exec := executor.New(false, false)
+	ctx := context.Background()
+
+	path, err := Enable(ctx, exec, job)
+	if err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("plist not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(job.LogFile)); err != nil {
+		t.Errorf("log directory not created: %v", err)
+	}
+	if status := GetStatus(ctx, exec, Label); !status.Installed || !status.Loaded {
+		t.Errorf("unexpected status after enable: %+v", status)
+	}
+
+	if err := Disable(ctx, exec, Label); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("plist not removed: %v", err)
+	}
+
+	log, _ := os.ReadFile(calls)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	var actions []string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "print") {
+			actions = append(actions, strings.Fields(line)[0])
+		}
+	}
+	if strings.Join(actions, ",") != "bootstrap,bootout" {
+		t.Errorf("unexpected launchctl calls:\n%s", log)
+	}
+}
@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// Prefix marks a config value as a secret reference
+const Prefix = "secret://"
+
+// Mask is shown in place of secret values
+const Mask = "********"
+
+// Resolver resolves one kind of secret reference
+type Resolver interface {
+	// Scheme returns the reference prefix handled, e.g. "keychain:"
+	Scheme() string
+
+	// Resolve returns the secret for a reference with the scheme stripped
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// IsRef reports whether a config value is a secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Manager resolves secret references through registered resolvers.
+// Resolved values are cached and registered with the executor for redaction.
+type Manager struct {
+	exec      *executor.Executor
+	resolvers []Resolver
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewManager creates a manager with the Keychain, 1Password and env resolvers
+func NewManager(exec *executor.Executor) *Manager {
+	m := &Manager{
+		exec:  exec,
+		cache: make(map[string]string),
+	}
+	m.Register(&KeychainResolver{exec: exec})
+	m.Register(&OnePasswordResolver{exec: exec})
+	m.Register(&EnvResolver{})
+	return m
+}
+
+// Register adds a resolver. Later registrations take precedence.
+func (m *Manager) Register(r Resolver) {
+	m.resolvers = append([]Resolver{r}, m.resolvers...)
+}
+
+// Validate checks that a reference has a known scheme without resolving it
+func (m *Manager) Validate(value string) error {
+	if !IsRef(value) {
+		return nil
+	}
+	_, _, err := m.resolverFor(value)
+	return err
+}
+
+// Resolve returns the secret for a reference, or value unchanged if it is not one
+func (m *Manager) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if secret, ok := m.cache[value]; ok {
+		return secret, nil
+	}
+
+	resolver, ref, err := m.resolverFor(value)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+
+	m.cache[value] = secret
+	if m.exec != nil {
+		m.exec.Redact(secret)
+	}
+
+	return secret, nil
+}
+
+func (m *Manager) resolverFor(value string) (Resolver, string, error) {
+	ref := strings.TrimPrefix(value, Prefix)
+	for _, r := range m.resolvers {
+		if strings.HasPrefix(ref, r.Scheme()) {
+			return r, strings.TrimPrefix(ref, r.Scheme()), nil
+		}
+	}
+
+	var schemes []string
+	for _, r := range m.resolvers {
+		schemes = append(schemes, r.Scheme())
+	}
+	return nil, "", fmt.Errorf("unknown secret reference %s (supported: %s)", value, strings.Join(schemes, ", "))
+}
+
+// KeychainResolver reads generic passwords from the macOS Keychain.
+// References have the form keychain:service/account.
+type KeychainResolver struct {
+	exec *executor.Executor
+}
+
+// Scheme returns the reference prefix
+func (k *KeychainResolver) Scheme() string {
+	return "keychain:"
+}
+
+// Resolve looks up the password with the security tool
+func (k *KeychainResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	args := []string{"find-generic-password", "-w"}
+
+	service, account, hasAccount := ref, "", false
+	if idx := strings.LastIndex(ref, "/"); idx != -1 {
+		service, account, hasAccount = ref[:idx], ref[idx+1:], true
+	}
+	if service == "" {
+		return "", fmt.Errorf("keychain reference needs a service name")
+	}
+
+	args = append(args, "-s", service)
+	if hasAccount && account != "" {
+		args = append(args, "-a", account)
+	}
+
+	result, err := k.exec.Run(ctx, "security", args...)
+	if err != nil {
+		return "", fmt.Errorf("keychain item not found: %w", err)
+	}
+
+	return strings.TrimRight(result.Stdout, "\n"), nil
+}
+
+// OnePasswordResolver reads secrets with the 1Password CLI.
+// References have the form op://vault/item/field.
+type OnePasswordResolver struct {
+	exec *executor.Executor
+}
+
+// Scheme returns the reference prefix
+func (o *OnePasswordResolver) Scheme() string {
+	return "op://"
+}
+
+// Resolve reads the field with `op read`
+func (o *OnePasswordResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	if !o.exec.Exists("op") {
+		return "", fmt.Errorf("1Password CLI (op) is not installed")
+	}
+
+	result, err := o.exec.Run(ctx, "op", "read", "op://"+ref)
+	if err != nil {
+		return "", fmt.Errorf("op read failed: %w", err)
+	}
+
+	return strings.TrimRight(result.Stdout, "\n"), nil
+}
+
+// EnvResolver reads secrets from environment variables.
+// References have the form env:NAME.
+type EnvResolver struct{}
+
+// Scheme returns the reference prefix
+func (e *EnvResolver) Scheme() string {
+	return "env:"
+}
+
+// Resolve returns the value of the environment variable
+func (e *EnvResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// fakeBinary writes an executable shell script to dir and puts dir on PATH
+func fakeBinary(t *testing.T, dir, name, script string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("failed to write fake %s: %v", name, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestIsRef(t *testing.T) {
+	if !IsRef("secret://env:TOKEN") {
+		t.Error("expected secret:// value to be a reference")
+	}
+	if IsRef("code --wait") {
+		t.Error("expected plain value not to be a reference")
+	}
+}
+
+func TestResolvePlainValue(t *testing.T) {
+	m := NewManager(executor.New(false, false))
+
+	value, err := m.Resolve(context.Background(), "en_US.UTF-8")
+	if err != nil || value != "en_US.UTF-8" {
+		t.Errorf("expected plain value unchanged, got %q, %v", value, err)
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("SETUP_MAC_TEST_TOKEN", "from-env")
+	m := NewManager(executor.New(false, false))
+
+	value, err := m.Resolve(context.Background(), "secret://env:SETUP_MAC_TEST_TOKEN")
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if value != "from-env" {
+		t.Errorf("expected from-env, got %q", value)
+	}
+
+	if _, err := m.Resolve(context.Background(), "secret://env:SETUP_MAC_UNSET_VARIABLE"); err == nil {
+		t.Error("expected error for unset variable")
+	}
+}
+
+func TestResolveKeychain(t *testing.T) {
+	dir := t.TempDir()
+	fakeBinary(t, dir, "security", `
+# Only answer for the expected service and account
+if [ "$1 $2 $3 $4 $5 $6" = "find-generic-password -w -s npm.registry -a ci" ]; then
+  echo "keychain-secret"
+  exit 0
+fi
+echo "security: SecKeychainSearchCopyNext: The specified item could not be found." >&2
+exit 44
+`)
+
+	var stdout bytes.Buffer
+	exec := executor.New(false, true)
+	exec.Stdout = &stdout
+	m := NewManager(exec)
+
+	value, err := m.Resolve(context.Background(), "secret://keychain:npm.registry/ci")
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if value != "keychain-secret" {
+		t.Errorf("expected keychain-secret, got %q", value)
+	}
+
+	if _, err := m.Resolve(context.Background(), "secret://keychain:missing/ci"); err == nil {
+		t.Error("expected error for missing keychain item")
+	}
+
+	// Commands using the secret afterwards must not print it
+	if _, err := exec.Run(context.Background(), "echo", value); err != nil {
+		t.Fatalf("failed to run echo: %v", err)
+	}
+	if bytes.Contains(stdout.Bytes(), []byte("keychain-secret")) {
+		t.Errorf("secret leaked in verbose output: %s", stdout.String())
+	}
+}
+
+func TestResolveOnePassword(t *testing.T) {
+	dir := t.TempDir()
+	fakeBinary(t, dir, "op", `
+if [ "$1" = "read" ] && [ "$2" = "op://Engineering/GitHub/token" ]; then
+  echo "op-secret"
+  exit 0
+fi
+exit 1
+`)
+
+	m := NewManager(executor.New(false, false))
+
+	value, err := m.Resolve(context.Background(), "secret://op://Engineering/GitHub/token")
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if value != "op-secret" {
+		t.Errorf("expected op-secret, got %q", value)
+	}
+}
+
+func TestResolveCachesValues(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	fakeBinary(t, dir, "op", `echo call >> "`+counter+`"; echo cached`)
+
+	m := NewManager(executor.New(false, false))
+	for i := 0; i < 3; i++ {
+		if _, err := m.Resolve(context.Background(), "secret://op://vault/item/field"); err != nil {
+			t.Fatalf("failed to resolve: %v", err)
+		}
+	}
+
+	calls, _ := os.ReadFile(counter)
+	if got := bytes.Count(calls, []byte("call")); got != 1 {
+		t.Errorf("expected op to run once, ran %d times", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := NewManager(executor.New(false, false))
+
+	if err := m.Validate("secret://vault:foo"); err == nil {
+		t.Error("expected error for unknown scheme")
+	}
+	if err := m.Validate("secret://keychain:service/account"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type staticResolver struct{}
+
+func (staticResolver) Scheme() string { return "static:" }
+
+func (staticResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	return "static-" + ref, nil
+}
+
+func TestRegisterCustomResolver(t *testing.T) {
+	m := NewManager(executor.New(false, false))
+	m.Register(staticResolver{})
+
+	value, err := m.Resolve(context.Background(), "secret://static:value")
+	if err != nil || value != "static-value" {
+		t.Errorf("expected static-value, got %q, %v", value, err)
+	}
+}
@@ -0,0 +1,63 @@
+package system
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/tldr-it-stepankutaj/setup-mac/internal/executor"
+)
+
+// Info contains information about the machine setup-mac runs on
+type Info struct {
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	AppleSilicon bool   `json:"apple_silicon"`
+	MacOSVersion string `json:"macos_version,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+}
+
+// Detect collects system information
+func Detect(ctx context.Context, exec *executor.Executor) Info {
+	info := Info{
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		AppleSilicon: runtime.GOARCH == "arm64",
+	}
+
+	// Get macOS version
+	if runtime.GOOS == "darwin" {
+		result, err := exec.Run(ctx, "sw_vers", "-productVersion")
+		if err == nil {
+			info.MacOSVersion = strings.TrimSpace(result.Stdout)
+		}
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		info.Hostname = hostname
+	}
+
+	return info
+}
+
+// OnBattery reports whether the Mac is running on battery power
+func OnBattery(ctx context.Context, exec *executor.Executor) (bool, error) {
+	result, err := exec.Query(ctx, "pmset", "-g", "batt")
+	if err != nil {
+		return false, err
+	}
+	return parsePowerSource(result.Stdout) == "Battery Power", nil
+}
+
+// parsePowerSource reads the source from pmset -g batt output:
+//
+//	Now drawing from 'Battery Power'
+func parsePowerSource(output string) string {
+	_, rest, ok := strings.Cut(output, "drawing from '")
+	if !ok {
+		return ""
+	}
+	source, _, _ := strings.Cut(rest, "'")
+	return source
+}
@@ -128,6 +128,66 @@ func (p *Prompt) SelectWithDescription(label string, items []SelectItem) (int, *
 	return idx, &items[idx], nil
 }
 
+// MultiSelect asks to pick any number of items from a list.
+// Items in selected start checked; entries are toggled until "Done" is chosen.
+func (p *Prompt) MultiSelect(label string, items []string, selected []string) ([]string, error) {
+	if !p.Interactive {
+		return selected, nil
+	}
+
+	checked := make(map[string]bool)
+	for _, item := range selected {
+		checked[item] = true
+	}
+
+	cursor := 0
+	for {
+		options := make([]string, len(items)+1)
+		options[0] = "Done"
+		for i, item := range items {
+			mark := "[ ]"
+			if checked[item] {
+				mark = "[x]"
+			}
+			options[i+1] = fmt.Sprintf("%s %s", mark, item)
+		}
+
+		prompt := promptui.Select{
+			Label:        label,
+			Items:        options,
+			Size:         10,
+			HideSelected: true,
+		}
+
+		scroll := cursor - prompt.Size + 1
+		if scroll < 0 {
+			scroll = 0
+		}
+
+		idx, _, err := prompt.RunCursorAt(cursor, scroll)
+		if err != nil {
+			return nil, err
+		}
+
+		if idx == 0 {
+			break
+		}
+
+		item := items[idx-1]
+		checked[item] = !checked[item]
+		cursor = idx
+	}
+
+	var result []string
+	for _, item := range items {
+		if checked[item] {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
 // SelectItem represents an item with description
 type SelectItem struct {
 	Name        string
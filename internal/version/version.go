@@ -0,0 +1,119 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Compare compares two dotted version strings numerically.
+// It returns -1 if a < b, 0 if they are equal and 1 if a > b.
+// Missing components count as zero, so "14" equals "14.0".
+func Compare(a, b string) int {
+	return compareParts(parse(a), parse(b), -1)
+}
+
+// Satisfies reports whether v matches a constraint such as ">=14",
+// "<15.2" or ">=13, <15". Several comma-separated conditions must all hold.
+// A bare version like "14" matches every release with that prefix.
+func Satisfies(v, constraint string) (bool, error) {
+	if strings.TrimSpace(constraint) == "" {
+		return true, nil
+	}
+
+	for _, cond := range strings.Split(constraint, ",") {
+		cond = strings.TrimSpace(cond)
+
+		op := ""
+		for _, candidate := range []string{">=", "<=", "!=", "==", ">", "<", "=", "~"} {
+			if strings.HasPrefix(cond, candidate) {
+				op = candidate
+				break
+			}
+		}
+
+		target := strings.TrimSpace(strings.TrimPrefix(cond, op))
+		if target == "" {
+			return false, fmt.Errorf("invalid version constraint: %q", cond)
+		}
+
+		targetParts := parse(target)
+		vParts := parse(v)
+
+		var ok bool
+		switch op {
+		case ">=":
+			ok = compareParts(vParts, targetParts, -1) >= 0
+		case "<=":
+			ok = compareParts(vParts, targetParts, -1) <= 0
+		case ">":
+			ok = compareParts(vParts, targetParts, -1) > 0
+		case "<":
+			ok = compareParts(vParts, targetParts, -1) < 0
+		case "!=":
+			ok = compareParts(vParts, targetParts, len(targetParts)) != 0
+		case "~":
+			// ~1.5 and ~1.5.2 allow 1.5.x but not 1.6, ~1 allows 1.x
+			prefix := targetParts
+			if len(prefix) > 2 {
+				prefix = prefix[:2]
+			}
+			ok = compareParts(vParts, targetParts, -1) >= 0 && compareParts(vParts, prefix, len(prefix)) == 0
+		default:
+			ok = compareParts(vParts, targetParts, len(targetParts)) == 0
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// parse splits a version into numeric components, ignoring suffixes like
+// "_1" revisions or "-beta" pre-release tags
+func parse(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if idx := strings.IndexAny(v, "_-+ "); idx != -1 {
+		v = v[:idx]
+	}
+
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareParts compares the first n components (all when n < 0)
+func compareParts(a, b []int, n int) int {
+	length := len(a)
+	if len(b) > length {
+		length = len(b)
+	}
+	if n >= 0 && n < length {
+		length = n
+	}
+
+	for i := 0; i < length; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
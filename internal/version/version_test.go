@@ -0,0 +1,62 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"14.5", "14.5", 0},
+		{"14", "14.0", 0},
+		{"14.10", "14.9", 1},
+		{"13.6.1", "14", -1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.5.7_1", "1.5.7", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		v, constraint string
+		want          bool
+	}{
+		{"14.5", ">=14", true},
+		{"13.6", ">=14", false},
+		{"14.5", "<15", true},
+		{"15.0", "<15", false},
+		{"14.5", "14", true},
+		{"15.1", "14", false},
+		{"14.5", ">=13, <15", true},
+		{"15.0", ">=13, <15", false},
+		{"1.5.7", "~1.5", true},
+		{"1.6.0", "~1.5", false},
+		{"1.5.7", "~1.5.2", true},
+		{"1.6.0", "~1.5.2", false},
+		{"14.5", "!=14", false},
+		{"14.5", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Satisfies(tt.v, tt.constraint)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q) returned error: %v", tt.v, tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Satisfies(%q, %q) = %v, want %v", tt.v, tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfiesInvalid(t *testing.T) {
+	if _, err := Satisfies("14.5", ">="); err == nil {
+		t.Error("expected error for empty constraint target")
+	}
+}